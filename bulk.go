@@ -0,0 +1,508 @@
+package scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+const (
+	// bulkIDPrefix is the prefix of a value that references the resource created by another operation within the same
+	// bulk request, e.g. "bulkId:qwerty".
+	bulkIDPrefix       = "bulkId:"
+	bulkRequestSchema  = "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
+	bulkResponseSchema = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+)
+
+// bulkForwardedHeaders are the headers of a bulk request that are passed on to its operations: the credentials and the
+// content negotiation headers. Preconditions are not passed on, they are taken from the version of each operation.
+var bulkForwardedHeaders = []string{
+	"Accept",
+	"Accept-Charset",
+	"Accept-Language",
+	"Authorization",
+	"Cookie",
+}
+
+// bulkIDReferences returns the bulk identifiers that are referenced within the given path and data.
+func bulkIDReferences(path string, data interface{}) []string {
+	var references []string
+	if i := strings.Index(path, bulkIDPrefix); i != -1 {
+		reference := strings.TrimPrefix(path[i:], bulkIDPrefix)
+		if j := strings.Index(reference, "/"); j != -1 {
+			reference = reference[:j]
+		}
+		references = append(references, reference)
+	}
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			if strings.HasPrefix(v, bulkIDPrefix) {
+				references = append(references, strings.TrimPrefix(v, bulkIDPrefix))
+			}
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(data)
+	return references
+}
+
+// bulkPayloadTooLarge returns the error that is returned if the payload of a bulk request exceeds the maximum size.
+func bulkPayloadTooLarge(maxPayloadSize int) *errors.ScimError {
+	return &errors.ScimError{
+		Detail: fmt.Sprintf("The size of the bulk operation exceeds the maxPayloadSize (%d).", maxPayloadSize),
+		Status: http.StatusRequestEntityTooLarge,
+	}
+}
+
+// collectBulkResults returns the results of the operations that were performed, in the order of the request.
+func collectBulkResults(results []*bulkResponseOperation) []bulkResponseOperation {
+	operations := make([]bulkResponseOperation, 0, len(results))
+	for _, result := range results {
+		if result != nil {
+			operations = append(operations, *result)
+		}
+	}
+	return operations
+}
+
+// replaceBulkIDs returns a copy of the given value in which all the bulk identifier references are replaced by the
+// identifiers of the resources that were created by the corresponding operations.
+func replaceBulkIDs(v interface{}, ids map[string]string) interface{} {
+	switch v := v.(type) {
+	case string:
+		if strings.HasPrefix(v, bulkIDPrefix) {
+			if id, ok := ids[strings.TrimPrefix(v, bulkIDPrefix)]; ok {
+				return id
+			}
+		}
+		return v
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, e := range v {
+			values[i] = replaceBulkIDs(e, ids)
+		}
+		return values
+	case map[string]interface{}:
+		values := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			values[k] = replaceBulkIDs(e, ids)
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+// replaceBulkIDsInPath replaces a bulk identifier reference within the given path, e.g. "/Groups/bulkId:qwerty".
+func replaceBulkIDsInPath(path string, ids map[string]string) string {
+	for _, reference := range bulkIDReferences(path, nil) {
+		path = strings.Replace(path, bulkIDPrefix+reference, ids[reference], 1)
+	}
+	return path
+}
+
+// bulkHandler receives an HTTP POST request to the "/Bulk" endpoint to perform a set of operations on potentially
+// multiple resources in a single request, as defined in Section 3.7 of RFC 7644.
+func (s Server) bulkHandler(w http.ResponseWriter, r *http.Request) {
+	maxPayloadSize := s.config.getBulkMaxPayloadSize()
+	if r.ContentLength > int64(maxPayloadSize) {
+		s.errorHandler(w, bulkPayloadTooLarge(maxPayloadSize))
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, int64(maxPayloadSize)+1))
+	if err != nil {
+		s.errorHandler(w, &errors.ScimErrorInternal)
+		return
+	}
+	if len(data) > maxPayloadSize {
+		s.errorHandler(w, bulkPayloadTooLarge(maxPayloadSize))
+		return
+	}
+
+	var req bulkRequest
+	if err := unmarshal(data, &req); err != nil {
+		s.errorHandler(w, &errors.ScimErrorInvalidSyntax)
+		return
+	}
+
+	if len(req.Schemas) != 1 || req.Schemas[0] != bulkRequestSchema {
+		s.errorHandler(w, &errors.ScimErrorInvalidValue)
+		return
+	}
+
+	if maxOperations := s.config.getBulkMaxOperations(); len(req.Operations) > maxOperations {
+		s.errorHandler(w, &errors.ScimError{
+			Detail: fmt.Sprintf("The number of operations exceeds the maxOperations (%d).", maxOperations),
+			Status: http.StatusRequestEntityTooLarge,
+		})
+		return
+	}
+
+	bulkIDs := make(map[string]bool)
+	for _, op := range req.Operations {
+		if op.BulkID == "" {
+			continue
+		}
+		if bulkIDs[op.BulkID] {
+			scimErr := errors.ScimErrorBadRequest(fmt.Sprintf("Duplicate bulkId %q.", op.BulkID))
+			s.errorHandler(w, &scimErr)
+			return
+		}
+		bulkIDs[op.BulkID] = true
+	}
+
+	resp := bulkResponse{
+		Operations: s.processBulkOperations(r, req, bulkIDs),
+	}
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		s.errorHandler(w, &errors.ScimErrorInternal)
+		s.log.Error(
+			"failed marshaling bulk response",
+			"bulkResponse", resp,
+			"error", err,
+		)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(raw)
+	if err != nil {
+		s.log.Error(
+			"failed writing response",
+			"error", err,
+		)
+	}
+}
+
+// bulkLocation returns the location of the resource that is targeted by the given path.
+func (s Server) bulkLocation(path string) string {
+	for _, resourceType := range s.resourceTypes {
+		if strings.HasPrefix(path, resourceType.Endpoint+"/") {
+			id, err := parseIdentifier(path, resourceType.Endpoint)
+			if err != nil {
+				return ""
+			}
+			return resourceLocation(resourceType, id, s.baseURL)
+		}
+	}
+	return ""
+}
+
+// dispatchBulkOperation routes a single bulk operation to the handler of the resource type it targets. Only the
+// POST, PUT, PATCH and DELETE methods on resource endpoints are supported within a bulk request.
+func (s Server) dispatchBulkOperation(w http.ResponseWriter, r *http.Request, path string) {
+	for _, resourceType := range s.resourceTypes {
+		if path == resourceType.Endpoint && r.Method == http.MethodPost {
+			s.resourcePostHandler(w, r, resourceType)
+			return
+		}
+
+		if strings.HasPrefix(path, resourceType.Endpoint+"/") {
+			id, err := parseIdentifier(path, resourceType.Endpoint)
+			if err != nil {
+				break
+			}
+
+			switch r.Method {
+			case http.MethodPut:
+				s.resourcePutHandler(w, r, id, resourceType)
+				return
+			case http.MethodPatch:
+				s.resourcePatchHandler(w, r, id, resourceType)
+				return
+			case http.MethodDelete:
+				s.resourceDeleteHandler(w, r, id, resourceType)
+				return
+			}
+		}
+	}
+
+	s.errorHandler(w, &errors.ScimError{
+		Detail: "Specified endpoint does not exist.",
+		Status: http.StatusNotFound,
+	})
+}
+
+// performBulkOperation performs a single operation of a bulk request. All bulk identifier references in the path and
+// data of the operation must already be resolved.
+func (s Server) performBulkOperation(r *http.Request, op bulkRequestOperation, data interface{}, ids map[string]string) bulkResponseOperation {
+	method := strings.ToUpper(op.Method)
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return op.failed(errors.ScimError{
+			ScimType: errors.ScimTypeInvalidValue,
+			Detail:   fmt.Sprintf("Unsupported bulk operation method %q.", op.Method),
+			Status:   http.StatusBadRequest,
+		})
+	}
+	if method == http.MethodPost && op.BulkID == "" {
+		return op.failed(errors.ScimError{
+			ScimType: errors.ScimTypeInvalidValue,
+			Detail:   "A bulkId is required for POST operations.",
+			Status:   http.StatusBadRequest,
+		})
+	}
+
+	var body []byte
+	if data != nil {
+		raw, err := json.Marshal(replaceBulkIDs(data, ids))
+		if err != nil {
+			return op.failed(errors.ScimErrorInvalidSyntax)
+		}
+		body = raw
+	}
+
+	req, err := http.NewRequest(method, replaceBulkIDsInPath(op.Path, ids), bytes.NewReader(body))
+	if err != nil {
+		return op.failed(errors.ScimErrorInvalidPath)
+	}
+	req = req.WithContext(r.Context())
+	for _, key := range bulkForwardedHeaders {
+		if values := r.Header.Values(key); len(values) != 0 {
+			req.Header[key] = append([]string(nil), values...)
+		}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/scim+json")
+	}
+	if op.Version != "" {
+		req.Header.Set("If-Match", op.Version)
+	}
+
+	path := s.routePath(req.URL.Path)
+	rw := &bulkResponseWriter{header: make(http.Header)}
+	s.dispatchBulkOperation(rw, req, path)
+
+	result := bulkResponseOperation{
+		Method:   method,
+		BulkID:   op.BulkID,
+		Version:  rw.header.Get("Etag"),
+		Location: rw.header.Get("Location"),
+		Status:   strconv.Itoa(rw.statusCode()),
+	}
+	if rw.statusCode() >= http.StatusBadRequest {
+		result.Response = rw.body.Bytes()
+		return result
+	}
+
+	if method == http.MethodPost {
+		var created struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(rw.body.Bytes(), &created); err == nil {
+			ids[op.BulkID] = created.ID
+		}
+	} else if result.Location == "" {
+		result.Location = s.bulkLocation(path)
+	}
+	return result
+}
+
+// processBulkOperations performs the operations of the given bulk request. Operations that reference the bulk
+// identifier of an operation that has not been performed yet are postponed until that operation is performed, which
+// allows forward references. Operations that can not be resolved because of circular references fail with a
+// 409 Conflict. Processing stops once the number of failed operations reaches "failOnErrors".
+func (s Server) processBulkOperations(r *http.Request, req bulkRequest, bulkIDs map[string]bool) []bulkResponseOperation {
+	var (
+		ids     = make(map[string]string)
+		failed  = make(map[string]bool)
+		results = make([]*bulkResponseOperation, len(req.Operations))
+		data    = make([]interface{}, len(req.Operations))
+		pending []int
+		errs    int
+	)
+
+	record := func(i int, result bulkResponseOperation) bool {
+		results[i] = &result
+		if status, _ := strconv.Atoi(result.Status); status >= http.StatusBadRequest {
+			if op := req.Operations[i]; op.BulkID != "" {
+				failed[op.BulkID] = true
+			}
+			errs++
+		}
+		return req.FailOnErrors != nil && *req.FailOnErrors > 0 && errs >= *req.FailOnErrors
+	}
+
+	for i, op := range req.Operations {
+		if len(op.Data) != 0 {
+			if err := unmarshal(op.Data, &data[i]); err != nil {
+				if record(i, op.failed(errors.ScimErrorInvalidSyntax)) {
+					return collectBulkResults(results)
+				}
+				continue
+			}
+		}
+		pending = append(pending, i)
+	}
+
+	for len(pending) != 0 {
+		var (
+			postponed []int
+			progress  bool
+		)
+		for _, i := range pending {
+			op := req.Operations[i]
+
+			var (
+				result  *bulkResponseOperation
+				blocked bool
+			)
+			for _, reference := range bulkIDReferences(op.Path, data[i]) {
+				switch {
+				case !bulkIDs[reference]:
+					res := op.failed(errors.ScimError{
+						ScimType: errors.ScimTypeInvalidValue,
+						Detail:   fmt.Sprintf("The bulkId %q is not defined within the request.", reference),
+						Status:   http.StatusBadRequest,
+					})
+					result = &res
+				case failed[reference]:
+					res := op.failed(errors.ScimError{
+						Detail: fmt.Sprintf("The operation with bulkId %q failed.", reference),
+						Status: http.StatusConflict,
+					})
+					result = &res
+				case ids[reference] == "":
+					blocked = true
+				}
+				if result != nil {
+					break
+				}
+			}
+
+			if result == nil && blocked {
+				postponed = append(postponed, i)
+				continue
+			}
+			if result == nil {
+				res := s.performBulkOperation(r, op, data[i], ids)
+				result = &res
+			}
+			progress = true
+			if record(i, *result) {
+				return collectBulkResults(results)
+			}
+		}
+
+		if !progress {
+			// None of the postponed operations could be performed, so they reference each other.
+			for _, i := range postponed {
+				if record(i, req.Operations[i].failed(errors.ScimError{
+					Detail: "The operation contains a circular bulkId reference that can not be resolved.",
+					Status: http.StatusConflict,
+				})) {
+					return collectBulkResults(results)
+				}
+			}
+			break
+		}
+		pending = postponed
+	}
+	return collectBulkResults(results)
+}
+
+// bulkRequest represents the JSON body of a POST /Bulk request per RFC 7644 Section 3.7.
+type bulkRequest struct {
+	Schemas []string
+	// FailOnErrors is the number of errors that the service provider will accept before the operation is terminated.
+	FailOnErrors *int
+	Operations   []bulkRequestOperation
+}
+
+// bulkRequestOperation is a single operation within a bulk request.
+type bulkRequestOperation struct {
+	// Method is the HTTP method of the operation: "POST", "PUT", "PATCH" or "DELETE".
+	Method string
+	// BulkID is a transient identifier of a newly created resource, unique within the bulk request.
+	BulkID string `json:"bulkId"`
+	// Version is the current version of the resource being updated.
+	Version string
+	// Path is the resource's relative path to the SCIM service provider's root, e.g. "/Users".
+	Path string
+	// Data is the resource data as it would appear for a single POST, PUT or PATCH request.
+	Data json.RawMessage
+}
+
+// failed returns the response of the operation that failed with the given SCIM error.
+func (op bulkRequestOperation) failed(scimErr errors.ScimError) bulkResponseOperation {
+	raw, _ := json.Marshal(scimErr)
+	return bulkResponseOperation{
+		Method:   strings.ToUpper(op.Method),
+		BulkID:   op.BulkID,
+		Status:   strconv.Itoa(scimErr.Status),
+		Response: raw,
+	}
+}
+
+// bulkResponse identifies a bulk response per RFC 7644 Section 3.7.
+type bulkResponse struct {
+	Operations []bulkResponseOperation
+}
+
+func (b bulkResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"schemas":    []string{bulkResponseSchema},
+		"Operations": b.Operations,
+	})
+}
+
+// bulkResponseOperation is the result of a single operation within a bulk request.
+type bulkResponseOperation struct {
+	Method   string          `json:"method"`
+	BulkID   string          `json:"bulkId,omitempty"`
+	Version  string          `json:"version,omitempty"`
+	Location string          `json:"location,omitempty"`
+	Status   string          `json:"status"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// bulkResponseWriter buffers the response of a single bulk operation.
+type bulkResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bulkResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bulkResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *bulkResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// statusCode returns the status code of the response, which defaults to 200 OK if none was written.
+func (w *bulkResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
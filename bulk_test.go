@@ -0,0 +1,355 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+)
+
+func TestServerBulkCircularReference(t *testing.T) {
+	rr := serveBulkRequest(t, newTestServerWithBulk(t), `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{
+				"method": "POST",
+				"path": "/Groups",
+				"bulkId": "a",
+				"data": {
+					"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+					"displayName": "A",
+					"members": [{"value": "bulkId:b"}]
+				}
+			},
+			{
+				"method": "POST",
+				"path": "/Groups",
+				"bulkId": "b",
+				"data": {
+					"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+					"displayName": "B",
+					"members": [{"value": "bulkId:a"}]
+				}
+			}
+		]
+	}`)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	response := unmarshalBulkResponse(t, rr)
+	assertLen(t, response.Operations, 2)
+	for _, op := range response.Operations {
+		assertEqual(t, "409", op.Status)
+		assertNotNil(t, op.Response, "response")
+	}
+}
+
+func TestServerBulkBaseURL(t *testing.T) {
+	s := newTestServerWithBulk(t)
+	WithBaseURL("https://example.com/scim/v2")(&s)
+
+	rr := serveBulkRequest(t, s, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "DELETE", "path": "/scim/v2/Users/0001"},
+			{"method": "DELETE", "path": "/Users/0002"}
+		]
+	}`)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	response := unmarshalBulkResponse(t, rr)
+	assertLen(t, response.Operations, 2)
+	for _, op := range response.Operations {
+		assertEqual(t, "204", op.Status)
+	}
+}
+
+func TestServerBulkFailOnErrors(t *testing.T) {
+	rr := serveBulkRequest(t, newTestServerWithBulk(t), `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"failOnErrors": 1,
+		"Operations": [
+			{
+				"method": "POST",
+				"path": "/Users",
+				"bulkId": "invalid",
+				"data": {"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"]}
+			},
+			{
+				"method": "POST",
+				"path": "/Users",
+				"bulkId": "valid",
+				"data": {"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "bulk"}
+			}
+		]
+	}`)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	response := unmarshalBulkResponse(t, rr)
+	assertLen(t, response.Operations, 1)
+	assertEqual(t, "invalid", response.Operations[0].BulkID)
+	assertEqual(t, "400", response.Operations[0].Status)
+}
+
+func TestServerBulkForwardReference(t *testing.T) {
+	groups := newTestResourceHandler().(testResourceHandler)
+	s := newTestServerWithBulk(t)
+	s.resourceTypes[1].Handler = groups
+
+	rr := serveBulkRequest(t, s, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{
+				"method": "POST",
+				"path": "/Groups",
+				"bulkId": "group",
+				"data": {
+					"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+					"displayName": "Tour Guides",
+					"members": [{"type": "User", "value": "bulkId:user"}]
+				}
+			},
+			{
+				"method": "POST",
+				"path": "/Users",
+				"bulkId": "user",
+				"data": {"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "Alice"}
+			},
+			{
+				"method": "DELETE",
+				"path": "/Users/0001"
+			}
+		]
+	}`)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	response := unmarshalBulkResponse(t, rr)
+	assertLen(t, response.Operations, 3)
+
+	group, user, deleted := response.Operations[0], response.Operations[1], response.Operations[2]
+	assertEqual(t, "201", group.Status)
+	assertEqual(t, "201", user.Status)
+	assertEqual(t, "204", deleted.Status)
+	assertEqual(t, "Users/0001", deleted.Location)
+	assertTrue(t, strings.HasPrefix(user.Location, "Users/"))
+	assertTrue(t, strings.HasPrefix(group.Location, "Groups/"))
+
+	userID := strings.TrimPrefix(user.Location, "Users/")
+	groupID := strings.TrimPrefix(group.Location, "Groups/")
+	members, ok := groups.data[groupID].resourceAttributes["members"].([]interface{})
+	assertTrue(t, ok)
+	assertLen(t, members, 1)
+	assertEqual(t, userID, members[0].(map[string]interface{})["value"])
+}
+
+func TestServerBulkInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "wrong method",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "invalid body",
+			method:         http.MethodPost,
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid schemas",
+			method:         http.MethodPost,
+			body:           `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": []}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "duplicate bulkId",
+			method: http.MethodPost,
+			body: `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"], "Operations": [
+				{"method": "POST", "path": "/Users", "bulkId": "a", "data": {}},
+				{"method": "POST", "path": "/Users", "bulkId": "a", "data": {}}
+			]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/Bulk", strings.NewReader(test.body))
+			rr := httptest.NewRecorder()
+			newTestServerWithBulk(t).ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, test.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestServerBulkLimits(t *testing.T) {
+	newServer := func(t *testing.T) Server {
+		s := newTestServerWithBulk(t)
+		s.config.MaxBulkOperations = 1
+		s.config.MaxBulkPayloadSize = 512
+		return s
+	}
+
+	t.Run("max operations", func(t *testing.T) {
+		rr := serveBulkRequest(t, newServer(t), `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+			"Operations": [
+				{"method": "DELETE", "path": "/Users/0001"},
+				{"method": "DELETE", "path": "/Users/0002"}
+			]
+		}`)
+		assertEqualStatusCode(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+		var scimErr *errors.ScimError
+		assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &scimErr))
+		assertEqual(t, "The number of operations exceeds the maxOperations (1).", scimErr.Detail)
+	})
+
+	t.Run("max payload size", func(t *testing.T) {
+		rr := serveBulkRequest(t, newServer(t), fmt.Sprintf(`{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+			"Operations": [
+				{"method": "DELETE", "path": "/Users/%s"}
+			]
+		}`, strings.Repeat("0", 512)))
+		assertEqualStatusCode(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+		var scimErr *errors.ScimError
+		assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &scimErr))
+		assertEqual(t, "The size of the bulk operation exceeds the maxPayloadSize (512).", scimErr.Detail)
+	})
+
+	t.Run("advertised", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil)
+		rr := httptest.NewRecorder()
+		newServer(t).ServeHTTP(rr, req)
+
+		var config struct {
+			Bulk struct {
+				Supported      bool
+				MaxOperations  int
+				MaxPayloadSize int
+			}
+		}
+		assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &config))
+		assertTrue(t, config.Bulk.Supported)
+		assertEqual(t, 1, config.Bulk.MaxOperations)
+		assertEqual(t, 512, config.Bulk.MaxPayloadSize)
+	})
+}
+
+func TestServerBulkNotSupported(t *testing.T) {
+	rr := serveBulkRequest(t, newTestServer(t), `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": []
+	}`)
+	assertEqualStatusCode(t, http.StatusNotImplemented, rr.Code)
+}
+
+func TestServerBulkPreconditions(t *testing.T) {
+	s := newTestServerWithBulk(t)
+	s.config.SupportETag = true
+
+	req := httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "DELETE", "path": "/Users/0001"},
+			{"method": "DELETE", "path": "/Users/0002", "version": "W/\"other\""}
+		]
+	}`))
+	// The preconditions of the bulk request itself do not apply to its operations.
+	req.Header.Set("If-Match", `W/"other"`)
+	req.Header.Set("If-None-Match", "*")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	response := unmarshalBulkResponse(t, rr)
+	assertLen(t, response.Operations, 2)
+	assertEqual(t, "204", response.Operations[0].Status)
+	assertEqual(t, "412", response.Operations[1].Status)
+}
+
+func TestServerBulkUnresolvedReference(t *testing.T) {
+	rr := serveBulkRequest(t, newTestServerWithBulk(t), `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{
+				"method": "PATCH",
+				"path": "/Groups/bulkId:unknown",
+				"data": {
+					"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+					"Operations": [{"op": "add", "path": "displayName", "value": "x"}]
+				}
+			}
+		]
+	}`)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	response := unmarshalBulkResponse(t, rr)
+	assertLen(t, response.Operations, 1)
+	assertEqual(t, "PATCH", response.Operations[0].Method)
+	assertEqual(t, "400", response.Operations[0].Status)
+}
+
+func newTestServerWithBulk(t *testing.T) Server {
+	s, err := NewServer(
+		&ServerArgs{
+			ServiceProviderConfig: &ServiceProviderConfig{
+				SupportBulk: true,
+			},
+			ResourceTypes: []ResourceType{
+				{
+					ID:       optional.NewString("User"),
+					Name:     "User",
+					Endpoint: "/Users",
+					Schema:   getUserSchema(),
+					Handler:  newTestResourceHandler(),
+				},
+				{
+					ID:       optional.NewString("Group"),
+					Name:     "Group",
+					Endpoint: "/Groups",
+					Schema:   schema.CoreGroupSchema(),
+					Handler:  newTestResourceHandler(),
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func serveBulkRequest(t *testing.T, s Server, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	return rr
+}
+
+func unmarshalBulkResponse(t *testing.T, rr *httptest.ResponseRecorder) testBulkResponse {
+	t.Helper()
+	var response testBulkResponse
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assertEqualStrings(t, []string{"urn:ietf:params:scim:api:messages:2.0:BulkResponse"}, response.Schemas)
+	return response
+}
+
+type testBulkResponse struct {
+	Schemas    []string
+	Operations []bulkResponseOperation
+}
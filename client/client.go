@@ -0,0 +1,153 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+const (
+	// contentType is the media type of SCIM messages, as defined in RFC 7644 Section 8.1.
+	contentType = "application/scim+json"
+
+	patchOpSchema       = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	searchRequestSchema = "urn:ietf:params:scim:api:messages:2.0:SearchRequest"
+)
+
+// decode decodes the given JSON data, numbers are decoded as json.Number to preserve integers.
+func decode(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// responseError converts an unsuccessful response into a SCIM error. If the body of the response does not contain a
+// SCIM error, the status code and the body are used instead.
+func responseError(status int, body []byte) errors.ScimError {
+	var scimErr errors.ScimError
+	if err := json.Unmarshal(body, &scimErr); err == nil && scimErr.Status != 0 {
+		return scimErr
+	}
+	detail := strings.TrimSpace(string(body))
+	if detail == "" {
+		detail = http.StatusText(status)
+	}
+	return errors.ScimError{
+		Detail: detail,
+		Status: status,
+	}
+}
+
+// Client is a client for a SCIM service provider. Unsuccessful responses are returned as errors.ScimError, so that
+// they can be inspected with errors.As.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	header     http.Header
+}
+
+// NewClient returns a client for the service provider at the given base URL, e.g., "https://example.com/scim/v2".
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		header:     http.Header{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Do sends a request with the given method, query and body to the given path relative to the base URL, e.g.,
+// "/Users/2819c223", and returns the body of the response, which is empty in case of "204 No Content". Unlike the other
+// methods, it returns the response as is, including attributes that are not part of scim.Resource, e.g., "schemas" and
+// "meta.location".
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body interface{}) (json.RawMessage, error) {
+	var raw json.RawMessage
+	if _, err := c.do(ctx, method, path, query, body, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// do sends a request with the given method to the given path relative to the base URL. The given body, if not nil, is
+// encoded as JSON and the body of a successful response is decoded into out, if not nil. It returns the headers of the
+// response.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) (http.Header, error) {
+	target := c.baseURL + path
+	if len(query) != 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", contentType)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || 299 < resp.StatusCode {
+		return nil, responseError(resp.StatusCode, data)
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent && len(bytes.TrimSpace(data)) != 0 {
+		if err := decode(data, out); err != nil {
+			return nil, fmt.Errorf("invalid response from %s %s: %w", method, path, err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBearerToken authenticates all requests with the given OAuth bearer token.
+func WithBearerToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithHTTPClient sets the HTTP client that is used to send requests, e.g., to configure timeouts or authentication.
+// The http.DefaultClient is used by default.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithHeader adds the given header to all requests.
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Add(key, value)
+	}
+}
@@ -0,0 +1,241 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/client"
+	scimErrors "github.com/elimity-com/scim/errors"
+	internal "github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/memory"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	var ids []string
+	for i, userName := range []string{"charlie", "alice", "bob", "dave", "eve"} {
+		r, err := c.Create(ctx, "/Users", scim.ResourceAttributes{
+			"schemas":    []string{"urn:ietf:params:scim:schemas:core:2.0:User"},
+			"userName":   userName,
+			"externalId": fmt.Sprintf("ext-%d", i),
+			"emails": []interface{}{
+				map[string]interface{}{"value": userName + "@example.com", "type": "work"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.ID == "" || r.ExternalID.Value() != fmt.Sprintf("ext-%d", i) || r.Meta.Created == nil || r.Meta.Version == "" {
+			t.Errorf("unexpected resource: %+v", r)
+		}
+		if r.Attributes["userName"] != userName {
+			t.Errorf("unexpected user name: %v", r.Attributes["userName"])
+		}
+		for _, name := range []string{"id", "externalId", "meta", "schemas"} {
+			if _, ok := r.Attributes[name]; ok {
+				t.Errorf("unexpected attribute %q", name)
+			}
+		}
+		ids = append(ids, r.ID)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		r, err := c.Get(ctx, "/Users", ids[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.ID != ids[1] || r.Attributes["userName"] != "alice" {
+			t.Errorf("unexpected resource: %+v", r)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		resp, err := c.List(ctx, "/Users", client.ListParams{
+			Filter:    internal.Attr("userName").Sw("a").Or(internal.Attr("userName").Eq("bob")).String(),
+			SortBy:    "userName",
+			SortOrder: "descending",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.TotalResults != 2 || len(resp.Resources) != 2 || resp.Resources[0].Attributes["userName"] != "bob" {
+			t.Errorf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("iterate", func(t *testing.T) {
+		for name, iterate := range map[string]func(context.Context, string, client.ListParams) *client.Iterator{
+			"list":   c.Iterate,
+			"search": c.IterateSearch,
+		} {
+			t.Run(name, func(t *testing.T) {
+				it := iterate(ctx, "/Users", client.ListParams{SortBy: "userName", Count: 2})
+				var userNames []interface{}
+				for it.Next() {
+					userNames = append(userNames, it.Resource().Attributes["userName"])
+				}
+				if err := it.Err(); err != nil {
+					t.Fatal(err)
+				}
+				if fmt.Sprint(userNames) != "[alice bob charlie dave eve]" || it.TotalResults() != 5 {
+					t.Errorf("unexpected user names: %v", userNames)
+				}
+			})
+		}
+	})
+
+	t.Run("patch", func(t *testing.T) {
+		path, err := filter.ParsePath([]byte(`emails[type eq "work"].value`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := c.Patch(ctx, "/Users", ids[0], []scim.PatchOperation{
+			{Op: scim.PatchOperationReplace, Path: &path, Value: "c@example.com"},
+			{Op: scim.PatchOperationAdd, Value: map[string]interface{}{"displayName": "Charlie"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		emails, _ := r.Attributes["emails"].([]interface{})
+		if len(emails) != 1 || emails[0].(map[string]interface{})["value"] != "c@example.com" {
+			t.Errorf("unexpected emails: %v", r.Attributes["emails"])
+		}
+		if r.Attributes["displayName"] != "Charlie" {
+			t.Errorf("unexpected display name: %v", r.Attributes["displayName"])
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		r, err := c.Replace(ctx, "/Users", ids[2], scim.ResourceAttributes{
+			"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:User"},
+			"userName": "robert",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Attributes["userName"] != "robert" || r.Attributes["emails"] != nil {
+			t.Errorf("unexpected resource: %+v", r)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := c.Delete(ctx, "/Users", ids[3]); err != nil {
+			t.Fatal(err)
+		}
+		_, err := c.Get(ctx, "/Users", ids[3])
+		var scimErr scimErrors.ScimError
+		if !errors.As(err, &scimErr) || scimErr.Status != http.StatusNotFound {
+			t.Errorf("expected a 404 SCIM error, got %v", err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := c.Create(ctx, "/Users", scim.ResourceAttributes{
+			"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:User"},
+			"userName": "ALICE",
+		})
+		var scimErr scimErrors.ScimError
+		if !errors.As(err, &scimErr) || scimErr.Status != http.StatusConflict || scimErr.ScimType != scimErrors.ScimTypeUniqueness {
+			t.Errorf("expected a uniqueness error, got %v", err)
+		}
+
+		_, err = c.List(ctx, "/Users", client.ListParams{Filter: `userName xx "a"`})
+		if !errors.As(err, &scimErr) || scimErr.ScimType != scimErrors.ScimTypeInvalidFilter {
+			t.Errorf("expected an invalid filter error, got %v", err)
+		}
+
+		_, err = c.Get(ctx, "/Unknown", "id")
+		if !errors.As(err, &scimErr) || scimErr.Status != http.StatusNotFound {
+			t.Errorf("expected a 404 SCIM error, got %v", err)
+		}
+	})
+}
+
+func TestClient_Discover(t *testing.T) {
+	d, err := newTestClient(t).Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Config.SupportFiltering || !d.Config.SupportETag || d.Config.SupportBulk || d.Config.MaxResults != 3 {
+		t.Errorf("unexpected config: %+v", d.Config)
+	}
+
+	resourceType, ok := d.ResourceType("/Users")
+	if !ok {
+		t.Fatal("expected the user resource type")
+	}
+	if resourceType.Name != "User" || len(resourceType.SchemaExtensions) != 1 {
+		t.Errorf("unexpected resource type: %+v", resourceType)
+	}
+	if len(resourceType.Schema.Attributes) != len(schema.CoreUserSchema().Attributes) {
+		t.Errorf("expected the schema to be resolved: %+v", resourceType.Schema)
+	}
+	if len(resourceType.SchemaExtensions[0].Schema.Attributes) == 0 {
+		t.Errorf("expected the schema extension to be resolved: %+v", resourceType.SchemaExtensions[0].Schema)
+	}
+
+	// The discovered resource types can be used to validate filters locally.
+	if len(scim.ValidateFilterForResourceTypes(`employeeNumber eq "1"`, d.ResourceTypes)) != 1 {
+		t.Error("expected the filter to be valid")
+	}
+	if len(scim.ValidateFilterForResourceTypes(`unknown eq "1"`, d.ResourceTypes)) != 0 {
+		t.Error("expected the filter to be invalid")
+	}
+}
+
+func TestClient_options(t *testing.T) {
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("Unauthorized"))
+	}))
+	defer ts.Close()
+
+	c := client.NewClient(ts.URL+"/", client.WithBearerToken("token"), client.WithHeader("X-Tenant", "a"))
+	_, err := c.ServiceProviderConfig(context.Background())
+	var scimErr scimErrors.ScimError
+	if !errors.As(err, &scimErr) || scimErr.Status != http.StatusUnauthorized || scimErr.Detail != "Unauthorized" {
+		t.Errorf("expected a 401 error, got %v", err)
+	}
+	if header.Get("Authorization") != "Bearer token" || header.Get("X-Tenant") != "a" {
+		t.Errorf("unexpected headers: %v", header)
+	}
+}
+
+func newTestClient(t *testing.T) *client.Client {
+	resourceType := scim.ResourceType{
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema:   schema.CoreUserSchema(),
+		SchemaExtensions: []scim.SchemaExtension{
+			{Schema: schema.ExtensionEnterpriseUser()},
+		},
+	}
+	resourceType.Handler = memory.NewHandler(resourceType)
+
+	s, err := scim.NewServer(&scim.ServerArgs{
+		ServiceProviderConfig: &scim.ServiceProviderConfig{
+			MaxResults:       3,
+			SupportETag:      true,
+			SupportFiltering: true,
+			SupportPatch:     true,
+		},
+		ResourceTypes: []scim.ResourceType{resourceType},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return client.NewClient(ts.URL, client.WithHTTPClient(ts.Client()))
+}
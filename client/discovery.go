@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+)
+
+// Discovery describes the capabilities of a service provider, as returned by its "/ServiceProviderConfig",
+// "/ResourceTypes" and "/Schemas" endpoints.
+type Discovery struct {
+	// Config is the configuration of the service provider.
+	Config scim.ServiceProviderConfig
+	// ResourceTypes are the resource types of the service provider, with their schemas resolved. They have no handler.
+	ResourceTypes []scim.ResourceType
+	// Schemas are the schemas of the service provider.
+	Schemas []schema.Schema
+}
+
+// ResourceType returns the resource type with the given name or endpoint, e.g., "User" or "/Users".
+func (d Discovery) ResourceType(name string) (scim.ResourceType, bool) {
+	for _, t := range d.ResourceTypes {
+		if strings.EqualFold(t.Name, name) || strings.EqualFold(t.Endpoint, name) {
+			return t, true
+		}
+	}
+	return scim.ResourceType{}, false
+}
+
+// Schema returns the schema with the given identifier.
+func (d Discovery) Schema(id string) (schema.Schema, bool) {
+	for _, s := range d.Schemas {
+		if strings.EqualFold(s.ID, id) {
+			return s, true
+		}
+	}
+	return schema.Schema{}, false
+}
+
+// Discover loads the service provider configuration, the resource types and the schemas of the service provider. The
+// schemas of the resource types are resolved against the loaded schemas, so that they can be used to validate filters
+// and resources locally.
+func (c *Client) Discover(ctx context.Context) (Discovery, error) {
+	config, err := c.ServiceProviderConfig(ctx)
+	if err != nil {
+		return Discovery{}, err
+	}
+	schemas, err := c.Schemas(ctx)
+	if err != nil {
+		return Discovery{}, err
+	}
+	resourceTypes, err := c.ResourceTypes(ctx)
+	if err != nil {
+		return Discovery{}, err
+	}
+
+	d := Discovery{
+		Config:        config,
+		ResourceTypes: resourceTypes,
+		Schemas:       schemas,
+	}
+	for i, t := range d.ResourceTypes {
+		s, ok := d.Schema(t.Schema.ID)
+		if !ok {
+			return Discovery{}, fmt.Errorf("unknown schema %q of resource type %q", t.Schema.ID, t.Name)
+		}
+		d.ResourceTypes[i].Schema = s
+		for j, e := range t.SchemaExtensions {
+			s, ok := d.Schema(e.Schema.ID)
+			if !ok {
+				return Discovery{}, fmt.Errorf("unknown schema extension %q of resource type %q", e.Schema.ID, t.Name)
+			}
+			d.ResourceTypes[i].SchemaExtensions[j].Schema = s
+		}
+	}
+	return d, nil
+}
+
+// ResourceTypes returns the resource types of the service provider. Only the identifiers of their schemas are known,
+// see Discover to resolve them.
+func (c *Client) ResourceTypes(ctx context.Context) ([]scim.ResourceType, error) {
+	resources, err := c.listAll(ctx, "/ResourceTypes")
+	if err != nil {
+		return nil, err
+	}
+
+	resourceTypes := make([]scim.ResourceType, 0, len(resources))
+	for _, data := range resources {
+		var t struct {
+			ID               optional.String
+			Name             string
+			Description      optional.String
+			Endpoint         string
+			Schema           string
+			SchemaExtensions []struct {
+				Schema   string
+				Required bool
+			}
+		}
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, err
+		}
+		resourceType := scim.ResourceType{
+			ID:          t.ID,
+			Name:        t.Name,
+			Description: t.Description,
+			Endpoint:    t.Endpoint,
+			Schema:      schema.Schema{ID: t.Schema},
+		}
+		for _, e := range t.SchemaExtensions {
+			resourceType.SchemaExtensions = append(resourceType.SchemaExtensions, scim.SchemaExtension{
+				Schema:   schema.Schema{ID: e.Schema},
+				Required: e.Required,
+			})
+		}
+		resourceTypes = append(resourceTypes, resourceType)
+	}
+	return resourceTypes, nil
+}
+
+// Schemas returns the schemas of the service provider.
+func (c *Client) Schemas(ctx context.Context) ([]schema.Schema, error) {
+	resources, err := c.listAll(ctx, "/Schemas")
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := make([]schema.Schema, 0, len(resources))
+	for _, data := range resources {
+		var s schema.Schema
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, s)
+	}
+	return schemas, nil
+}
+
+// ServiceProviderConfig returns the configuration of the service provider.
+func (c *Client) ServiceProviderConfig(ctx context.Context) (scim.ServiceProviderConfig, error) {
+	type supported struct {
+		Supported bool
+	}
+	var raw struct {
+		DocumentationURI optional.String
+		Patch            supported
+		Bulk             struct {
+			Supported      bool
+			MaxOperations  int
+			MaxPayloadSize int
+		}
+		Filter struct {
+			Supported  bool
+			MaxResults int
+		}
+		Sort       supported
+		ETag       supported
+		Pagination struct {
+			Cursor        bool
+			CursorTimeout int
+		}
+		AuthenticationSchemes []struct {
+			Type             scim.AuthenticationType
+			Name             string
+			Description      string
+			SpecURI          optional.String
+			DocumentationURI optional.String
+			Primary          bool
+		}
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/ServiceProviderConfig", nil, nil, &raw); err != nil {
+		return scim.ServiceProviderConfig{}, err
+	}
+
+	config := scim.ServiceProviderConfig{
+		DocumentationURI:        raw.DocumentationURI,
+		CursorTimeout:           raw.Pagination.CursorTimeout,
+		MaxBulkOperations:       raw.Bulk.MaxOperations,
+		MaxBulkPayloadSize:      raw.Bulk.MaxPayloadSize,
+		MaxResults:              raw.Filter.MaxResults,
+		SupportBulk:             raw.Bulk.Supported,
+		SupportCursorPagination: raw.Pagination.Cursor,
+		SupportETag:             raw.ETag.Supported,
+		SupportFiltering:        raw.Filter.Supported,
+		SupportPatch:            raw.Patch.Supported,
+		SupportSorting:          raw.Sort.Supported,
+	}
+	for _, s := range raw.AuthenticationSchemes {
+		config.AuthenticationSchemes = append(config.AuthenticationSchemes, scim.AuthenticationScheme{
+			Type:             s.Type,
+			Name:             s.Name,
+			Description:      s.Description,
+			SpecURI:          s.SpecURI,
+			DocumentationURI: s.DocumentationURI,
+			Primary:          s.Primary,
+		})
+	}
+	return config, nil
+}
+
+// listAll returns the resources of all pages of the list response at the given path.
+func (c *Client) listAll(ctx context.Context, path string) ([]json.RawMessage, error) {
+	var resources []json.RawMessage
+	for {
+		var raw struct {
+			TotalResults int
+			Resources    []json.RawMessage
+		}
+		query := url.Values{"startIndex": []string{strconv.Itoa(len(resources) + 1)}}
+		if _, err := c.do(ctx, http.MethodGet, path, query, nil, &raw); err != nil {
+			return nil, err
+		}
+		resources = append(resources, raw.Resources...)
+		if len(raw.Resources) == 0 || raw.TotalResults <= len(resources) {
+			return resources, nil
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/elimity-com/scim"
+)
+
+// ListParams are the parameters of a list or search request, as described in RFC 7644 Section 3.4.2. Zero values are
+// not sent to the service provider.
+type ListParams struct {
+	// Filter is the filter of the request, e.g., `userName eq "bjensen"`. A filter.Builder can be used to construct a
+	// correctly escaped filter with its String method.
+	Filter string
+	// Attributes is a list of attribute names to return.
+	Attributes []string
+	// ExcludedAttributes is a list of attribute names to exclude.
+	ExcludedAttributes []string
+	// SortBy is the attribute whose value is used to order the resources.
+	SortBy string
+	// SortOrder is the order in which SortBy is applied, either "ascending" or "descending".
+	SortOrder string
+	// StartIndex is the 1-based index of the first resource.
+	StartIndex int
+	// Count is the desired maximum number of resources per page. The service provider decides on the page size if it
+	// is zero.
+	Count int
+}
+
+// query returns the parameters as query parameters of a list request.
+func (p ListParams) query() url.Values {
+	q := url.Values{}
+	if p.Filter != "" {
+		q.Set("filter", p.Filter)
+	}
+	if len(p.Attributes) != 0 {
+		q.Set("attributes", strings.Join(p.Attributes, ","))
+	}
+	if len(p.ExcludedAttributes) != 0 {
+		q.Set("excludedAttributes", strings.Join(p.ExcludedAttributes, ","))
+	}
+	if p.SortBy != "" {
+		q.Set("sortBy", p.SortBy)
+	}
+	if p.SortOrder != "" {
+		q.Set("sortOrder", p.SortOrder)
+	}
+	if p.StartIndex != 0 {
+		q.Set("startIndex", strconv.Itoa(p.StartIndex))
+	}
+	if p.Count != 0 {
+		q.Set("count", strconv.Itoa(p.Count))
+	}
+	return q
+}
+
+// searchRequest returns the parameters as the body of a search request.
+func (p ListParams) searchRequest() map[string]interface{} {
+	raw := map[string]interface{}{
+		"schemas": []string{searchRequestSchema},
+	}
+	if p.Filter != "" {
+		raw["filter"] = p.Filter
+	}
+	if len(p.Attributes) != 0 {
+		raw["attributes"] = p.Attributes
+	}
+	if len(p.ExcludedAttributes) != 0 {
+		raw["excludedAttributes"] = p.ExcludedAttributes
+	}
+	if p.SortBy != "" {
+		raw["sortBy"] = p.SortBy
+	}
+	if p.SortOrder != "" {
+		raw["sortOrder"] = p.SortOrder
+	}
+	if p.StartIndex != 0 {
+		raw["startIndex"] = p.StartIndex
+	}
+	if p.Count != 0 {
+		raw["count"] = p.Count
+	}
+	return raw
+}
+
+// ListResponse is a single page of resources, as described in RFC 7644 Section 3.4.2.
+type ListResponse struct {
+	// TotalResults is the total number of resources that match the request.
+	TotalResults int
+	// ItemsPerPage is the number of resources in the page.
+	ItemsPerPage int
+	// StartIndex is the 1-based index of the first resource in the page.
+	StartIndex int
+	// Resources are the resources in the page.
+	Resources []scim.Resource
+	// RawResources are the JSON representations of the resources in the page, in the same order, as returned by the
+	// service provider.
+	RawResources []json.RawMessage
+}
+
+// Iterator iterates over all resources of a list or search request, fetching the pages as needed.
+//
+//	it := c.Iterate(ctx, "/Users", client.ListParams{Filter: `active eq true`})
+//	for it.Next() {
+//		r := it.Resource()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator struct {
+	ctx    context.Context
+	fetch  func(ctx context.Context, params ListParams) (ListResponse, error)
+	params ListParams
+
+	page    []scim.Resource
+	rawPage []json.RawMessage
+	index   int
+	total   int
+	done    bool
+	err     error
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Next advances the iterator to the next resource, which is then available through Resource. It returns false when
+// there are no more resources or if an error occurred.
+func (it *Iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.index+1 < len(it.page) {
+		it.index++
+		return true
+	}
+	if it.done {
+		return false
+	}
+
+	resp, err := it.fetch(it.ctx, it.params)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.page, it.rawPage, it.index, it.total = resp.Resources, resp.RawResources, 0, resp.TotalResults
+
+	startIndex := it.params.StartIndex
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	it.params.StartIndex = startIndex + len(resp.Resources)
+	// Stop if the page is empty, to not loop forever on a service provider that ignores the start index.
+	it.done = len(resp.Resources) == 0 || resp.TotalResults < it.params.StartIndex
+	return len(it.page) != 0
+}
+
+// Resource returns the current resource.
+func (it *Iterator) Resource() scim.Resource {
+	if it.index < len(it.page) {
+		return it.page[it.index]
+	}
+	return scim.Resource{}
+}
+
+// RawResource returns the JSON representation of the current resource, as returned by the service provider.
+func (it *Iterator) RawResource() json.RawMessage {
+	if it.index < len(it.rawPage) {
+		return it.rawPage[it.index]
+	}
+	return nil
+}
+
+// TotalResults returns the total number of resources as reported by the last fetched page.
+func (it *Iterator) TotalResults() int {
+	return it.total
+}
+
+// Iterate returns an iterator over all resources at the given endpoint that match the given parameters, using list
+// requests.
+func (c *Client) Iterate(ctx context.Context, endpoint string, params ListParams) *Iterator {
+	return &Iterator{
+		ctx: ctx,
+		fetch: func(ctx context.Context, params ListParams) (ListResponse, error) {
+			return c.List(ctx, endpoint, params)
+		},
+		params: params,
+		index:  -1,
+	}
+}
+
+// IterateSearch returns an iterator over all resources at the given endpoint that match the given parameters, using
+// search requests.
+func (c *Client) IterateSearch(ctx context.Context, endpoint string, params ListParams) *Iterator {
+	return &Iterator{
+		ctx: ctx,
+		fetch: func(ctx context.Context, params ListParams) (ListResponse, error) {
+			return c.Search(ctx, endpoint, params)
+		},
+		params: params,
+		index:  -1,
+	}
+}
+
+// List returns a page of the resources at the given endpoint, e.g., "/Users", that match the given parameters. The
+// resources of all resource types are queried if the endpoint is empty.
+func (c *Client) List(ctx context.Context, endpoint string, params ListParams) (ListResponse, error) {
+	return c.list(ctx, http.MethodGet, endpoint, params.query(), nil)
+}
+
+// Search returns a page of the resources at the given endpoint that match the given parameters, using the
+// "/.search" endpoint as described in RFC 7644 Section 3.4.3. This avoids sending the filter in the URL.
+func (c *Client) Search(ctx context.Context, endpoint string, params ListParams) (ListResponse, error) {
+	return c.list(ctx, http.MethodPost, endpoint+"/.search", nil, params.searchRequest())
+}
+
+// list sends a list or search request and decodes the list response.
+func (c *Client) list(ctx context.Context, method, path string, query url.Values, body interface{}) (ListResponse, error) {
+	var raw struct {
+		TotalResults int
+		ItemsPerPage int
+		StartIndex   int
+		Resources    []json.RawMessage
+	}
+	if _, err := c.do(ctx, method, path, query, body, &raw); err != nil {
+		return ListResponse{}, err
+	}
+
+	resp := ListResponse{
+		TotalResults: raw.TotalResults,
+		ItemsPerPage: raw.ItemsPerPage,
+		StartIndex:   raw.StartIndex,
+	}
+	for _, data := range raw.Resources {
+		var attributes map[string]interface{}
+		if err := decode(data, &attributes); err != nil {
+			return ListResponse{}, fmt.Errorf("invalid resource in response from %s %s: %w", method, path, err)
+		}
+		r, err := resourceFromAttributes(attributes)
+		if err != nil {
+			return ListResponse{}, err
+		}
+		resp.Resources = append(resp.Resources, r)
+		resp.RawResources = append(resp.RawResources, data)
+	}
+	return resp, nil
+}
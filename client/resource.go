@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/elimity-com/scim"
+	internal "github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+// formatPath returns the text of the given PATCH path. Value expressions are formatted canonically, see filter.Format.
+func formatPath(p filter.Path) string {
+	s := p.AttributePath.String()
+	if p.ValueExpression != nil {
+		s += "[" + internal.Format(p.ValueExpression) + "]"
+	}
+	if p.SubAttribute != nil {
+		s += "." + *p.SubAttribute
+	}
+	return s
+}
+
+// parseTime parses the given "dateTime" value, if present.
+func parseTime(value interface{}) (*time.Time, error) {
+	s, ok := value.(string)
+	if !ok || s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// patchRequest returns the body of a PATCH request with the given operations.
+func patchRequest(operations []scim.PatchOperation) map[string]interface{} {
+	ops := make([]map[string]interface{}, 0, len(operations))
+	for _, op := range operations {
+		raw := map[string]interface{}{
+			"op": op.Op,
+		}
+		if op.Path != nil {
+			raw["path"] = formatPath(*op.Path)
+		}
+		if op.Value != nil {
+			raw["value"] = op.Value
+		}
+		ops = append(ops, raw)
+	}
+	return map[string]interface{}{
+		"schemas":    []string{patchOpSchema},
+		"Operations": ops,
+	}
+}
+
+// resourceFromAttributes converts the JSON representation of a resource into a resource. The common attributes "id",
+// "externalId" and "meta" and the "schemas" attribute are removed from the attributes.
+func resourceFromAttributes(attributes map[string]interface{}) (scim.Resource, error) {
+	var r scim.Resource
+	r.Attributes = make(scim.ResourceAttributes, len(attributes))
+	for k, v := range attributes {
+		r.Attributes[k] = v
+	}
+	delete(r.Attributes, "schemas")
+
+	if id, ok := r.Attributes[schema.CommonAttributeID].(string); ok {
+		r.ID = id
+	}
+	delete(r.Attributes, schema.CommonAttributeID)
+
+	if externalID, ok := r.Attributes[schema.CommonAttributeExternalID].(string); ok {
+		r.ExternalID = optional.NewString(externalID)
+	}
+	delete(r.Attributes, schema.CommonAttributeExternalID)
+
+	if meta, ok := r.Attributes[schema.CommonAttributeMeta].(map[string]interface{}); ok {
+		created, err := parseTime(meta["created"])
+		if err != nil {
+			return scim.Resource{}, fmt.Errorf("invalid meta.created: %w", err)
+		}
+		lastModified, err := parseTime(meta["lastModified"])
+		if err != nil {
+			return scim.Resource{}, fmt.Errorf("invalid meta.lastModified: %w", err)
+		}
+		version, _ := meta["version"].(string)
+		r.Meta = scim.Meta{
+			Created:      created,
+			LastModified: lastModified,
+			Version:      version,
+		}
+	}
+	delete(r.Attributes, schema.CommonAttributeMeta)
+	return r, nil
+}
+
+// resourcePath returns the path of the resource with the given identifier at the given endpoint, e.g., "/Users".
+func resourcePath(endpoint, id string) string {
+	return endpoint + "/" + url.PathEscape(id)
+}
+
+// Create creates a resource with the given attributes at the given endpoint, e.g., "/Users". The attributes should
+// contain the "schemas" attribute, as required by RFC 7643 Section 3.
+func (c *Client) Create(ctx context.Context, endpoint string, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	return c.resource(ctx, http.MethodPost, endpoint, attributes)
+}
+
+// Delete deletes the resource with the given identifier at the given endpoint.
+func (c *Client) Delete(ctx context.Context, endpoint, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, resourcePath(endpoint, id), nil, nil, nil)
+	return err
+}
+
+// Get returns the resource with the given identifier at the given endpoint.
+func (c *Client) Get(ctx context.Context, endpoint, id string) (scim.Resource, error) {
+	return c.resource(ctx, http.MethodGet, resourcePath(endpoint, id), nil)
+}
+
+// Patch applies the given operations to the resource with the given identifier at the given endpoint. It returns the
+// updated resource, or a resource with only its identifier if the service provider responded with "204 No Content".
+func (c *Client) Patch(ctx context.Context, endpoint, id string, operations []scim.PatchOperation) (scim.Resource, error) {
+	r, err := c.resource(ctx, http.MethodPatch, resourcePath(endpoint, id), patchRequest(operations))
+	if err != nil {
+		return scim.Resource{}, err
+	}
+	if r.ID == "" {
+		r.ID = id
+	}
+	return r, nil
+}
+
+// Replace replaces all attributes of the resource with the given identifier at the given endpoint.
+func (c *Client) Replace(ctx context.Context, endpoint, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	return c.resource(ctx, http.MethodPut, resourcePath(endpoint, id), attributes)
+}
+
+// resource sends a request with the given method and body to the given path and decodes the resource in the response.
+// The version of the resource falls back to the "ETag" header of the response.
+func (c *Client) resource(ctx context.Context, method, path string, body interface{}) (scim.Resource, error) {
+	var raw map[string]interface{}
+	header, err := c.do(ctx, method, path, nil, body, &raw)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+	r, err := resourceFromAttributes(raw)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+	if r.Meta.Version == "" {
+		r.Meta.Version = header.Get("ETag")
+	}
+	return r, nil
+}
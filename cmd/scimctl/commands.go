@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/client"
+	"github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+)
+
+// patchOpSchema is the schema of PATCH requests, as defined in RFC 7644 Section 3.5.2.
+const patchOpSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+
+// decode decodes the given JSON data into the given value, numbers are decoded as json.Number.
+func decode(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// listFlags defines the flags of a list or search command on the given flag set.
+func listFlags(fs *flag.FlagSet, params *client.ListParams, all *bool) *flag.FlagSet {
+	fs.StringVar(&params.Filter, "filter", "", "the `filter` of the request, which is validated against the schemas of the resource type")
+	fs.Var(listFlag{&params.Attributes}, "attributes", "a comma-separated list of `attributes` to return")
+	fs.Var(listFlag{&params.ExcludedAttributes}, "excluded-attributes", "a comma-separated list of `attributes` to exclude")
+	fs.StringVar(&params.SortBy, "sort-by", "", "the `attribute` to sort by")
+	fs.StringVar(&params.SortOrder, "sort-order", "", "the sort `order`, either ascending or descending")
+	fs.IntVar(&params.StartIndex, "start-index", 0, "the 1-based `index` of the first resource")
+	fs.IntVar(&params.Count, "count", 0, "the maximum `number` of resources per page")
+	fs.BoolVar(all, "all", false, "fetch all pages")
+	return fs
+}
+
+// printAttributes prints the given attributes and their sub-attributes, indented with the given prefix.
+func printAttributes(w io.Writer, attributes schema.Attributes, indent string) {
+	for _, attr := range attributes {
+		var properties []string
+		if attr.MultiValued() {
+			properties = append(properties, "multi-valued")
+		}
+		if attr.Required() {
+			properties = append(properties, "required")
+		}
+		properties = append(properties, attr.Mutability())
+		_, _ = fmt.Fprintf(w, "%s%s\t%s\t%s\n", indent, attr.Name(), attr.AttributeType(), strings.Join(properties, ", "))
+		printAttributes(w, attr.SubAttributes(), indent+"  ")
+	}
+}
+
+// resourcePath returns the path of the resource with the given identifier of the given resource type.
+func resourcePath(resourceType scim.ResourceType, id string) string {
+	return resourceType.Endpoint + "/" + url.PathEscape(id)
+}
+
+// validateFilter validates the given filter against the schemas of the given resource type.
+func validateFilter(f string, resourceType scim.ResourceType) error {
+	var extensions []schema.Schema
+	for _, e := range resourceType.SchemaExtensions {
+		extensions = append(extensions, e.Schema)
+	}
+	validator, err := filter.NewValidator(f, schema.WithCommonAttributes(resourceType.Schema), extensions...)
+	if err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	if err := validator.Validate(); err != nil {
+		return fmt.Errorf("invalid filter for resource type %s: %w", resourceType.Name, err)
+	}
+	return nil
+}
+
+// app executes the commands of scimctl.
+type app struct {
+	client *client.Client
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	discovery *client.Discovery
+}
+
+// create creates a resource from a JSON file.
+func (a *app) create(ctx context.Context, args []string) error {
+	positional, err := parseArgs(a.flagSet("create"), args, "type", "file")
+	if err != nil {
+		return err
+	}
+	resourceType, err := a.resourceType(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	attributes, err := a.readResource(positional[1], resourceType)
+	if err != nil {
+		return err
+	}
+	raw, err := a.client.Do(ctx, http.MethodPost, resourceType.Endpoint, nil, attributes)
+	if err != nil {
+		return err
+	}
+	return a.print(raw)
+}
+
+// delete deletes a resource.
+func (a *app) delete(ctx context.Context, args []string) error {
+	positional, err := parseArgs(a.flagSet("delete"), args, "type", "id")
+	if err != nil {
+		return err
+	}
+	resourceType, err := a.resourceType(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	if err := a.client.Delete(ctx, resourceType.Endpoint, positional[1]); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(a.stdout, "deleted %s %s\n", resourceType.Name, positional[1])
+	return err
+}
+
+// discover prints the service provider config, the resource types and the schemas of the service provider.
+func (a *app) discover(ctx context.Context, args []string) error {
+	fs := a.flagSet("discover")
+	verbose := fs.Bool("v", false, "print the attributes of the schemas")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	d, err := a.loadDiscovery(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	config := d.Config
+	_, _ = fmt.Fprintln(w, "Service provider config:")
+	_, _ = fmt.Fprintf(w, "  patch\t%t\n", config.SupportPatch)
+	_, _ = fmt.Fprintf(w, "  bulk\t%t\t(max %d operations, %d bytes)\n", config.SupportBulk, config.MaxBulkOperations, config.MaxBulkPayloadSize)
+	_, _ = fmt.Fprintf(w, "  filter\t%t\t(max %d results)\n", config.SupportFiltering, config.MaxResults)
+	_, _ = fmt.Fprintf(w, "  sort\t%t\n", config.SupportSorting)
+	_, _ = fmt.Fprintf(w, "  etag\t%t\n", config.SupportETag)
+	_, _ = fmt.Fprintf(w, "  cursor pagination\t%t\n", config.SupportCursorPagination)
+	for _, s := range config.AuthenticationSchemes {
+		primary := ""
+		if s.Primary {
+			primary = ", primary"
+		}
+		_, _ = fmt.Fprintf(w, "  authentication\t%s\t(%s%s)\n", s.Type, s.Name, primary)
+	}
+
+	_, _ = fmt.Fprintln(w, "\nResource types:")
+	for _, t := range d.ResourceTypes {
+		_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\n", t.Name, t.Endpoint, t.Schema.ID)
+		for _, e := range t.SchemaExtensions {
+			required := "optional"
+			if e.Required {
+				required = "required"
+			}
+			_, _ = fmt.Fprintf(w, "  \t\t%s\t(%s extension)\n", e.Schema.ID, required)
+		}
+	}
+
+	_, _ = fmt.Fprintln(w, "\nSchemas:")
+	for _, s := range d.Schemas {
+		_, _ = fmt.Fprintf(w, "  %s\t%s\t%d attributes\n", s.ID, s.Name.Value(), len(s.Attributes))
+		if *verbose {
+			printAttributes(w, s.Attributes, "    ")
+		}
+	}
+	return w.Flush()
+}
+
+// flagSet returns a new flag set of the command with the given name, which prints to the standard error.
+func (a *app) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	return fs
+}
+
+// get prints a resource.
+func (a *app) get(ctx context.Context, args []string) error {
+	positional, err := parseArgs(a.flagSet("get"), args, "type", "id")
+	if err != nil {
+		return err
+	}
+	resourceType, err := a.resourceType(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	raw, err := a.client.Do(ctx, http.MethodGet, resourcePath(resourceType, positional[1]), nil, nil)
+	if err != nil {
+		return err
+	}
+	return a.print(raw)
+}
+
+// list lists resources with GET requests.
+func (a *app) list(ctx context.Context, args []string) error {
+	return a.query(ctx, "list", args, a.client.List, a.client.Iterate)
+}
+
+// loadDiscovery returns the discovery document of the service provider, which is loaded once.
+func (a *app) loadDiscovery(ctx context.Context) (client.Discovery, error) {
+	if a.discovery == nil {
+		d, err := a.client.Discover(ctx)
+		if err != nil {
+			return client.Discovery{}, err
+		}
+		a.discovery = &d
+	}
+	return *a.discovery, nil
+}
+
+// patch patches a resource with the operations of a JSON file.
+func (a *app) patch(ctx context.Context, args []string) error {
+	positional, err := parseArgs(a.flagSet("patch"), args, "type", "id", "file")
+	if err != nil {
+		return err
+	}
+	resourceType, err := a.resourceType(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	data, err := a.readFile(positional[2])
+	if err != nil {
+		return err
+	}
+
+	var raw []struct {
+		Op    string
+		Path  *string
+		Value interface{}
+	}
+	// The file contains either a PatchOp message or its operations.
+	if trimmed := bytes.TrimSpace(data); len(trimmed) != 0 && trimmed[0] == '{' {
+		var request struct {
+			Operations json.RawMessage
+		}
+		if err := decode(data, &request); err != nil {
+			return fmt.Errorf("invalid patch request: %w", err)
+		}
+		data = request.Operations
+	}
+	if err := decode(data, &raw); err != nil {
+		return fmt.Errorf("invalid patch operations: %w", err)
+	}
+
+	var extensions []schema.Schema
+	for _, e := range resourceType.SchemaExtensions {
+		extensions = append(extensions, e.Schema)
+	}
+	// The operations are validated, but sent as given, so that their paths are not reformatted.
+	operations := make([]map[string]interface{}, 0, len(raw))
+	for i, op := range raw {
+		operation := map[string]interface{}{
+			"op": strings.ToLower(op.Op),
+		}
+		if op.Value != nil {
+			operation["value"] = op.Value
+		}
+		if op.Path != nil {
+			validator, err := filter.NewPathValidator(*op.Path, schema.WithCommonAttributes(resourceType.Schema), extensions...)
+			if err == nil {
+				err = validator.Validate()
+			}
+			if err != nil {
+				return fmt.Errorf("invalid path %q of operation %d: %w", *op.Path, i+1, err)
+			}
+			operation["path"] = *op.Path
+		}
+		operations = append(operations, operation)
+	}
+
+	resp, err := a.client.Do(ctx, http.MethodPatch, resourcePath(resourceType, positional[1]), nil, map[string]interface{}{
+		"schemas":    []string{patchOpSchema},
+		"Operations": operations,
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp) == 0 {
+		_, err := fmt.Fprintf(a.stdout, "patched %s %s, no content\n", resourceType.Name, positional[1])
+		return err
+	}
+	return a.print(resp)
+}
+
+// print prints the given value, e.g., a response body, as indented JSON.
+func (a *app) print(v interface{}) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(a.stdout, string(raw))
+	return err
+}
+
+// query lists or searches resources with the given functions.
+func (a *app) query(
+	ctx context.Context, name string, args []string,
+	page func(context.Context, string, client.ListParams) (client.ListResponse, error),
+	iterate func(context.Context, string, client.ListParams) *client.Iterator,
+) error {
+	var (
+		params client.ListParams
+		all    bool
+	)
+	positional, err := parseArgs(listFlags(a.flagSet(name), &params, &all), args, "type")
+	if err != nil {
+		return err
+	}
+	resourceType, err := a.resourceType(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	if params.Filter != "" {
+		if err := validateFilter(params.Filter, resourceType); err != nil {
+			return err
+		}
+	}
+
+	resources := []json.RawMessage{}
+	if !all {
+		resp, err := page(ctx, resourceType.Endpoint, params)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, resp.RawResources...)
+		return a.print(map[string]interface{}{
+			"totalResults": resp.TotalResults,
+			"itemsPerPage": resp.ItemsPerPage,
+			"startIndex":   resp.StartIndex,
+			"Resources":    resources,
+		})
+	}
+
+	it := iterate(ctx, resourceType.Endpoint, params)
+	for it.Next() {
+		resources = append(resources, it.RawResource())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return a.print(map[string]interface{}{
+		"totalResults": it.TotalResults(),
+		"Resources":    resources,
+	})
+}
+
+// readFile reads the given file, or the standard input if the name is "-".
+func (a *app) readFile(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(a.stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// readResource reads the attributes of a resource from the given JSON file. The "schemas" attribute is added if it is
+// absent.
+func (a *app) readResource(name string, resourceType scim.ResourceType) (scim.ResourceAttributes, error) {
+	data, err := a.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var attributes scim.ResourceAttributes
+	if err := decode(data, &attributes); err != nil {
+		return nil, fmt.Errorf("invalid resource: %w", err)
+	}
+	if _, ok := attributes["schemas"]; !ok {
+		schemas := []string{resourceType.Schema.ID}
+		for _, e := range resourceType.SchemaExtensions {
+			if _, ok := attributes[e.Schema.ID]; ok {
+				schemas = append(schemas, e.Schema.ID)
+			}
+		}
+		attributes["schemas"] = schemas
+	}
+	return attributes, nil
+}
+
+// replace replaces a resource with a JSON file.
+func (a *app) replace(ctx context.Context, args []string) error {
+	positional, err := parseArgs(a.flagSet("replace"), args, "type", "id", "file")
+	if err != nil {
+		return err
+	}
+	resourceType, err := a.resourceType(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	attributes, err := a.readResource(positional[2], resourceType)
+	if err != nil {
+		return err
+	}
+	raw, err := a.client.Do(ctx, http.MethodPut, resourcePath(resourceType, positional[1]), nil, attributes)
+	if err != nil {
+		return err
+	}
+	return a.print(raw)
+}
+
+// resourceType returns the resource type with the given name or endpoint.
+func (a *app) resourceType(ctx context.Context, name string) (scim.ResourceType, error) {
+	d, err := a.loadDiscovery(ctx)
+	if err != nil {
+		return scim.ResourceType{}, err
+	}
+	if t, ok := d.ResourceType(name); ok {
+		return t, nil
+	}
+	if t, ok := d.ResourceType("/" + name); ok {
+		return t, nil
+	}
+	var names []string
+	for _, t := range d.ResourceTypes {
+		names = append(names, t.Name)
+	}
+	return scim.ResourceType{}, fmt.Errorf("unknown resource type %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// search searches resources with POST requests.
+func (a *app) search(ctx context.Context, args []string) error {
+	return a.query(ctx, "search", args, a.client.Search, a.client.IterateSearch)
+}
+
+// listFlag is a comma-separated list flag.
+type listFlag struct {
+	values *[]string
+}
+
+// Set implements the flag.Value interface.
+func (l listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l.values = append(*l.values, v)
+		}
+	}
+	return nil
+}
+
+// String implements the flag.Value interface.
+func (l listFlag) String() string {
+	if l.values == nil {
+		return ""
+	}
+	return strings.Join(*l.values, ",")
+}
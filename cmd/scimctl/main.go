@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/elimity-com/scim/client"
+	scimErrors "github.com/elimity-com/scim/errors"
+)
+
+const usage = `Usage: scimctl [flags] <command> [arguments]
+
+Commands:
+  discover [-v]               show the service provider config, resource types and schemas
+  list <type> [flags]         list resources, e.g., list Users -filter 'userName sw "b"'
+  search <type> [flags]       search resources with POST /.search, same flags as list
+  get <type> <id>             get a resource
+  create <type> <file>        create a resource from a JSON file ("-" for stdin)
+  replace <type> <id> <file>  replace a resource with a JSON file
+  patch <type> <id> <file>    patch a resource with a JSON file containing a PatchOp or its operations
+  delete <type> <id>          delete a resource
+
+The <type> is the name or the endpoint of a resource type, e.g., "User" or "/Users".
+
+Flags:
+`
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// parseArgs parses the given arguments with the given flag set, allowing flags after the positional arguments, and
+// returns the positional arguments. The number of positional arguments must equal the number of the given names.
+func parseArgs(fs *flag.FlagSet, args []string, names ...string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != len(names) {
+		return nil, fmt.Errorf("expected arguments <%s>", strings.Join(names, "> <"))
+	}
+	return positional, nil
+}
+
+// printError prints the given error, SCIM errors are printed with their status, type and detail.
+func printError(w io.Writer, err error) {
+	var scimErr scimErrors.ScimError
+	if !errors.As(err, &scimErr) {
+		_, _ = fmt.Fprintf(w, "error: %v\n", err)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "SCIM error: %d %s\n", scimErr.Status, http.StatusText(scimErr.Status))
+	if scimErr.ScimType != "" {
+		_, _ = fmt.Fprintf(w, "  scimType: %s\n", scimErr.ScimType)
+	}
+	if scimErr.Detail != "" {
+		_, _ = fmt.Fprintf(w, "  detail:   %s\n", scimErr.Detail)
+	}
+}
+
+// run runs scimctl with the given arguments and returns its exit code.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("scimctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	var (
+		baseURL = fs.String("url", os.Getenv("SCIM_URL"), "the base `URL` of the service provider, e.g., https://example.com/scim/v2")
+		token   = fs.String("token", os.Getenv("SCIM_TOKEN"), "the bearer `token` to authenticate with")
+		timeout = fs.Duration("timeout", 30*time.Second, "the timeout of each request")
+		headers headerFlags
+	)
+	fs.Var(&headers, "header", "an additional `header` of each request, e.g., \"X-Tenant: acme\", can be repeated")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	commands := map[string]func(a *app, ctx context.Context, args []string) error{
+		"create":   (*app).create,
+		"delete":   (*app).delete,
+		"discover": (*app).discover,
+		"get":      (*app).get,
+		"list":     (*app).list,
+		"patch":    (*app).patch,
+		"replace":  (*app).replace,
+		"search":   (*app).search,
+	}
+	command, ok := commands[fs.Arg(0)]
+	if !ok {
+		if fs.NArg() != 0 {
+			_, _ = fmt.Fprintf(stderr, "unknown command %q\n", fs.Arg(0))
+		}
+		fs.Usage()
+		return 2
+	}
+	if *baseURL == "" {
+		_, _ = fmt.Fprintln(stderr, "the base URL is required, use -url or SCIM_URL")
+		return 2
+	}
+
+	opts := []client.Option{
+		client.WithHTTPClient(&http.Client{Timeout: *timeout}),
+	}
+	if *token != "" {
+		opts = append(opts, client.WithBearerToken(*token))
+	}
+	for _, h := range headers {
+		parts := strings.SplitN(h, ":", 2)
+		opts = append(opts, client.WithHeader(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])))
+	}
+
+	a := &app{
+		client: client.NewClient(*baseURL, opts...),
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}
+	if err := command(a, ctx, fs.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		printError(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// headerFlags collects the values of a repeated header flag.
+type headerFlags []string
+
+// Set implements the flag.Value interface.
+func (h *headerFlags) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("invalid header %q, expected \"name: value\"", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// String implements the flag.Value interface.
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/memory"
+	"github.com/elimity-com/scim/schema"
+)
+
+func TestRun(t *testing.T) {
+	url := newTestServer(t)
+
+	var stdout bytes.Buffer
+	runTest(t, 0, &stdout, "-url", url, "discover", "-v")
+	for _, s := range []string{"User", "/Users", "urn:ietf:params:scim:schemas:core:2.0:User", "userName"} {
+		if !strings.Contains(stdout.String(), s) {
+			t.Errorf("expected discover output to contain %q, got:\n%s", s, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	stdin := strings.NewReader(`{"userName": "bjensen", "displayName": "Babs"}`)
+	if code := run(context.Background(), []string{"-url", url, "create", "User", "-"}, stdin, &stdout, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := created["id"].(string)
+	if id == "" || created["userName"] != "bjensen" {
+		t.Fatalf("unexpected resource: %v", created)
+	}
+	// The response is printed as is, including the attributes that are not part of scim.Resource.
+	meta, _ := created["meta"].(map[string]interface{})
+	location, _ := meta["location"].(string)
+	if created["schemas"] == nil || meta["resourceType"] != "User" || !strings.HasSuffix(location, "Users/"+id) {
+		t.Errorf("expected the schemas and meta of the response, got: %v", created)
+	}
+
+	stdout.Reset()
+	runTest(t, 0, &stdout, "-url", url, "list", "Users", "--filter", `userName sw "bj"`)
+	var list struct {
+		TotalResults int
+		Resources    []map[string]interface{}
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.TotalResults != 1 || len(list.Resources) != 1 || list.Resources[0]["id"] != id || list.Resources[0]["schemas"] == nil {
+		t.Errorf("unexpected list response: %s", stdout.String())
+	}
+
+	stdout.Reset()
+	stdin = strings.NewReader(`{"Operations": [{"op": "replace", "path": "displayName", "value": "Barbara"}]}`)
+	if code := run(context.Background(), []string{"-url", url, "patch", "User", id, "-"}, stdin, &stdout, &bytes.Buffer{}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), `"schemas"`) {
+		t.Errorf("expected the patched resource, got:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	runTest(t, 0, &stdout, "-url", url, "get", "User", id)
+	if !strings.Contains(stdout.String(), `"displayName": "Barbara"`) {
+		t.Errorf("expected patched resource, got:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	runTest(t, 0, &stdout, "-url", url, "delete", "User", id)
+	runTest(t, 0, &stdout, "-url", url, "search", "User", "-all")
+	if !strings.Contains(stdout.String(), `"totalResults": 0`) {
+		t.Errorf("expected no resources, got:\n%s", stdout.String())
+	}
+}
+
+func TestRun_errors(t *testing.T) {
+	url := newTestServer(t)
+
+	for _, test := range []struct {
+		name     string
+		args     []string
+		code     int
+		expected string
+	}{
+		{name: "no command", args: []string{"-url", url}, code: 2, expected: "Usage: scimctl"},
+		{name: "unknown command", args: []string{"-url", url, "foo"}, code: 2, expected: `unknown command "foo"`},
+		{name: "unknown resource type", args: []string{"-url", url, "get", "Groups", "x"}, code: 1, expected: `unknown resource type "Groups"`},
+		{name: "missing arguments", args: []string{"-url", url, "get", "User"}, code: 1, expected: "expected arguments <type> <id>"},
+		{name: "invalid filter", args: []string{"-url", url, "list", "User", "-filter", `userName eq`}, code: 1, expected: "invalid filter"},
+		{name: "unknown filter attribute", args: []string{"-url", url, "list", "User", "-filter", `foo eq "bar"`}, code: 1, expected: "invalid filter for resource type User"},
+		{name: "not found", args: []string{"-url", url, "get", "User", "unknown"}, code: 1, expected: "SCIM error: 404 Not Found"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			if code := run(context.Background(), test.args, strings.NewReader(""), &bytes.Buffer{}, &stderr); code != test.code {
+				t.Errorf("expected exit code %d, got %d", test.code, code)
+			}
+			if !strings.Contains(stderr.String(), test.expected) {
+				t.Errorf("expected %q in:\n%s", test.expected, stderr.String())
+			}
+		})
+	}
+}
+
+func newTestServer(t *testing.T) string {
+	resourceType := scim.ResourceType{
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema:   schema.CoreUserSchema(),
+	}
+	resourceType.Handler = memory.NewHandler(resourceType)
+	s, err := scim.NewServer(&scim.ServerArgs{
+		ServiceProviderConfig: &scim.ServiceProviderConfig{SupportFiltering: true, SupportPatch: true},
+		ResourceTypes:         []scim.ResourceType{resourceType},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func runTest(t *testing.T, expected int, stdout *bytes.Buffer, args ...string) {
+	t.Helper()
+	var stderr bytes.Buffer
+	if code := run(context.Background(), args, strings.NewReader(""), stdout, &stderr); code != expected {
+		t.Fatalf("expected exit code %d, got %d: %s", expected, code, stderr.String())
+	}
+}
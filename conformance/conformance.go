@@ -0,0 +1,218 @@
+package conformance
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/client"
+)
+
+// newNonce returns a random string that distinguishes the resources of a run from the resources of other runs.
+func newNonce() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return "conformance" + hex.EncodeToString(b)
+}
+
+// skipf returns an error that marks a requirement as skipped, with the given reason.
+func skipf(format string, args ...interface{}) error {
+	return skipError{reason: fmt.Sprintf(format, args...)}
+}
+
+// Generator returns the attributes of the n-th resource (starting at zero) that the suite creates during a run. The
+// attributes of different resources must differ in the values of their attributes with a "server" or "global"
+// uniqueness, also across runs if the service provider is not reset in between.
+type Generator func(n int) scim.ResourceAttributes
+
+// Option configures a suite.
+type Option func(*Suite)
+
+// WithBearerToken authenticates all requests with the given bearer token.
+func WithBearerToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithGenerator sets the generator of the resources of the resource type with the given name. By default, resources
+// are generated from the schema of the resource type: they contain a unique value for all required attributes and for
+// all optional, single-valued attributes of type string, boolean, decimal, integer and dateTime.
+func WithGenerator(resourceType string, generate Generator) Option {
+	return func(s *Suite) {
+		s.generators[strings.ToLower(resourceType)] = generate
+	}
+}
+
+// WithHTTPClient sets the HTTP client that is used to send requests. It is ignored by NewHandlerSuite.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(s *Suite) {
+		s.httpClient = httpClient
+	}
+}
+
+// WithHeader adds a header to all requests, e.g., to select a tenant.
+func WithHeader(key, value string) Option {
+	return func(s *Suite) {
+		s.header.Add(key, value)
+	}
+}
+
+// WithResourceTypes limits the resource types that are checked to the ones with the given names. By default, all
+// resource types of the service provider are checked.
+func WithResourceTypes(names ...string) Option {
+	return func(s *Suite) {
+		s.resourceTypes = append(s.resourceTypes, names...)
+	}
+}
+
+// Suite checks whether a SCIM service provider conforms to RFC 7643 and RFC 7644. It checks the discovery endpoints,
+// the status codes, headers and bodies of the CRUD operations, error responses, the filter operators of every attribute
+// type, the semantics of PATCH operations, pagination, sorting and the "schemas" attribute of all messages.
+//
+// A run creates, modifies and deletes resources of every resource type, so it should not be pointed at a service
+// provider with production data. The created resources are deleted at the end of the run.
+type Suite struct {
+	baseURL       string
+	httpClient    *http.Client
+	header        http.Header
+	resourceTypes []string
+	generators    map[string]Generator
+}
+
+// NewHandlerSuite returns a suite that checks the service provider that is served by the given handler, e.g., a
+// scim.Server, without a network connection.
+func NewHandlerSuite(handler http.Handler, opts ...Option) *Suite {
+	s := NewSuite("http://localhost", opts...)
+	s.httpClient = &http.Client{Transport: handlerTransport{handler: handler}}
+	return s
+}
+
+// NewSuite returns a suite that checks the service provider at the given base URL, e.g., "https://example.com/scim/v2".
+func NewSuite(baseURL string, opts ...Option) *Suite {
+	s := &Suite{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		header:     http.Header{},
+		generators: map[string]Generator{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Run checks all requirements and returns the report. Requirements that depend on an optional feature that the service
+// provider does not support according to its service provider config are skipped.
+func (s *Suite) Run(ctx context.Context) Report {
+	r := &run{
+		ctx:   ctx,
+		suite: s,
+		nonce: newNonce(),
+	}
+	r.checkDiscovery()
+
+	var opts []client.Option
+	opts = append(opts, client.WithHTTPClient(s.httpClient))
+	for k, vs := range s.header {
+		for _, v := range vs {
+			opts = append(opts, client.WithHeader(k, v))
+		}
+	}
+	d, err := client.NewClient(s.baseURL, opts...).Discover(ctx)
+	if err != nil {
+		r.skip(requirementCreate, "", fmt.Sprintf("could not discover the resource types: %v", err))
+		return r.report
+	}
+	for _, resourceType := range s.selectResourceTypes(d.ResourceTypes) {
+		r.checkResourceType(resourceType)
+	}
+	return r.report
+}
+
+// selectResourceTypes returns the resource types that are checked.
+func (s *Suite) selectResourceTypes(resourceTypes []scim.ResourceType) []scim.ResourceType {
+	if len(s.resourceTypes) == 0 {
+		return resourceTypes
+	}
+	var selected []scim.ResourceType
+	for _, t := range resourceTypes {
+		for _, name := range s.resourceTypes {
+			if strings.EqualFold(t.Name, name) {
+				selected = append(selected, t)
+			}
+		}
+	}
+	return selected
+}
+
+// run is the state of a single run of a suite.
+type run struct {
+	ctx   context.Context
+	suite *Suite
+	nonce string
+	// config is the decoded service provider config, it is nil if it could not be loaded.
+	config map[string]interface{}
+	report Report
+}
+
+// check checks the given requirement with the given function and adds its result to the report. The function returns
+// nil if the requirement is met, an error created by skipf if the requirement is skipped or any other error if the
+// requirement is not met. It returns whether the requirement is met.
+func (r *run) check(requirement Requirement, resourceType string, f func() error) bool {
+	result := Result{
+		Requirement:  requirement,
+		ResourceType: resourceType,
+		Status:       StatusPass,
+	}
+	err := f()
+	var skip skipError
+	switch {
+	case errors.As(err, &skip):
+		result.Status = StatusSkip
+		result.Detail = skip.reason
+	case err != nil:
+		result.Status = StatusFail
+		result.Detail = err.Error()
+	}
+	r.report.Results = append(r.report.Results, result)
+	return err == nil
+}
+
+// do sends a request to the service provider.
+func (r *run) do(method, path string, query url.Values, body interface{}, header http.Header) (response, error) {
+	return r.suite.do(r.ctx, method, path, query, body, header)
+}
+
+// feature returns the value of the given property of the given feature of the service provider config, e.g.,
+// "supported" of "patch".
+func (r *run) feature(name, property string) interface{} {
+	feature, _ := r.config[name].(map[string]interface{})
+	return feature[property]
+}
+
+// skip adds a skipped result for the given requirement to the report.
+func (r *run) skip(requirement Requirement, resourceType, reason string) {
+	r.check(requirement, resourceType, func() error {
+		return skipf("%s", reason)
+	})
+}
+
+// supported checks whether the service provider config reports that the given feature is supported.
+func (r *run) supported(name string) bool {
+	supported, _ := r.feature(name, "supported").(bool)
+	return supported
+}
+
+// skipError marks a requirement as skipped.
+type skipError struct {
+	reason string
+}
+
+func (e skipError) Error() string {
+	return e.reason
+}
@@ -0,0 +1,147 @@
+package conformance_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/conformance"
+	"github.com/elimity-com/scim/memory"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+)
+
+func TestSuite(t *testing.T) {
+	report := conformance.NewHandlerSuite(newTestServer(t)).Run(context.Background())
+	report.Test(t)
+
+	var skipped []string
+	for _, result := range report.Results {
+		if result.Status == conformance.StatusSkip {
+			skipped = append(skipped, result.Name())
+		}
+	}
+	// Only the requirements for which the schemas have no suitable attributes are skipped.
+	expected := "User/filter.integer,User/filter.decimal,User/filter.dateTime," +
+		"Group/create.uniqueness,Group/patch.remove,Group/patch.add," +
+		"Group/filter.boolean,Group/filter.integer,Group/filter.decimal,Group/filter.dateTime,Group/list.attributes," +
+		"Device/patch.replace"
+	if s := strings.Join(skipped, ","); s != expected {
+		t.Errorf("unexpected skips: %s", s)
+	}
+	if !report.Passed() {
+		t.Errorf("expected the report to pass:\n%s", report)
+	}
+}
+
+func TestSuite_failures(t *testing.T) {
+	server := newTestServer(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A broken service provider that drops the Location header and ignores the count parameter.
+		query := r.URL.Query()
+		query.Del("count")
+		r.URL.RawQuery = query.Encode()
+		server.ServeHTTP(locationDropper{w}, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	report := conformance.NewSuite(ts.URL, conformance.WithResourceTypes("User"), conformance.WithHTTPClient(ts.Client())).Run(context.Background())
+	if report.Passed() {
+		t.Fatal("expected the report to fail")
+	}
+	var failed []string
+	for _, result := range report.Failed() {
+		failed = append(failed, result.Name())
+	}
+	if s := strings.Join(failed, ","); s != "User/create.location,User/list.response,User/list.pagination" {
+		t.Errorf("unexpected failures: %s\n%s", s, report)
+	}
+	for _, result := range report.Results {
+		if strings.HasPrefix(result.Name(), "Group/") || strings.HasPrefix(result.Name(), "Device/") {
+			t.Errorf("unexpected result of unselected resource type: %s", result.Name())
+		}
+	}
+}
+
+func newTestServer(t *testing.T) scim.Server {
+	deviceSchema := schema.Schema{
+		ID:   "urn:example:params:scim:schemas:core:2.0:Device",
+		Name: optional.NewString("Device"),
+		Attributes: []schema.CoreAttribute{
+			schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+				Name:       "serialNumber",
+				CaseExact:  true,
+				Required:   true,
+				Uniqueness: schema.AttributeUniquenessServer(),
+			})),
+			schema.SimpleCoreAttribute(schema.SimpleNumberParams(schema.NumberParams{
+				Name: "ports",
+				Type: schema.AttributeTypeInteger(),
+			})),
+			schema.SimpleCoreAttribute(schema.SimpleNumberParams(schema.NumberParams{
+				Name: "weight",
+				Type: schema.AttributeTypeDecimal(),
+			})),
+			schema.SimpleCoreAttribute(schema.SimpleDateTimeParams(schema.DateTimeParams{
+				Name: "purchased",
+			})),
+			schema.SimpleCoreAttribute(schema.SimpleBooleanParams(schema.BooleanParams{
+				Name: "managed",
+			})),
+		},
+	}
+
+	resourceTypes := []scim.ResourceType{
+		{
+			ID:       optional.NewString("User"),
+			Name:     "User",
+			Endpoint: "/Users",
+			Schema:   schema.CoreUserSchema(),
+			SchemaExtensions: []scim.SchemaExtension{
+				{Schema: schema.ExtensionEnterpriseUser()},
+			},
+		},
+		{
+			ID:       optional.NewString("Group"),
+			Name:     "Group",
+			Endpoint: "/Groups",
+			Schema:   schema.CoreGroupSchema(),
+		},
+		{
+			ID:       optional.NewString("Device"),
+			Name:     "Device",
+			Endpoint: "/Devices",
+			Schema:   deviceSchema,
+		},
+	}
+	for i := range resourceTypes {
+		resourceTypes[i].Handler = memory.NewHandler(resourceTypes[i])
+	}
+
+	s, err := scim.NewServer(&scim.ServerArgs{
+		ServiceProviderConfig: &scim.ServiceProviderConfig{
+			MaxResults:       10,
+			SupportETag:      true,
+			SupportFiltering: true,
+			SupportPatch:     true,
+			SupportSorting:   true,
+		},
+		ResourceTypes: resourceTypes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+type locationDropper struct {
+	http.ResponseWriter
+}
+
+func (w locationDropper) WriteHeader(status int) {
+	w.Header().Del("Location")
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,178 @@
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	resourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	schemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	serviceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// checkDiscovery checks the discovery endpoints of the service provider, as described in RFC 7644 Section 4.
+func (r *run) checkDiscovery() {
+	r.check(requirementServiceProviderConfig, "", r.checkServiceProviderConfig)
+
+	var resourceTypes, schemas []map[string]interface{}
+	r.check(requirementResourceTypes, "", func() error {
+		var err error
+		resourceTypes, err = r.listAll("/ResourceTypes")
+		if err != nil {
+			return err
+		}
+		if len(resourceTypes) == 0 {
+			return fmt.Errorf("expected at least one resource type")
+		}
+		for _, t := range resourceTypes {
+			if !containsString(t["schemas"], resourceTypeSchema) {
+				return fmt.Errorf("expected the schemas of resource type %v to contain %q, got %v", t["name"], resourceTypeSchema, t["schemas"])
+			}
+			for _, name := range []string{"name", "endpoint", "schema"} {
+				if s, ok := t[name].(string); !ok || s == "" {
+					return fmt.Errorf("expected resource type %v to have a %s, got %#v", t["name"], name, t[name])
+				}
+			}
+		}
+		return nil
+	})
+
+	r.check(requirementSchemas, "", func() error {
+		var err error
+		schemas, err = r.listAll("/Schemas")
+		if err != nil {
+			return err
+		}
+		ids := map[string]bool{}
+		for _, s := range schemas {
+			id, _ := s["id"].(string)
+			if id == "" {
+				return fmt.Errorf("expected schema to have an id, got %#v", s["id"])
+			}
+			ids[id] = true
+			attributes, ok := s["attributes"].([]interface{})
+			if !ok {
+				return fmt.Errorf("expected schema %s to have attributes, got %#v", id, s["attributes"])
+			}
+			for _, v := range attributes {
+				attr, _ := v.(map[string]interface{})
+				if _, ok := attr["name"].(string); !ok {
+					return fmt.Errorf("expected the attributes of schema %s to have a name, got %#v", id, v)
+				}
+				if _, ok := attr["type"].(string); !ok {
+					return fmt.Errorf("expected attribute %v of schema %s to have a type, got %#v", attr["name"], id, attr["type"])
+				}
+				if _, ok := attr["multiValued"].(bool); !ok {
+					return fmt.Errorf("expected attribute %v of schema %s to have a boolean multiValued, got %#v", attr["name"], id, attr["multiValued"])
+				}
+			}
+		}
+		for _, t := range resourceTypes {
+			if id, _ := t["schema"].(string); !ids[id] {
+				return fmt.Errorf("expected the schema %s of resource type %v to be listed", id, t["name"])
+			}
+			extensions, _ := t["schemaExtensions"].([]interface{})
+			for _, v := range extensions {
+				extension, _ := v.(map[string]interface{})
+				if id, _ := extension["schema"].(string); !ids[id] {
+					return fmt.Errorf("expected the schema extension %s of resource type %v to be listed", id, t["name"])
+				}
+			}
+		}
+		return nil
+	})
+
+	r.check(requirementDiscoveryByID, "", func() error {
+		if len(resourceTypes) == 0 && len(schemas) == 0 {
+			return skipf("no resource types or schemas were discovered")
+		}
+		for _, t := range resourceTypes {
+			// The id of a resource type is optional, the name is used by most service providers.
+			id, ok := t["id"].(string)
+			if !ok {
+				id, _ = t["name"].(string)
+			}
+			if err := r.checkByID("/ResourceTypes/", id, resourceTypeSchema); err != nil {
+				return err
+			}
+		}
+		for _, s := range schemas {
+			id, _ := s["id"].(string)
+			if err := r.checkByID("/Schemas/", id, schemaSchema); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// checkByID checks whether GET of the given path and id returns a resource with the given id and schema.
+func (r *run) checkByID(path, id, schema string) error {
+	resp, err := r.do(http.MethodGet, path+url.PathEscape(id), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := expectStatus(resp, http.StatusOK); err != nil {
+		return fmt.Errorf("GET %s%s: %v", path, id, err)
+	}
+	if !containsString(resp.body["schemas"], schema) {
+		return fmt.Errorf("GET %s%s: expected the schemas to contain %q, got %v", path, id, schema, resp.body["schemas"])
+	}
+	if resp.body["id"] != id && resp.body["name"] != id {
+		return fmt.Errorf("GET %s%s: expected id %q, got %#v", path, id, id, resp.body["id"])
+	}
+	return nil
+}
+
+// checkServiceProviderConfig checks the service provider config and stores it for the other checks.
+func (r *run) checkServiceProviderConfig() error {
+	resp, err := r.do(http.MethodGet, "/ServiceProviderConfig", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := expectStatus(resp, http.StatusOK); err != nil {
+		return err
+	}
+	if !containsString(resp.body["schemas"], serviceProviderConfigSchema) {
+		return fmt.Errorf("expected the schemas to contain %q, got %v", serviceProviderConfigSchema, resp.body["schemas"])
+	}
+	for _, name := range []string{"patch", "bulk", "filter", "changePassword", "sort", "etag"} {
+		feature, ok := resp.body[name].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected the feature %s to be an object, got %#v", name, resp.body[name])
+		}
+		if _, ok := feature["supported"].(bool); !ok {
+			return fmt.Errorf("expected the feature %s to have a boolean supported, got %#v", name, feature["supported"])
+		}
+	}
+	if _, ok := resp.body["authenticationSchemes"].([]interface{}); !ok {
+		return fmt.Errorf("expected authenticationSchemes to be an array, got %#v", resp.body["authenticationSchemes"])
+	}
+	r.config = resp.body
+	return nil
+}
+
+// listAll returns all resources of the list response of the given discovery endpoint, which may be paginated.
+func (r *run) listAll(path string) ([]map[string]interface{}, error) {
+	var all []map[string]interface{}
+	for {
+		query := url.Values{}
+		if len(all) != 0 {
+			query.Set("startIndex", fmt.Sprint(len(all)+1))
+		}
+		resp, err := r.do(http.MethodGet, path, query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		resources, err := expectListResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, resources...)
+		if total, _ := integer(resp.body["totalResults"]); len(resources) == 0 || len(all) >= total {
+			return all, nil
+		}
+	}
+}
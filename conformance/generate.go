@@ -0,0 +1,92 @@
+package conformance
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/schema"
+)
+
+// defaultGenerator returns the generator of the given resource type that is used if no generator was configured, see
+// WithGenerator. The string values of the generated resources start with the given nonce.
+func defaultGenerator(resourceType scim.ResourceType, nonce string) Generator {
+	return func(n int) scim.ResourceAttributes {
+		schemas := []interface{}{resourceType.Schema.ID}
+		attributes := generateAttributes(resourceType.Schema.Attributes, nonce, n)
+		for _, extension := range resourceType.SchemaExtensions {
+			if !extension.Required {
+				continue
+			}
+			schemas = append(schemas, extension.Schema.ID)
+			attributes[extension.Schema.ID] = generateAttributes(extension.Schema.Attributes, nonce, n)
+		}
+		attributes["schemas"] = schemas
+		return attributes
+	}
+}
+
+// filterable checks whether the given attribute is a simple attribute of a type with an ordering, or a boolean.
+func filterable(attr schema.CoreAttribute) bool {
+	switch attr.AttributeType() {
+	case "string", "boolean", "integer", "decimal", "dateTime":
+		return true
+	default:
+		return false
+	}
+}
+
+// generateAttributes returns the generated values of the given attributes.
+func generateAttributes(attributes schema.Attributes, nonce string, n int) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, attr := range attributes {
+		if v, ok := generateValue(attr, nonce, n); ok {
+			values[attr.Name()] = v
+		}
+	}
+	return values
+}
+
+// generateValue returns the value of the given attribute of the n-th generated resource. Values are generated for all
+// required attributes and for all optional, single-valued attributes of which the type can be filtered on.
+func generateValue(attr schema.CoreAttribute, nonce string, n int) (interface{}, bool) {
+	if attr.Mutability() == "readOnly" {
+		return nil, false
+	}
+	if !attr.Required() && (attr.MultiValued() || attr.Returned() == "never" || !filterable(attr)) {
+		return nil, false
+	}
+
+	var value interface{}
+	switch attr.AttributeType() {
+	case "complex":
+		values := generateAttributes(attr.SubAttributes(), nonce, n)
+		if len(values) == 0 {
+			return nil, false
+		}
+		value = values
+	case "boolean":
+		value = n%2 == 0
+	case "integer":
+		value = n + 1
+	case "decimal":
+		value = float64(n) + 0.5
+	case "dateTime":
+		value = time.Date(2020, time.January, 1+n, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	case "binary":
+		value = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s-%d", nonce, n)))
+	case "reference":
+		value = fmt.Sprintf("https://example.com/%s/%d", nonce, n)
+	default:
+		if canonical := attr.CanonicalValues(); len(canonical) != 0 {
+			value = canonical[n%len(canonical)]
+		} else {
+			value = fmt.Sprintf("%s-%d-%s", nonce, n, attr.Name())
+		}
+	}
+	if attr.MultiValued() {
+		return []interface{}{value}, true
+	}
+	return value, true
+}
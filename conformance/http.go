@@ -0,0 +1,189 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	contentType         = "application/scim+json"
+	errorSchema         = "urn:ietf:params:scim:api:messages:2.0:Error"
+	listResponseSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	patchOpSchema       = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	searchRequestSchema = "urn:ietf:params:scim:api:messages:2.0:SearchRequest"
+)
+
+// containsString checks whether the given JSON array contains the given string.
+func containsString(values interface{}, s string) bool {
+	list, _ := values.([]interface{})
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// expectError checks whether the given response is a SCIM error with the given status and, if not empty, the given
+// SCIM type, as described in RFC 7644 Section 3.12.
+func expectError(resp response, status int, scimType string) error {
+	if err := expectStatus(resp, status); err != nil {
+		return err
+	}
+	if resp.body == nil {
+		return fmt.Errorf("expected an error body, got %q", resp.raw)
+	}
+	if !containsString(resp.body["schemas"], errorSchema) {
+		return fmt.Errorf("expected the schemas of the error to contain %q, got %v", errorSchema, resp.body["schemas"])
+	}
+	if s, ok := resp.body["status"].(string); !ok || s != strconv.Itoa(status) {
+		return fmt.Errorf("expected the status of the error to be the string %q, got %#v", strconv.Itoa(status), resp.body["status"])
+	}
+	if scimType != "" && resp.body["scimType"] != scimType {
+		return fmt.Errorf("expected scimType %q, got %#v", scimType, resp.body["scimType"])
+	}
+	return nil
+}
+
+// expectListResponse checks whether the given response is a list response, as described in RFC 7644 Section 3.4.2,
+// and returns its resources.
+func expectListResponse(resp response) ([]map[string]interface{}, error) {
+	if err := expectStatus(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	if !containsString(resp.body["schemas"], listResponseSchema) {
+		return nil, fmt.Errorf("expected the schemas of the list response to contain %q, got %v", listResponseSchema, resp.body["schemas"])
+	}
+	totalResults, ok := integer(resp.body["totalResults"])
+	if !ok {
+		return nil, fmt.Errorf("expected an integer totalResults, got %#v", resp.body["totalResults"])
+	}
+	var resources []map[string]interface{}
+	switch list := resp.body["Resources"].(type) {
+	case nil:
+		// Resources may be omitted if the page is empty, e.g., if the count is zero.
+	case []interface{}:
+		for _, v := range list {
+			r, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("expected the Resources to be objects, got %#v", v)
+			}
+			resources = append(resources, r)
+		}
+	default:
+		return nil, fmt.Errorf("expected Resources to be an array, got %#v", list)
+	}
+	if len(resources) > totalResults {
+		return nil, fmt.Errorf("got %d resources, but totalResults is %d", len(resources), totalResults)
+	}
+	return resources, nil
+}
+
+// expectStatus checks whether the given response has one of the given statuses.
+func expectStatus(resp response, statuses ...int) error {
+	for _, status := range statuses {
+		if resp.status == status {
+			return nil
+		}
+	}
+	var expected []string
+	for _, status := range statuses {
+		expected = append(expected, strconv.Itoa(status))
+	}
+	detail := ""
+	if d, ok := resp.body["detail"].(string); ok && d != "" {
+		detail = ": " + d
+	}
+	return fmt.Errorf("expected status %s, got %d%s", strings.Join(expected, " or "), resp.status, detail)
+}
+
+// integer converts the given JSON number to an int.
+func integer(v interface{}) (int, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return int(i), err == nil
+}
+
+// handlerTransport is a round tripper that serves requests with an http.Handler, without a network connection.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t handlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, r)
+	return rec.Result(), nil
+}
+
+// response is a decoded response of the service provider.
+type response struct {
+	status int
+	header http.Header
+	// body is the decoded JSON object of the response, numbers are decoded as json.Number. It is nil if the body is
+	// not a JSON object.
+	body map[string]interface{}
+	raw  []byte
+}
+
+// do sends a request to the given path, relative to the base URL of the suite, and decodes the response.
+func (s *Suite) do(ctx context.Context, method, path string, query url.Values, body interface{}, header http.Header) (response, error) {
+	u := strings.TrimRight(s.baseURL, "/") + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return response{}, err
+		}
+		r = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return response{}, err
+	}
+	req.Header.Set("Accept", contentType)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, vs := range s.header {
+		req.Header[k] = vs
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return response{}, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return response{}, err
+	}
+	decoded := response{
+		status: resp.StatusCode,
+		header: resp.Header,
+		raw:    raw,
+	}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	_ = d.Decode(&decoded.body)
+	return decoded, nil
+}
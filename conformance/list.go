@@ -0,0 +1,489 @@
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+)
+
+// listed is the number of resources that are created to check the list and search operations.
+const listed = 3
+
+// filters returns the filters that check the operators of the type of the given attribute, with the given value.
+func filters(attr schema.CoreAttribute, value interface{}) []filter.Builder {
+	a := filter.Attr(attr.Name())
+	switch attr.AttributeType() {
+	case "string":
+		s, _ := value.(string)
+		fs := []filter.Builder{a.Eq(s), a.Ne(s), a.Gt(s), a.Lt(s), a.Pr()}
+		if len(s) >= 3 {
+			fs = append(fs, a.Co(s[1:len(s)-1]), a.Sw(s[:len(s)-1]), a.Ew(s[1:]))
+		}
+		if upper := strings.ToUpper(s); !attr.CaseExact() && upper != s {
+			fs = append(fs, a.Eq(upper))
+		}
+		return fs
+	case "boolean":
+		return []filter.Builder{a.Eq(true), a.Eq(false), a.Ne(true), a.Pr()}
+	case "dateTime":
+		s, _ := value.(string)
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			value = t
+		}
+	}
+	return []filter.Builder{a.Eq(value), a.Ne(value), a.Gt(value), a.Ge(value), a.Lt(value), a.Le(value), a.Pr()}
+}
+
+// idFilter returns the filter that selects the resources with the given ids.
+func idFilter(ids []string) filter.Builder {
+	fs := make([]filter.Builder, len(ids))
+	for i, id := range ids {
+		fs[i] = filter.Attr(schema.CommonAttributeID).Eq(id)
+	}
+	return fs[0].Or(fs[1:]...)
+}
+
+// maxInt returns the larger of the given integers.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// minInt returns the smaller of the given integers.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// checkList checks the list and search operations on the resources of the resource type.
+func (t *resourceTest) checkList() {
+	name := t.resourceType.Name
+	requirements := []Requirement{
+		requirementList, requirementFilterString, requirementFilterBoolean, requirementFilterInteger,
+		requirementFilterDecimal, requirementFilterDateTime, requirementFilterLogical, requirementFilterInvalid,
+		requirementPagination, requirementSort, requirementAttributes, requirementSearch,
+	}
+
+	var (
+		ids       []string
+		resources []map[string]interface{}
+	)
+	defer func() {
+		for _, id := range ids {
+			_, _ = t.do(http.MethodDelete, t.path(id), nil, nil, nil)
+		}
+	}()
+	for n := 1; n <= listed; n++ {
+		resp, err := t.do(http.MethodPost, t.resourceType.Endpoint, nil, t.generate(n), nil)
+		if err == nil {
+			err = expectStatus(resp, http.StatusCreated)
+		}
+		var resource map[string]interface{}
+		if err == nil {
+			id, _ := resp.body["id"].(string)
+			ids = append(ids, id)
+			resource, err = t.get(id)
+		}
+		if err != nil {
+			for _, requirement := range requirements {
+				t.skip(requirement, name, fmt.Sprintf("the resources could not be created: %v", err))
+			}
+			return
+		}
+		resources = append(resources, resource)
+	}
+
+	t.check(requirementList, name, func() error {
+		resp, err := t.do(http.MethodGet, t.resourceType.Endpoint, url.Values{"count": {"1"}}, nil, nil)
+		if err != nil {
+			return err
+		}
+		page, err := expectListResponse(resp)
+		if err != nil {
+			return err
+		}
+		if total, _ := integer(resp.body["totalResults"]); total < listed {
+			return fmt.Errorf("expected totalResults to be at least %d, got %d", listed, total)
+		}
+		if len(page) > 1 {
+			return fmt.Errorf("expected at most 1 resource for count 1, got %d", len(page))
+		}
+		for _, resource := range page {
+			if _, ok := resource["id"].(string); !ok {
+				return fmt.Errorf("expected the resources to have an id, got %#v", resource["id"])
+			}
+			if err := t.checkSchemas(resource); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if !t.supported("filter") {
+		for _, requirement := range requirements[1:] {
+			t.skip(requirement, name, "the service provider does not support filtering")
+		}
+		return
+	}
+	l := &listTest{
+		resourceTest: t,
+		ids:          ids,
+		resources:    resources,
+	}
+	l.checkFilters()
+	l.checkPagination()
+	l.checkSort()
+	l.checkAttributes()
+	l.checkSearch()
+}
+
+// listTest checks the list and search operations on the resources that were created for it. All requests select these
+// resources with a filter on their ids.
+type listTest struct {
+	*resourceTest
+	ids       []string
+	resources []map[string]interface{}
+}
+
+// checkAttributes checks the attributes parameter.
+func (l *listTest) checkAttributes() {
+	l.check(requirementAttributes, l.resourceType.Name, func() error {
+		var requested, other schema.CoreAttribute
+		var found int
+		for _, attr := range l.resourceType.Schema.Attributes {
+			if _, ok := l.resources[0][attr.Name()]; !ok || attr.Returned() != "default" {
+				continue
+			}
+			if found == 0 {
+				requested = attr
+			} else {
+				other = attr
+			}
+			if found++; found == 2 {
+				break
+			}
+		}
+		if found < 2 {
+			return skipf("the resources have less than two attributes that are returned by default")
+		}
+		resources, _, err := l.list(filter.Builder{}, url.Values{"attributes": {requested.Name()}})
+		if err != nil {
+			return err
+		}
+		for _, resource := range resources {
+			if _, ok := resource["id"]; !ok {
+				return fmt.Errorf("expected id to be returned")
+			}
+			if _, ok := resource[requested.Name()]; !ok {
+				return fmt.Errorf("expected the requested attribute %s to be returned", requested.Name())
+			}
+			if v, ok := resource[other.Name()]; ok {
+				return fmt.Errorf("expected %s not to be returned, got %#v", other.Name(), v)
+			}
+		}
+		return nil
+	})
+}
+
+// checkFilters checks the filter operators of every attribute type.
+func (l *listTest) checkFilters() {
+	name := l.resourceType.Name
+	for _, c := range []struct {
+		requirement   Requirement
+		attributeType string
+	}{
+		{requirementFilterString, "string"},
+		{requirementFilterBoolean, "boolean"},
+		{requirementFilterInteger, "integer"},
+		{requirementFilterDecimal, "decimal"},
+		{requirementFilterDateTime, "dateTime"},
+	} {
+		attributeType := c.attributeType
+		l.check(c.requirement, name, func() error {
+			attr, ok := l.filterAttribute(attributeType)
+			if !ok {
+				return skipf("the resources have no %s attribute", attributeType)
+			}
+			for _, f := range filters(attr, l.resources[1][attr.Name()]) {
+				if err := l.expectFilter(f); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	l.check(requirementFilterLogical, name, func() error {
+		id := func(i int) filter.Builder {
+			return filter.Attr(schema.CommonAttributeID).Eq(l.ids[i])
+		}
+		for _, f := range []filter.Builder{
+			id(0).Or(id(2)),
+			id(0).And(id(1)),
+			filter.Not(id(1)),
+			filter.Not(id(0).Or(id(1))),
+			filter.Attr(schema.CommonAttributeID).Pr().And(filter.Not(id(0))),
+		} {
+			if err := l.expectFilter(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	l.check(requirementFilterInvalid, name, func() error {
+		resp, err := l.do(http.MethodGet, l.resourceType.Endpoint, url.Values{"filter": {"id eq"}}, nil, nil)
+		if err != nil {
+			return err
+		}
+		return expectError(resp, http.StatusBadRequest, "invalidFilter")
+	})
+}
+
+// checkPagination checks the startIndex and count parameters.
+func (l *listTest) checkPagination() {
+	l.check(requirementPagination, l.resourceType.Name, func() error {
+		size := 2
+		if maxResults, ok := integer(l.feature("filter", "maxResults")); ok && maxResults > 0 && maxResults < size {
+			size = maxResults
+		}
+		seen := map[string]bool{}
+		for _, c := range []struct {
+			startIndex, count, expected int
+		}{
+			{1, size, size},
+			{1 + size, size, minInt(size, listed-size)},
+			{listed + 1, size, 0},
+			{1, 0, 0},
+			{0, size, size},
+		} {
+			query := url.Values{
+				"startIndex": {fmt.Sprint(c.startIndex)},
+				"count":      {fmt.Sprint(c.count)},
+			}
+			resources, resp, err := l.list(filter.Builder{}, query)
+			if err != nil {
+				return err
+			}
+			params := fmt.Sprintf("startIndex %d and count %d", c.startIndex, c.count)
+			if total, _ := integer(resp.body["totalResults"]); total != listed {
+				return fmt.Errorf("%s: expected totalResults %d, got %d", params, listed, total)
+			}
+			if len(resources) != c.expected {
+				return fmt.Errorf("%s: expected %d resources, got %d", params, c.expected, len(resources))
+			}
+			// A startIndex less than 1 is interpreted as 1.
+			if startIndex, ok := integer(resp.body["startIndex"]); ok && startIndex != maxInt(c.startIndex, 1) {
+				return fmt.Errorf("%s: expected startIndex %d, got %d", params, maxInt(c.startIndex, 1), startIndex)
+			}
+			// Many service providers report the requested page size, rather than the number of returned resources.
+			if itemsPerPage, ok := integer(resp.body["itemsPerPage"]); ok && (itemsPerPage < len(resources) || itemsPerPage > c.count) {
+				return fmt.Errorf("%s: expected itemsPerPage between %d and %d, got %d", params, len(resources), c.count, itemsPerPage)
+			}
+			if c.startIndex > 0 {
+				for _, resource := range resources {
+					id, _ := resource["id"].(string)
+					if seen[id] {
+						return fmt.Errorf("%s: resource %s was already returned on a previous page", params, id)
+					}
+					seen[id] = true
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// checkSearch checks the search operation with POST.
+func (l *listTest) checkSearch() {
+	l.check(requirementSearch, l.resourceType.Name, func() error {
+		body := map[string]interface{}{
+			"schemas":    []interface{}{searchRequestSchema},
+			"filter":     idFilter(l.ids).String(),
+			"startIndex": 1,
+			"count":      listed,
+		}
+		resp, err := l.do(http.MethodPost, l.resourceType.Endpoint+"/.search", nil, body, nil)
+		if err != nil {
+			return err
+		}
+		resources, err := expectListResponse(resp)
+		if err != nil {
+			return err
+		}
+		if total, _ := integer(resp.body["totalResults"]); total != listed {
+			return fmt.Errorf("expected totalResults %d, got %d", listed, total)
+		}
+		if expected := minInt(listed, l.maxResults()); len(resources) != expected {
+			return fmt.Errorf("expected %d resources, got %d", expected, len(resources))
+		}
+		for _, resource := range resources {
+			if err := l.checkSchemas(resource); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// checkSort checks the sortBy and sortOrder parameters.
+func (l *listTest) checkSort() {
+	l.check(requirementSort, l.resourceType.Name, func() error {
+		if !l.supported("sort") {
+			return skipf("the service provider does not support sorting")
+		}
+		if l.maxResults() < listed {
+			return skipf("the service provider returns less than %d resources per page", listed)
+		}
+		attr, ok := l.filterAttribute("string")
+		if !ok {
+			return skipf("the resources have no string attribute")
+		}
+		key := func(resource map[string]interface{}) string {
+			s, _ := resource[attr.Name()].(string)
+			if !attr.CaseExact() {
+				s = strings.ToLower(s)
+			}
+			return s
+		}
+		for _, order := range []string{"ascending", "descending"} {
+			resources, _, err := l.list(filter.Builder{}, url.Values{"sortBy": {attr.Name()}, "sortOrder": {order}})
+			if err != nil {
+				return err
+			}
+			if len(resources) != listed {
+				return fmt.Errorf("expected %d resources, got %d", listed, len(resources))
+			}
+			sorted := sort.SliceIsSorted(resources, func(i, j int) bool {
+				if order == "descending" {
+					return key(resources[i]) > key(resources[j])
+				}
+				return key(resources[i]) < key(resources[j])
+			})
+			if !sorted {
+				var keys []string
+				for _, resource := range resources {
+					keys = append(keys, key(resource))
+				}
+				return fmt.Errorf("expected the resources in %s order of %s, got %v", order, attr.Name(), keys)
+			}
+		}
+		return nil
+	})
+}
+
+// expectFilter checks whether the service provider returns the resources that pass the given filter. The resources
+// that are expected are determined by evaluating the filter on the resources that were retrieved after creation.
+func (l *listTest) expectFilter(f filter.Builder) error {
+	var extensions []schema.Schema
+	for _, extension := range l.resourceType.SchemaExtensions {
+		extensions = append(extensions, extension.Schema)
+	}
+	validator, err := f.Validator(schema.WithCommonAttributes(l.resourceType.Schema), extensions...)
+	if err != nil {
+		return fmt.Errorf("invalid filter %s: %v", f, err)
+	}
+	predicate, err := validator.Compile()
+	if err != nil {
+		return fmt.Errorf("invalid filter %s: %v", f, err)
+	}
+	expected := map[string]bool{}
+	for i, resource := range l.resources {
+		ok, err := predicate(resource)
+		if err != nil {
+			return fmt.Errorf("could not evaluate filter %s: %v", f, err)
+		}
+		if ok {
+			expected[l.ids[i]] = true
+		}
+	}
+
+	resources, resp, err := l.list(f, nil)
+	if err != nil {
+		return fmt.Errorf("filter %s: %v", f, err)
+	}
+	if total, _ := integer(resp.body["totalResults"]); total != len(expected) {
+		return fmt.Errorf("filter %s: expected totalResults %d, got %d", f, len(expected), total)
+	}
+	actual := map[string]bool{}
+	for _, resource := range resources {
+		id, _ := resource["id"].(string)
+		actual[id] = true
+	}
+	if len(actual) != minInt(len(expected), l.maxResults()) {
+		return fmt.Errorf("filter %s: expected resources %v, got %v", f, l.indices(expected), l.indices(actual))
+	}
+	for id := range actual {
+		if !expected[id] {
+			return fmt.Errorf("filter %s: expected resources %v, got %v", f, l.indices(expected), l.indices(actual))
+		}
+	}
+	return nil
+}
+
+// filterAttribute returns the first single-valued attribute of the given type of which all resources have a value.
+func (l *listTest) filterAttribute(attributeType string) (schema.CoreAttribute, bool) {
+	for _, attr := range l.resourceType.Schema.Attributes {
+		if attr.AttributeType() != attributeType || attr.MultiValued() || attr.Returned() == "never" {
+			continue
+		}
+		present := true
+		for _, resource := range l.resources {
+			if _, ok := resource[attr.Name()]; !ok {
+				present = false
+			}
+		}
+		if present {
+			return attr, true
+		}
+	}
+	return schema.CoreAttribute{}, false
+}
+
+// indices returns the sorted indices of the resources with the given ids, e.g., "[1 3]", for readable details.
+func (l *listTest) indices(ids map[string]bool) []int {
+	var indices []int
+	for i, id := range l.ids {
+		if ids[id] {
+			indices = append(indices, i+1)
+		}
+	}
+	return indices
+}
+
+// list lists the resources that pass the given filter, if not empty, with the given query parameters. The filter is
+// combined with a filter on the ids of the resources of the test.
+func (l *listTest) list(f filter.Builder, query url.Values) ([]map[string]interface{}, response, error) {
+	combined := idFilter(l.ids)
+	if f.String() != "" {
+		combined = combined.And(f)
+	}
+	q := url.Values{"filter": {combined.String()}}
+	for k, vs := range query {
+		q[k] = vs
+	}
+	resp, err := l.do(http.MethodGet, l.resourceType.Endpoint, q, nil, nil)
+	if err != nil {
+		return nil, response{}, err
+	}
+	resources, err := expectListResponse(resp)
+	return resources, resp, err
+}
+
+// maxResults returns the maximum number of resources that the service provider returns per page.
+func (l *listTest) maxResults() int {
+	if maxResults, ok := integer(l.feature("filter", "maxResults")); ok && maxResults > 0 {
+		return maxResults
+	}
+	return listed
+}
@@ -0,0 +1,115 @@
+package conformance
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"text/tabwriter"
+)
+
+const (
+	// StatusPass indicates that the service provider meets the requirement.
+	StatusPass Status = "PASS"
+	// StatusFail indicates that the service provider does not meet the requirement.
+	StatusFail Status = "FAIL"
+	// StatusSkip indicates that the requirement was not checked, e.g., because the service provider does not support
+	// the feature or because a check it depends on failed.
+	StatusSkip Status = "SKIP"
+)
+
+// Report is the result of a conformance run.
+type Report struct {
+	// Results are the results of the checked requirements, in order.
+	Results []Result
+}
+
+// Count returns the number of results with the given status.
+func (r Report) Count(status Status) int {
+	var n int
+	for _, result := range r.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns the results of the requirements that the service provider does not meet.
+func (r Report) Failed() []Result {
+	var failed []Result
+	for _, result := range r.Results {
+		if result.Status == StatusFail {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Passed reports whether the service provider meets all checked requirements.
+func (r Report) Passed() bool {
+	return r.Count(StatusFail) == 0
+}
+
+// String returns the report as a table with a line per result, followed by a summary.
+func (r Report) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	for _, result := range r.Results {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Status, result.Name(), result.Requirement.Reference, result.Detail)
+	}
+	_ = w.Flush()
+	_, _ = fmt.Fprintf(&b, "passed %d, failed %d, skipped %d\n", r.Count(StatusPass), r.Count(StatusFail), r.Count(StatusSkip))
+	return b.String()
+}
+
+// Test reports every result as a subtest of the given test, so that a conformance run can be part of a test suite:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.NewHandlerSuite(server).Run(context.Background()).Test(t)
+//	}
+func (r Report) Test(t *testing.T) {
+	t.Helper()
+	for _, result := range r.Results {
+		result := result
+		t.Run(result.Name(), func(t *testing.T) {
+			switch result.Status {
+			case StatusFail:
+				t.Errorf("%s (%s): %s", result.Requirement.Description, result.Requirement.Reference, result.Detail)
+			case StatusSkip:
+				t.Skip(result.Detail)
+			}
+		})
+	}
+}
+
+// Requirement is a requirement of RFC 7643 or RFC 7644 that is checked by the suite.
+type Requirement struct {
+	// ID identifies the requirement, e.g., "create.status".
+	ID string
+	// Reference is the section of the RFC that states the requirement, e.g., "RFC 7644 Section 3.3".
+	Reference string
+	// Description describes the requirement.
+	Description string
+}
+
+// Result is the result of checking a requirement.
+type Result struct {
+	Requirement Requirement
+	// ResourceType is the name of the resource type that the requirement was checked against. It is empty for the
+	// requirements of the service provider itself, e.g., its discovery endpoints.
+	ResourceType string
+	Status       Status
+	// Detail is the reason why the requirement failed or was skipped.
+	Detail string
+}
+
+// Name returns the name of the result, e.g., "User/create.status".
+func (r Result) Name() string {
+	if r.ResourceType == "" {
+		return r.Requirement.ID
+	}
+	return r.ResourceType + "/" + r.Requirement.ID
+}
+
+// Status is the status of a result.
+type Status string
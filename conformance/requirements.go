@@ -0,0 +1,161 @@
+package conformance
+
+var (
+	requirementServiceProviderConfig = Requirement{
+		ID:          "discovery.service-provider-config",
+		Reference:   "RFC 7643 Section 5",
+		Description: "GET /ServiceProviderConfig returns the service provider config with its supported features and authentication schemes",
+	}
+	requirementResourceTypes = Requirement{
+		ID:          "discovery.resource-types",
+		Reference:   "RFC 7643 Section 6",
+		Description: "GET /ResourceTypes returns a list response of resource types with a name, an endpoint and a schema",
+	}
+	requirementSchemas = Requirement{
+		ID:          "discovery.schemas",
+		Reference:   "RFC 7643 Section 7",
+		Description: "GET /Schemas returns a list response of schemas with an id and attributes, including the schemas of all resource types",
+	}
+	requirementDiscoveryByID = Requirement{
+		ID:          "discovery.by-id",
+		Reference:   "RFC 7644 Section 4",
+		Description: "GET /ResourceTypes/{name} and GET /Schemas/{id} return a single resource type or schema",
+	}
+
+	requirementCreate = Requirement{
+		ID:          "create.status",
+		Reference:   "RFC 7644 Section 3.3",
+		Description: "POST to the endpoint of a resource type returns 201 Created with the created resource and its id",
+	}
+	requirementCreateLocation = Requirement{
+		ID:          "create.location",
+		Reference:   "RFC 7644 Section 3.3",
+		Description: "the Location header of a created resource equals its meta.location, and meta.resourceType is the name of its resource type",
+	}
+	requirementCreateInvalid = Requirement{
+		ID:          "create.invalid",
+		Reference:   "RFC 7644 Section 3.12",
+		Description: "POST of a resource with a value of the wrong type returns 400 Bad Request with an error body",
+	}
+	requirementCreateUniqueness = Requirement{
+		ID:          "create.uniqueness",
+		Reference:   "RFC 7644 Section 3.3",
+		Description: "POST of a resource with the value of a unique attribute of another resource returns 409 Conflict with scimType uniqueness",
+	}
+	requirementSchemasAttribute = Requirement{
+		ID:          "resource.schemas",
+		Reference:   "RFC 7643 Section 3",
+		Description: "the schemas attribute of a resource contains the schema of its resource type and the extensions that it contains",
+	}
+	requirementGet = Requirement{
+		ID:          "get.status",
+		Reference:   "RFC 7644 Section 3.4.1",
+		Description: "GET of a resource returns 200 OK with the resource",
+	}
+	requirementNotFound = Requirement{
+		ID:          "get.not-found",
+		Reference:   "RFC 7644 Section 3.12",
+		Description: "GET of an unknown resource returns 404 Not Found with an error body",
+	}
+	requirementETag = Requirement{
+		ID:          "etag",
+		Reference:   "RFC 7644 Section 3.14",
+		Description: "the ETag header equals meta.version, If-None-Match returns 304 Not Modified and a stale If-Match returns 412 Precondition Failed",
+	}
+	requirementReplace = Requirement{
+		ID:          "replace.status",
+		Reference:   "RFC 7644 Section 3.5.1",
+		Description: "PUT of a resource returns 200 OK with the replaced resource and keeps its id",
+	}
+	requirementPatchReplace = Requirement{
+		ID:          "patch.replace",
+		Reference:   "RFC 7644 Section 3.5.2.3",
+		Description: "a PATCH replace operation replaces the value of an attribute and returns 200 OK or 204 No Content",
+	}
+	requirementPatchRemove = Requirement{
+		ID:          "patch.remove",
+		Reference:   "RFC 7644 Section 3.5.2.2",
+		Description: "a PATCH remove operation removes an optional attribute",
+	}
+	requirementPatchAdd = Requirement{
+		ID:          "patch.add",
+		Reference:   "RFC 7644 Section 3.5.2.1",
+		Description: "a PATCH add operation adds an attribute that was absent",
+	}
+	requirementPatchInvalidPath = Requirement{
+		ID:          "patch.invalid-path",
+		Reference:   "RFC 7644 Section 3.5.2",
+		Description: "a PATCH operation with an unknown attribute in its path returns 400 Bad Request with scimType invalidPath",
+	}
+	requirementPatchNoTarget = Requirement{
+		ID:          "patch.no-target",
+		Reference:   "RFC 7644 Section 3.5.2.2",
+		Description: "a PATCH remove operation without a path returns 400 Bad Request with scimType noTarget",
+	}
+	requirementDelete = Requirement{
+		ID:          "delete.status",
+		Reference:   "RFC 7644 Section 3.6",
+		Description: "DELETE of a resource returns 204 No Content, after which GET of the resource returns 404 Not Found",
+	}
+
+	requirementList = Requirement{
+		ID:          "list.response",
+		Reference:   "RFC 7644 Section 3.4.2",
+		Description: "GET of the endpoint of a resource type returns a list response with totalResults and the resources",
+	}
+	requirementFilterString = Requirement{
+		ID:          "filter.string",
+		Reference:   "RFC 7644 Section 3.4.2.2",
+		Description: "the eq, ne, co, sw, ew, gt, lt and pr operators select the right resources on string attributes, case-insensitively unless caseExact",
+	}
+	requirementFilterBoolean = Requirement{
+		ID:          "filter.boolean",
+		Reference:   "RFC 7644 Section 3.4.2.2",
+		Description: "the eq, ne and pr operators select the right resources on boolean attributes",
+	}
+	requirementFilterInteger = Requirement{
+		ID:          "filter.integer",
+		Reference:   "RFC 7644 Section 3.4.2.2",
+		Description: "the eq, ne, gt, ge, lt, le and pr operators select the right resources on integer attributes",
+	}
+	requirementFilterDecimal = Requirement{
+		ID:          "filter.decimal",
+		Reference:   "RFC 7644 Section 3.4.2.2",
+		Description: "the eq, ne, gt, ge, lt, le and pr operators select the right resources on decimal attributes",
+	}
+	requirementFilterDateTime = Requirement{
+		ID:          "filter.dateTime",
+		Reference:   "RFC 7644 Section 3.4.2.2",
+		Description: "the eq, ne, gt, ge, lt, le and pr operators select the right resources on dateTime attributes",
+	}
+	requirementFilterLogical = Requirement{
+		ID:          "filter.logical",
+		Reference:   "RFC 7644 Section 3.4.2.2",
+		Description: "the and, or and not operators and grouping select the right resources",
+	}
+	requirementFilterInvalid = Requirement{
+		ID:          "filter.invalid",
+		Reference:   "RFC 7644 Section 3.4.2.2",
+		Description: "an invalid filter returns 400 Bad Request with scimType invalidFilter",
+	}
+	requirementPagination = Requirement{
+		ID:          "list.pagination",
+		Reference:   "RFC 7644 Section 3.4.2.4",
+		Description: "startIndex and count select the right page, with the right totalResults, startIndex and itemsPerPage",
+	}
+	requirementSort = Requirement{
+		ID:          "list.sort",
+		Reference:   "RFC 7644 Section 3.4.2.3",
+		Description: "sortBy and sortOrder return the resources in ascending and descending order",
+	}
+	requirementAttributes = Requirement{
+		ID:          "list.attributes",
+		Reference:   "RFC 7644 Section 3.4.2.5",
+		Description: "the attributes parameter limits the returned attributes to the requested ones and the ones that are always returned",
+	}
+	requirementSearch = Requirement{
+		ID:          "search.status",
+		Reference:   "RFC 7644 Section 3.4.3",
+		Description: "POST to /.search of the endpoint of a resource type returns the same list response as GET",
+	}
+)
@@ -0,0 +1,414 @@
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/schema"
+)
+
+// patchRequest returns the body of a PATCH request with the given operations.
+func patchRequest(operations ...map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"schemas":    []interface{}{patchOpSchema},
+		"Operations": operations,
+	}
+}
+
+// checkResourceType checks the operations on the resources of the given resource type.
+func (r *run) checkResourceType(resourceType scim.ResourceType) {
+	generate, ok := r.suite.generators[strings.ToLower(resourceType.Name)]
+	if !ok {
+		generate = defaultGenerator(resourceType, r.nonce)
+	}
+	t := &resourceTest{
+		run:          r,
+		resourceType: resourceType,
+		generate:     generate,
+	}
+	t.checkCRUD()
+	t.checkList()
+}
+
+// resourceTest checks the operations on the resources of a single resource type.
+type resourceTest struct {
+	*run
+	resourceType scim.ResourceType
+	generate     Generator
+}
+
+// attribute returns the first attribute of the core schema for which the given function returns true and which has a
+// value in the given resource.
+func (t *resourceTest) attribute(resource map[string]interface{}, f func(attr schema.CoreAttribute) bool) (schema.CoreAttribute, bool) {
+	for _, attr := range t.resourceType.Schema.Attributes {
+		if _, ok := resource[attr.Name()]; ok && f(attr) {
+			return attr, true
+		}
+	}
+	return schema.CoreAttribute{}, false
+}
+
+// checkCRUD checks the create, get, replace, patch and delete operations on a single resource.
+func (t *resourceTest) checkCRUD() {
+	name := t.resourceType.Name
+
+	t.check(requirementNotFound, name, func() error {
+		resp, err := t.do(http.MethodGet, t.path(t.nonce+"-unknown"), nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		return expectError(resp, http.StatusNotFound, "")
+	})
+
+	attributes := t.generate(0)
+	t.check(requirementCreateInvalid, name, func() error {
+		invalid := make(scim.ResourceAttributes, len(attributes))
+		for k, v := range attributes {
+			invalid[k] = v
+		}
+		attr, ok := t.attribute(attributes, func(attr schema.CoreAttribute) bool {
+			return !attr.MultiValued() && attr.AttributeType() != "complex"
+		})
+		if !ok {
+			return skipf("the generated resource has no simple attribute")
+		}
+		// A complex value is invalid for all simple attributes.
+		invalid[attr.Name()] = map[string]interface{}{"value": invalid[attr.Name()]}
+		resp, err := t.do(http.MethodPost, t.resourceType.Endpoint, nil, invalid, nil)
+		if err != nil {
+			return err
+		}
+		if resp.status == http.StatusCreated {
+			if id, ok := resp.body["id"].(string); ok {
+				_, _ = t.do(http.MethodDelete, t.path(id), nil, nil, nil)
+			}
+		}
+		return expectError(resp, http.StatusBadRequest, "")
+	})
+
+	var (
+		id      string
+		created response
+	)
+	if !t.check(requirementCreate, name, func() error {
+		resp, err := t.do(http.MethodPost, t.resourceType.Endpoint, nil, attributes, nil)
+		if err != nil {
+			return err
+		}
+		if err := expectStatus(resp, http.StatusCreated); err != nil {
+			return err
+		}
+		id, _ = resp.body["id"].(string)
+		if id == "" {
+			return fmt.Errorf("expected the created resource to have an id, got %#v", resp.body["id"])
+		}
+		created = resp
+		return nil
+	}) {
+		for _, requirement := range []Requirement{
+			requirementCreateLocation, requirementCreateUniqueness, requirementGet, requirementSchemasAttribute,
+			requirementETag, requirementReplace, requirementPatchReplace, requirementPatchRemove, requirementPatchAdd,
+			requirementPatchInvalidPath, requirementPatchNoTarget, requirementDelete,
+		} {
+			t.skip(requirement, name, "the resource could not be created")
+		}
+		return
+	}
+
+	t.check(requirementCreateLocation, name, func() error {
+		location := created.header.Get("Location")
+		if location == "" {
+			return fmt.Errorf("expected a Location header")
+		}
+		meta, _ := created.body["meta"].(map[string]interface{})
+		if meta["location"] != location {
+			return fmt.Errorf("expected meta.location to equal the Location header %q, got %#v", location, meta["location"])
+		}
+		if meta["resourceType"] != name {
+			return fmt.Errorf("expected meta.resourceType %q, got %#v", name, meta["resourceType"])
+		}
+		if suffix := strings.TrimPrefix(t.path(id), "/"); !strings.HasSuffix(location, suffix) {
+			return fmt.Errorf("expected the Location header %q to end with %q", location, suffix)
+		}
+		return nil
+	})
+
+	t.check(requirementCreateUniqueness, name, func() error {
+		attr, ok := t.attribute(attributes, func(attr schema.CoreAttribute) bool {
+			return attr.Uniqueness() == "server" || attr.Uniqueness() == "global"
+		})
+		if !ok {
+			return skipf("the resource type has no unique attribute")
+		}
+		resp, err := t.do(http.MethodPost, t.resourceType.Endpoint, nil, attributes, nil)
+		if err != nil {
+			return err
+		}
+		if resp.status == http.StatusCreated {
+			if id, ok := resp.body["id"].(string); ok {
+				_, _ = t.do(http.MethodDelete, t.path(id), nil, nil, nil)
+			}
+		}
+		if err := expectError(resp, http.StatusConflict, "uniqueness"); err != nil {
+			return fmt.Errorf("duplicate %s: %v", attr.Name(), err)
+		}
+		return nil
+	})
+
+	var got response
+	t.check(requirementGet, name, func() error {
+		resp, err := t.do(http.MethodGet, t.path(id), nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		if err := expectStatus(resp, http.StatusOK); err != nil {
+			return err
+		}
+		if resp.body["id"] != id {
+			return fmt.Errorf("expected id %q, got %#v", id, resp.body["id"])
+		}
+		for _, attr := range t.resourceType.Schema.Attributes {
+			s, ok := attributes[attr.Name()].(string)
+			if ok && attr.Returned() != "never" && resp.body[attr.Name()] != s {
+				return fmt.Errorf("expected %s to be %q, got %#v", attr.Name(), s, resp.body[attr.Name()])
+			}
+		}
+		got = resp
+		return nil
+	})
+
+	t.check(requirementSchemasAttribute, name, func() error {
+		for _, resp := range []response{created, got} {
+			if resp.body == nil {
+				continue
+			}
+			if err := t.checkSchemas(resp.body); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	t.check(requirementETag, name, func() error {
+		if !t.supported("etag") {
+			return skipf("the service provider does not support entity tags")
+		}
+		if got.body == nil {
+			return skipf("the resource could not be retrieved")
+		}
+		etag := got.header.Get("ETag")
+		if etag == "" {
+			return fmt.Errorf("expected an ETag header")
+		}
+		meta, _ := got.body["meta"].(map[string]interface{})
+		if meta["version"] != etag {
+			return fmt.Errorf("expected meta.version to equal the ETag header %q, got %#v", etag, meta["version"])
+		}
+		resp, err := t.do(http.MethodGet, t.path(id), nil, nil, http.Header{"If-None-Match": {etag}})
+		if err != nil {
+			return err
+		}
+		if err := expectStatus(resp, http.StatusNotModified); err != nil {
+			return fmt.Errorf("GET with If-None-Match: %v", err)
+		}
+		resp, err = t.do(http.MethodPut, t.path(id), nil, attributes, http.Header{"If-Match": {`W/"` + t.nonce + `"`}})
+		if err != nil {
+			return err
+		}
+		if err := expectError(resp, http.StatusPreconditionFailed, ""); err != nil {
+			return fmt.Errorf("PUT with a stale If-Match: %v", err)
+		}
+		return nil
+	})
+
+	// The replaced and patched attribute is a mutable string without constraints on its value.
+	target, hasTarget := t.attribute(attributes, func(attr schema.CoreAttribute) bool {
+		return attr.AttributeType() == "string" && !attr.MultiValued() && attr.Mutability() == "readWrite" && attr.Uniqueness() == "none" && len(attr.CanonicalValues()) == 0
+	})
+
+	t.check(requirementReplace, name, func() error {
+		replacement := make(scim.ResourceAttributes, len(attributes))
+		for k, v := range attributes {
+			replacement[k] = v
+		}
+		if hasTarget {
+			replacement[target.Name()] = fmt.Sprintf("%s-replaced", attributes[target.Name()])
+		}
+		resp, err := t.do(http.MethodPut, t.path(id), nil, replacement, nil)
+		if err != nil {
+			return err
+		}
+		if err := expectStatus(resp, http.StatusOK); err != nil {
+			return err
+		}
+		if resp.body["id"] != id {
+			return fmt.Errorf("expected id %q, got %#v", id, resp.body["id"])
+		}
+		if hasTarget && resp.body[target.Name()] != replacement[target.Name()] {
+			return fmt.Errorf("expected %s to be %q, got %#v", target.Name(), replacement[target.Name()], resp.body[target.Name()])
+		}
+		return nil
+	})
+
+	t.checkPatch(id, attributes, target, hasTarget)
+
+	t.check(requirementDelete, name, func() error {
+		resp, err := t.do(http.MethodDelete, t.path(id), nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		if err := expectStatus(resp, http.StatusNoContent); err != nil {
+			return err
+		}
+		resp, err = t.do(http.MethodGet, t.path(id), nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		if err := expectError(resp, http.StatusNotFound, ""); err != nil {
+			return fmt.Errorf("GET after DELETE: %v", err)
+		}
+		return nil
+	})
+}
+
+// checkPatch checks the PATCH operations on the resource with the given id and attributes. The given target is the
+// attribute that is replaced.
+func (t *resourceTest) checkPatch(id string, attributes scim.ResourceAttributes, target schema.CoreAttribute, hasTarget bool) {
+	name := t.resourceType.Name
+	if !t.supported("patch") {
+		for _, requirement := range []Requirement{
+			requirementPatchReplace, requirementPatchRemove, requirementPatchAdd, requirementPatchInvalidPath,
+			requirementPatchNoTarget,
+		} {
+			t.skip(requirement, name, "the service provider does not support PATCH")
+		}
+		return
+	}
+
+	t.check(requirementPatchReplace, name, func() error {
+		if !hasTarget {
+			return skipf("the generated resource has no mutable string attribute")
+		}
+		value := fmt.Sprintf("%s-patched", attributes[target.Name()])
+		return t.patch(id, map[string]interface{}{"op": "replace", "path": target.Name(), "value": value}, func(resource map[string]interface{}) error {
+			if resource[target.Name()] != value {
+				return fmt.Errorf("expected %s to be %q, got %#v", target.Name(), value, resource[target.Name()])
+			}
+			return nil
+		})
+	})
+
+	optional, hasOptional := t.attribute(attributes, func(attr schema.CoreAttribute) bool {
+		return !attr.Required() && attr.Mutability() == "readWrite" && attr.Returned() != "never"
+	})
+	removed := t.check(requirementPatchRemove, name, func() error {
+		if !hasOptional {
+			return skipf("the generated resource has no optional attribute")
+		}
+		return t.patch(id, map[string]interface{}{"op": "remove", "path": optional.Name()}, func(resource map[string]interface{}) error {
+			if v, ok := resource[optional.Name()]; ok {
+				return fmt.Errorf("expected %s to be removed, got %#v", optional.Name(), v)
+			}
+			return nil
+		})
+	})
+
+	t.check(requirementPatchAdd, name, func() error {
+		if !removed {
+			return skipf("no attribute was removed")
+		}
+		value := attributes[optional.Name()]
+		return t.patch(id, map[string]interface{}{"op": "add", "path": optional.Name(), "value": value}, func(resource map[string]interface{}) error {
+			if v, ok := resource[optional.Name()]; !ok || fmt.Sprint(v) != fmt.Sprint(value) {
+				return fmt.Errorf("expected %s to be %v, got %#v", optional.Name(), value, v)
+			}
+			return nil
+		})
+	})
+
+	t.check(requirementPatchInvalidPath, name, func() error {
+		operation := map[string]interface{}{"op": "replace", "path": t.nonce + "Unknown", "value": "value"}
+		resp, err := t.do(http.MethodPatch, t.path(id), nil, patchRequest(operation), nil)
+		if err != nil {
+			return err
+		}
+		return expectError(resp, http.StatusBadRequest, "invalidPath")
+	})
+
+	t.check(requirementPatchNoTarget, name, func() error {
+		resp, err := t.do(http.MethodPatch, t.path(id), nil, patchRequest(map[string]interface{}{"op": "remove"}), nil)
+		if err != nil {
+			return err
+		}
+		return expectError(resp, http.StatusBadRequest, "noTarget")
+	})
+}
+
+// checkSchemas checks whether the "schemas" attribute of the given resource contains the schema of the resource type
+// and the schema extensions of which the resource contains attributes, and no other schemas.
+func (t *resourceTest) checkSchemas(resource map[string]interface{}) error {
+	if !containsString(resource["schemas"], t.resourceType.Schema.ID) {
+		return fmt.Errorf("expected the schemas to contain %q, got %v", t.resourceType.Schema.ID, resource["schemas"])
+	}
+	known := map[string]bool{strings.ToLower(t.resourceType.Schema.ID): true}
+	for _, extension := range t.resourceType.SchemaExtensions {
+		known[strings.ToLower(extension.Schema.ID)] = true
+	}
+	schemas, _ := resource["schemas"].([]interface{})
+	for _, v := range schemas {
+		if s, _ := v.(string); !known[strings.ToLower(s)] {
+			return fmt.Errorf("expected the schemas to only contain the schemas of the resource type, got %v", v)
+		}
+	}
+	for k := range resource {
+		if known[strings.ToLower(k)] && !containsString(resource["schemas"], k) {
+			return fmt.Errorf("expected the schemas to contain the extension %q of which the resource contains attributes, got %v", k, resource["schemas"])
+		}
+	}
+	return nil
+}
+
+// get returns the resource with the given id.
+func (t *resourceTest) get(id string) (map[string]interface{}, error) {
+	resp, err := t.do(http.MethodGet, t.path(id), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectStatus(resp, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("GET %s: %v", t.path(id), err)
+	}
+	return resp.body, nil
+}
+
+// patch sends a PATCH request with the given operation and checks the patched resource with the given function, both
+// the one in the response and the one that is retrieved afterwards.
+func (t *resourceTest) patch(id string, operation map[string]interface{}, check func(resource map[string]interface{}) error) error {
+	resp, err := t.do(http.MethodPatch, t.path(id), nil, patchRequest(operation), nil)
+	if err != nil {
+		return err
+	}
+	if err := expectStatus(resp, http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+	if resp.status == http.StatusOK {
+		if err := check(resp.body); err != nil {
+			return fmt.Errorf("PATCH response: %v", err)
+		}
+	}
+	resource, err := t.get(id)
+	if err != nil {
+		return err
+	}
+	if err := check(resource); err != nil {
+		return fmt.Errorf("GET after PATCH: %v", err)
+	}
+	return nil
+}
+
+// path returns the path of the resource with the given id.
+func (t *resourceTest) path(id string) string {
+	return t.resourceType.Endpoint + "/" + url.PathEscape(id)
+}
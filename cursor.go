@@ -0,0 +1,45 @@
+package scim
+
+import (
+	"net/http"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+// cursorUnsupported returns an error indicating that cursor-based pagination is not supported.
+func cursorUnsupported() *errors.ScimError {
+	return &errors.ScimError{
+		ScimType: errors.ScimTypeInvalidValue,
+		Detail:   "Cursor-based pagination is not supported.",
+		Status:   http.StatusBadRequest,
+	}
+}
+
+// parseCursorParam returns the "cursor" query parameter of the given request and whether it is present. An empty
+// cursor requests the first page. As described in RFC 9865 Section 2.1, a request can not combine cursor-based and
+// index-based pagination.
+func parseCursorParam(r *http.Request) (string, bool, *errors.ScimError) {
+	query := r.URL.Query()
+	if _, ok := query["cursor"]; !ok {
+		return "", false, nil
+	}
+	if _, ok := query["startIndex"]; ok {
+		scimErr := errors.ScimErrorBadParams([]string{"cursor", "startIndex"})
+		scimErr.ScimType = errors.ScimTypeInvalidValue
+		return "", false, &scimErr
+	}
+	return query.Get("cursor"), true, nil
+}
+
+// cursorPaginator returns the given handler as a CursorPaginator, or an error if the server or the handler does not
+// support cursor-based pagination.
+func (s Server) cursorPaginator(handler interface{}) (CursorPaginator, *errors.ScimError) {
+	if !s.config.SupportCursorPagination {
+		return nil, cursorUnsupported()
+	}
+	paginator, ok := handler.(CursorPaginator)
+	if !ok {
+		return nil, cursorUnsupported()
+	}
+	return paginator, nil
+}
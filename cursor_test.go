@@ -0,0 +1,243 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/optional"
+)
+
+func TestListResponseCursor(t *testing.T) {
+	raw, err := json.Marshal(listResponse{
+		TotalResults: 2,
+		ItemsPerPage: 1,
+		Resources:    []interface{}{},
+		NextCursor:   "next",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualJSON(t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:ListResponse"],
+		"totalResults": 2,
+		"itemsPerPage": 1,
+		"Resources": [],
+		"nextCursor": "next"
+	}`, string(raw))
+}
+
+func TestServerCursorPagination(t *testing.T) {
+	var captured ListRequestParams
+	s := newTestServerWithCursor(t, true, &captured)
+
+	var ids []string
+	cursor := ""
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/Users?count=8&cursor="+cursor, nil)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		assertEqualStatusCode(t, http.StatusOK, rr.Code)
+		assertEqual(t, cursor, captured.Cursor)
+
+		var response map[string]interface{}
+		assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assertNil(t, response["startIndex"], "startIndex")
+		for _, resource := range response["Resources"].([]interface{}) {
+			ids = append(ids, resource.(map[string]interface{})["id"].(string))
+		}
+
+		next, ok := response["nextCursor"].(string)
+		if !ok {
+			break
+		}
+		cursor = next
+	}
+	assertLen(t, ids, 20)
+	assertTrue(t, sort.StringsAreSorted(ids))
+}
+
+func TestServerCursorPaginationErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		supported        bool
+		method           string
+		target           string
+		body             string
+		expectedStatus   int
+		expectedScimType errors.ScimType
+	}{
+		{
+			name:             "unsupported",
+			method:           http.MethodGet,
+			target:           "/Users?cursor=",
+			expectedStatus:   http.StatusBadRequest,
+			expectedScimType: errors.ScimTypeInvalidValue,
+		},
+		{
+			name:             "with start index",
+			supported:        true,
+			method:           http.MethodGet,
+			target:           "/Users?cursor=&startIndex=1",
+			expectedStatus:   http.StatusBadRequest,
+			expectedScimType: errors.ScimTypeInvalidValue,
+		},
+		{
+			name:             "invalid cursor",
+			supported:        true,
+			method:           http.MethodGet,
+			target:           "/Users?cursor=unknown",
+			expectedStatus:   http.StatusBadRequest,
+			expectedScimType: errors.ScimTypeInvalidCursor,
+		},
+		{
+			name:             "expired cursor",
+			supported:        true,
+			method:           http.MethodPost,
+			target:           "/Users/.search",
+			body:             `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"], "cursor": "expired"}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedScimType: errors.ScimTypeExpiredCursor,
+		},
+		{
+			name:             "search with start index",
+			supported:        true,
+			method:           http.MethodPost,
+			target:           "/Users/.search",
+			body:             `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"], "cursor": "", "startIndex": 1}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedScimType: errors.ScimTypeInvalidValue,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			rr := httptest.NewRecorder()
+			newTestServerWithCursor(t, test.supported, nil).ServeHTTP(rr, req)
+			assertEqualStatusCode(t, test.expectedStatus, rr.Code)
+
+			var scimErr errors.ScimError
+			assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &scimErr))
+			assertEqual(t, test.expectedScimType, scimErr.ScimType)
+		})
+	}
+}
+
+func TestServerCursorSearch(t *testing.T) {
+	var captured ListRequestParams
+	req := httptest.NewRequest(http.MethodPost, "/Users/.search", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"],
+		"filter": "userName sw \"test\"",
+		"sortBy": "userName",
+		"count": 5,
+		"cursor": ""
+	}`))
+	rr := httptest.NewRecorder()
+	newTestServerWithCursor(t, true, &captured).ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+	assertNotNil(t, captured.FilterValidator, "filter validator")
+	assertEqual(t, "userName", captured.SortBy)
+	assertEqual(t, SortOrderAscending, captured.SortOrder)
+	assertEqual(t, 5, captured.Count)
+
+	var response struct {
+		Resources  []interface{}
+		NextCursor string
+	}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assertLen(t, response.Resources, 5)
+	assertEqual(t, "00014", response.NextCursor)
+}
+
+func TestServiceProviderConfigPagination(t *testing.T) {
+	config := ServiceProviderConfig{SupportCursorPagination: true, CursorTimeout: 60, MaxResults: 50}
+	raw, err := json.Marshal(config.getRaw()["pagination"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualJSON(t, `{
+		"cursor": true,
+		"index": true,
+		"defaultPaginationMethod": "index",
+		"defaultPageSize": 50,
+		"maxPageSize": 50,
+		"cursorTimeout": 60
+	}`, string(raw))
+}
+
+func newTestServerWithCursor(t *testing.T, supported bool, captured *ListRequestParams) Server {
+	s, err := NewServer(
+		&ServerArgs{
+			ServiceProviderConfig: &ServiceProviderConfig{SupportCursorPagination: supported},
+			ResourceTypes: []ResourceType{
+				{
+					ID:       optional.NewString("User"),
+					Name:     "User",
+					Endpoint: "/Users",
+					Schema:   getUserSchema(),
+					Handler: testCursorHandler{
+						testSearchHandler: testSearchHandler{testResourceHandler: newTestResourceHandler().(testResourceHandler)},
+						captured:          captured,
+					},
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// testCursorHandler pages through the resources ordered by identifier, the cursor is the identifier of the first
+// resource of the page.
+type testCursorHandler struct {
+	testSearchHandler
+	// captured records the parameters of the last request, if not nil.
+	captured *ListRequestParams
+}
+
+func (h testCursorHandler) GetAllWithCursor(r *http.Request, params ListRequestParams) (Page, error) {
+	if h.captured != nil {
+		*h.captured = params
+	}
+	if params.Cursor == "expired" {
+		return Page{}, errors.ScimErrorExpiredCursor
+	}
+
+	ids := make([]string, 0, len(h.data))
+	for id := range h.data {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	start := 0
+	if params.Cursor != "" {
+		start = sort.SearchStrings(ids, params.Cursor)
+		if start == len(ids) || ids[start] != params.Cursor {
+			return Page{}, errors.ScimErrorInvalidCursor
+		}
+	}
+	end := start + params.Count
+	if end > len(ids) {
+		end = len(ids)
+	}
+
+	page := Page{TotalResults: len(ids)}
+	for _, id := range ids[start:end] {
+		page.Resources = append(page.Resources, Resource{
+			ID:         id,
+			Attributes: h.data[id].resourceAttributes,
+		})
+	}
+	if end < len(ids) {
+		page.NextCursor = ids[end]
+	}
+	return page, nil
+}
@@ -0,0 +1,364 @@
+package scim
+
+import (
+	"strings"
+
+	f "github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+// diffKeys are the sub-attributes that identify a value of a complex multi-valued attribute, in order of preference.
+var diffKeys = []string{"value", "type"}
+
+// countKeyValues returns the number of the given values of which the given key equals the given value.
+func countKeyValues(attr schema.CoreAttribute, key string, v interface{}, values []interface{}) int {
+	var n int
+	for _, value := range values {
+		if equalKeyValues(attr, key, v, keyValue(attr, key, value)) {
+			n++
+		}
+	}
+	return n
+}
+
+// diffAttribute returns the operations that change the value of the given attribute at the given path.
+func diffAttribute(path filter.Path, attr schema.CoreAttribute, from, to interface{}) ([]PatchOperation, error) {
+	switch {
+	case isEmptyValue(from) && isEmptyValue(to):
+		return nil, nil
+	case isEmptyValue(to):
+		return newPatchOperations(PatchOperationRemove, path, nil), nil
+	case attr.MultiValued():
+		return diffMultiValued(path, attr, toSlice(from), toSlice(to))
+	case attr.AttributeType() == "complex" && !isEmptyValue(from):
+		return diffComplex(path, attr, from, to)
+	case isEmptyValue(from) || !equalAttributeValues(attr, from, to):
+		return newPatchOperations(PatchOperationReplace, path, writableValue(attr, to)), nil
+	default:
+		return nil, nil
+	}
+}
+
+// diffComplex returns the operations that change the sub-attributes of the given complex value, e.g.,
+// "name.givenName".
+func diffComplex(path filter.Path, attr schema.CoreAttribute, from, to interface{}) ([]PatchOperation, error) {
+	var operations []PatchOperation
+	for _, subAttr := range attr.SubAttributes() {
+		if subAttr.Mutability() == "readOnly" {
+			continue
+		}
+		ops, err := diffAttribute(
+			subAttributePath(path, subAttr.Name()), subAttr,
+			lookupAttribute(from, subAttr.Name()), lookupAttribute(to, subAttr.Name()),
+		)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, ops...)
+	}
+	return operations, nil
+}
+
+// diffMultiValued returns the operations that change the values of the given multi-valued attribute. The values are
+// treated as an unordered set: values are matched by equality and then by one of the diffKeys, e.g., the "type" of an
+// email. Values that are no longer present are removed, matched values are updated and new values are added. If a value
+// can not be targeted by a value filter, all values of the attribute are replaced instead.
+func diffMultiValued(path filter.Path, attr schema.CoreAttribute, from, to []interface{}) ([]PatchOperation, error) {
+	matches := make([]int, len(from))
+	matched := make([]bool, len(to))
+	for i, a := range from {
+		matches[i] = -1
+		for j, b := range to {
+			if !matched[j] && equalAttributeValues(attr, a, b) {
+				matches[i], matched[j] = j, true
+				break
+			}
+		}
+	}
+	if attr.AttributeType() == "complex" {
+		for _, key := range diffKeys {
+			for i, a := range from {
+				if matches[i] != -1 {
+					continue
+				}
+				if j, ok := uniqueMatch(attr, key, a, from, to, matches, matched); ok {
+					matches[i], matched[j] = j, true
+				}
+			}
+		}
+	}
+
+	replace := func() ([]PatchOperation, error) {
+		return newPatchOperations(PatchOperationReplace, path, writableValue(attr, to)), nil
+	}
+
+	var operations []PatchOperation
+	for i, a := range from {
+		if matches[i] != -1 {
+			continue
+		}
+		valueFilter, ok := identifyingFilter(attr, a, nil, from, nil)
+		if !ok {
+			return replace()
+		}
+		operations = append(operations, newPatchOperations(PatchOperationRemove, valuePath(path, valueFilter), nil)...)
+	}
+
+	for i, a := range from {
+		j := matches[i]
+		if j == -1 || equalAttributeValues(attr, a, to[j]) {
+			continue
+		}
+		// The filter must select the value before and after the update, so it can only use an unchanged key.
+		valueFilter, ok := identifyingFilter(attr, a, to[j], from, to)
+		if !ok {
+			return replace()
+		}
+		ops, err := diffComplex(valuePath(path, valueFilter), attr, a, to[j])
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, ops...)
+	}
+
+	var added []interface{}
+	for j, b := range to {
+		if !matched[j] {
+			added = append(added, writableElement(attr, b))
+		}
+	}
+	if len(added) != 0 {
+		operations = append(operations, newPatchOperations(PatchOperationAdd, path, added)...)
+	}
+	return operations, nil
+}
+
+// equalAttributeValues checks whether the given singular values of the given attribute are equal. Strings are compared
+// case-insensitively unless the attribute is case-exact and complex values are compared by their sub-attributes that
+// are not read-only.
+func equalAttributeValues(attr schema.CoreAttribute, a, b interface{}) bool {
+	if attr.AttributeType() == "complex" {
+		if _, ok := asMap(a); !ok {
+			return equalValues(a, b)
+		}
+		for _, subAttr := range attr.SubAttributes() {
+			if subAttr.Mutability() == "readOnly" {
+				continue
+			}
+			x, y := lookupAttribute(a, subAttr.Name()), lookupAttribute(b, subAttr.Name())
+			switch {
+			case isEmptyValue(x) || isEmptyValue(y):
+				if isEmptyValue(x) != isEmptyValue(y) {
+					return false
+				}
+			case subAttr.MultiValued():
+				if !equalValues(x, y) {
+					return false
+				}
+			case !equalAttributeValues(subAttr, x, y):
+				return false
+			}
+		}
+		return true
+	}
+	if x, ok := a.(string); ok && !attr.CaseExact() {
+		y, ok := b.(string)
+		return ok && strings.EqualFold(x, y)
+	}
+	return equalValues(a, b)
+}
+
+// equalKeyValues checks whether the given values of the given key of a multi-valued attribute are assigned and equal.
+func equalKeyValues(attr schema.CoreAttribute, key string, a, b interface{}) bool {
+	if isEmptyValue(a) || isEmptyValue(b) {
+		return false
+	}
+	if attr.AttributeType() == "complex" {
+		attr, _ = attr.SubAttributes().ContainsAttribute(key)
+	}
+	return equalAttributeValues(attr, a, b)
+}
+
+// identifyingFilter returns a value filter that selects only the given value among the given values of the given
+// multi-valued attribute, e.g., `type eq "work"`. If an updated value is given, the filter also selects only that value
+// among the updated values. Attributes that are not complex are filtered on their "value" sub-attribute.
+func identifyingFilter(attr schema.CoreAttribute, value, updated interface{}, values, updatedValues []interface{}) (filter.Expression, bool) {
+	keys := diffKeys
+	if attr.AttributeType() != "complex" {
+		keys = []string{"value"}
+	}
+	for _, key := range keys {
+		v := keyValue(attr, key, value)
+		if countKeyValues(attr, key, v, values) != 1 {
+			continue
+		}
+		if updated != nil {
+			if !equalKeyValues(attr, key, v, keyValue(attr, key, updated)) || countKeyValues(attr, key, v, updatedValues) != 1 {
+				continue
+			}
+		}
+		if e, err := f.Attr(key).Eq(v).Expression(); err == nil {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// isEmptyValue checks whether the given value is unassigned, i.e., null, an empty array or an empty complex value.
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if m, ok := asMap(value); ok {
+		return len(m) == 0
+	}
+	switch value.(type) {
+	case []interface{}, []map[string]interface{}:
+		return len(toSlice(value)) == 0
+	default:
+		return false
+	}
+}
+
+// keyValue returns the value of the given key of the given value of a multi-valued attribute. Values of attributes
+// that are not complex are their own "value".
+func keyValue(attr schema.CoreAttribute, key string, value interface{}) interface{} {
+	if attr.AttributeType() != "complex" {
+		if key == "value" {
+			return value
+		}
+		return nil
+	}
+	if _, ok := attr.SubAttributes().ContainsAttribute(key); !ok {
+		return nil
+	}
+	return lookupAttribute(value, key)
+}
+
+// newPatchOperations returns a single PATCH operation with the given path, e.g., `emails[type eq "work"].value`.
+func newPatchOperations(op string, path filter.Path, value interface{}) []PatchOperation {
+	return []PatchOperation{{
+		Op:    op,
+		Path:  &path,
+		Value: value,
+	}}
+}
+
+// subAttributePath returns the path of the sub-attribute with the given name of the attribute at the given path, e.g.,
+// "name.givenName" or `emails[type eq "work"].value`.
+func subAttributePath(path filter.Path, name string) filter.Path {
+	if path.ValueExpression != nil {
+		path.SubAttribute = &name
+	} else {
+		path.AttributePath.SubAttribute = &name
+	}
+	return path
+}
+
+// uniqueMatch returns the index of the single unmatched value in to of which the given key equals the key of the given
+// value, if the key also identifies the value among the unmatched values in from.
+func uniqueMatch(attr schema.CoreAttribute, key string, value interface{}, from, to []interface{}, matches []int, matched []bool) (int, bool) {
+	v := keyValue(attr, key, value)
+
+	var n int
+	for i, a := range from {
+		if matches[i] == -1 && equalKeyValues(attr, key, v, keyValue(attr, key, a)) {
+			n++
+		}
+	}
+	if n != 1 {
+		return -1, false
+	}
+
+	index := -1
+	for j, b := range to {
+		if matched[j] || !equalKeyValues(attr, key, v, keyValue(attr, key, b)) {
+			continue
+		}
+		if index != -1 {
+			return -1, false
+		}
+		index = j
+	}
+	return index, index != -1
+}
+
+// valuePath returns the path of the values of the multi-valued attribute at the given path that match the given value
+// filter, e.g., `emails[type eq "work"]`.
+func valuePath(path filter.Path, valueFilter filter.Expression) filter.Path {
+	path.ValueExpression = valueFilter
+	return path
+}
+
+// writableElement returns a copy of the given singular value without its read-only sub-attributes.
+func writableElement(attr schema.CoreAttribute, value interface{}) interface{} {
+	m, ok := asMap(value)
+	if !ok || attr.AttributeType() != "complex" {
+		return copyValue(value)
+	}
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if subAttr, ok := attr.SubAttributes().ContainsAttribute(k); ok && subAttr.Mutability() == "readOnly" {
+			continue
+		}
+		c[k] = copyValue(v)
+	}
+	return c
+}
+
+// writableValue returns a copy of the given value without its read-only sub-attributes.
+func writableValue(attr schema.CoreAttribute, value interface{}) interface{} {
+	if !attr.MultiValued() {
+		return writableElement(attr, value)
+	}
+	values := toSlice(value)
+	c := make([]interface{}, len(values))
+	for i, v := range values {
+		c[i] = writableElement(attr, v)
+	}
+	return c
+}
+
+// Diff returns the PATCH operations that change the given attributes into the other given attributes, e.g., to
+// synchronise a resource with its state in a source of truth. Attributes are compared according to the schemas of the
+// resource type: strings are compared case-insensitively unless the attribute is case-exact, and read-only attributes
+// and attributes that are not defined by the schemas are ignored. Multi-valued attributes are compared as unordered
+// sets, of which the values are identified by their "value" or "type" sub-attribute. Updates of identified values
+// target them with a value filter, e.g., `emails[type eq "work"].value`.
+//
+// Applying the operations to the first attributes with ApplyPatch results in the second attributes, apart from their
+// read-only attributes.
+func (t ResourceType) Diff(from, to ResourceAttributes) ([]PatchOperation, error) {
+	var operations []PatchOperation
+	diff := func(uri *string, attrs schema.Attributes, from, to interface{}) error {
+		for _, attr := range attrs {
+			if attr.Mutability() == "readOnly" {
+				continue
+			}
+			ops, err := diffAttribute(
+				filter.Path{AttributePath: filter.AttributePath{URIPrefix: uri, AttributeName: attr.Name()}}, attr,
+				lookupAttribute(from, attr.Name()), lookupAttribute(to, attr.Name()),
+			)
+			if err != nil {
+				return err
+			}
+			operations = append(operations, ops...)
+		}
+		return nil
+	}
+
+	if err := diff(nil, t.schemaWithCommon().Attributes, from, to); err != nil {
+		return nil, err
+	}
+	for _, extension := range t.SchemaExtensions {
+		uri := extension.Schema.ID
+		if err := diff(
+			&uri, extension.Schema.Attributes,
+			lookupAttribute(from, extension.Schema.ID), lookupAttribute(to, extension.Schema.ID),
+		); err != nil {
+			return nil, err
+		}
+	}
+	return operations, nil
+}
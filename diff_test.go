@@ -0,0 +1,239 @@
+package scim
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+func TestResourceTypeDiff(t *testing.T) {
+	const enterprise = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+	resourceType := ResourceType{
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema:   schema.CoreUserSchema(),
+		SchemaExtensions: []SchemaExtension{
+			{Schema: schema.ExtensionEnterpriseUser()},
+		},
+	}
+
+	from := `{
+		"userName": "alice",
+		"name": {"familyName": "Smith", "givenName": "Alice"},
+		"emails": [
+			{"value": "alice@example.com", "type": "work", "primary": true},
+			{"value": "alice@example.org", "type": "home"}
+		],
+		"phoneNumbers": [
+			{"value": "555-0100", "type": "work"},
+			{"value": "555-0101", "type": "work"}
+		],
+		"groups": [{"value": "g1", "display": "Group"}],
+		"` + enterprise + `": {"employeeNumber": "42", "manager": {"value": "m1"}}
+	}`
+
+	for _, test := range []struct {
+		name     string
+		to       string
+		expected []string
+	}{
+		{name: "unchanged", to: from},
+		{
+			name: "case-insensitive and unordered",
+			to: `{
+				"USERNAME": "Alice",
+				"name": {"familyName": "Smith", "givenName": "Alice"},
+				"emails": [
+					{"value": "alice@example.org", "type": "home"},
+					{"value": "alice@example.com", "type": "work", "primary": true}
+				],
+				"phoneNumbers": [
+					{"value": "555-0101", "type": "work"},
+					{"value": "555-0100", "type": "work"}
+				],
+				"` + enterprise + `": {"employeeNumber": "42", "manager": {"value": "m1"}}
+			}`,
+		},
+		{
+			name: "simple and complex attributes",
+			to: `{
+				"userName": "alice",
+				"displayName": "Alice Smith",
+				"name": {"givenName": "Ally"},
+				"emails": [
+					{"value": "alice@example.com", "type": "work", "primary": true},
+					{"value": "alice@example.org", "type": "home"}
+				],
+				"phoneNumbers": [
+					{"value": "555-0100", "type": "work"},
+					{"value": "555-0101", "type": "work"}
+				]
+			}`,
+			expected: []string{
+				`remove name.familyName`,
+				`replace name.givenName "Ally"`,
+				`replace displayName "Alice Smith"`,
+				`remove ` + enterprise + `:employeeNumber`,
+				`remove ` + enterprise + `:manager`,
+			},
+		},
+		{
+			name: "value paths",
+			to: `{
+				"userName": "alice",
+				"name": {"familyName": "Smith", "givenName": "Alice"},
+				"emails": [
+					{"value": "a.smith@example.com", "type": "work", "primary": true},
+					{"value": "alice@example.net", "type": "other"}
+				],
+				"phoneNumbers": [
+					{"value": "555-0100", "type": "home"},
+					{"value": "555-0101", "type": "work"}
+				],
+				"` + enterprise + `": {"employeeNumber": "43", "manager": {"value": "m2"}}
+			}`,
+			expected: []string{
+				`remove emails[value eq "alice@example.org"]`,
+				`replace emails[type eq "work"].value "a.smith@example.com"`,
+				`add emails [{"type":"other","value":"alice@example.net"}]`,
+				`replace phoneNumbers[value eq "555-0100"].type "home"`,
+				`replace ` + enterprise + `:employeeNumber "43"`,
+				`replace ` + enterprise + `:manager.value "m2"`,
+			},
+		},
+		{
+			name: "ambiguous values",
+			to: `{
+				"userName": "alice",
+				"name": {"familyName": "Smith", "givenName": "Alice"},
+				"emails": [
+					{"value": "alice@example.com", "type": "work", "primary": true},
+					{"value": "alice@example.org", "type": "home"}
+				],
+				"phoneNumbers": [
+					{"value": "555-0102", "type": "work"},
+					{"value": "555-0103", "type": "work"}
+				],
+				"` + enterprise + `": {"employeeNumber": "42", "manager": {"value": "m1"}}
+			}`,
+			expected: []string{
+				`remove phoneNumbers[value eq "555-0100"]`,
+				`remove phoneNumbers[value eq "555-0101"]`,
+				`add phoneNumbers [{"type":"work","value":"555-0102"},{"type":"work","value":"555-0103"}]`,
+			},
+		},
+		{
+			name: "removed attributes",
+			to:   `{"userName": "bob"}`,
+			expected: []string{
+				`replace userName "bob"`,
+				`remove name`,
+				`remove emails`,
+				`remove phoneNumbers`,
+				`remove ` + enterprise + `:employeeNumber`,
+				`remove ` + enterprise + `:manager`,
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var fromAttributes, toAttributes ResourceAttributes
+			assertUnmarshalNoError(t, unmarshal([]byte(from), &fromAttributes))
+			assertUnmarshalNoError(t, unmarshal([]byte(test.to), &toAttributes))
+
+			operations, err := resourceType.Diff(fromAttributes, toAttributes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actual, raw []string
+			for _, op := range operations {
+				s := op.Op + " " + op.Path.String()
+				if op.Value != nil {
+					value, _ := json.Marshal(op.Value)
+					s += " " + string(value)
+				}
+				actual = append(actual, s)
+
+				operation, _ := json.Marshal(map[string]interface{}{
+					"op":    op.Op,
+					"path":  op.Path.String(),
+					"value": op.Value,
+				})
+				raw = append(raw, string(operation))
+			}
+			assertEqual(t, strings.Join(test.expected, "\n"), strings.Join(actual, "\n"))
+			if len(operations) == 0 {
+				return
+			}
+
+			// The operations pass validation and result in the expected attributes.
+			patched, _, err := resourceType.ApplyPatch(
+				fromAttributes,
+				validTestPatchOperations(t, resourceType, strings.Join(raw, ",")),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			remaining, err := resourceType.Diff(patched, toAttributes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(remaining) != 0 {
+				t.Errorf("unexpected remaining operations: %v", remaining)
+			}
+		})
+	}
+}
+
+func TestResourceTypeDiffEscapedValues(t *testing.T) {
+	resourceType := ResourceType{
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema:   schema.CoreUserSchema(),
+	}
+
+	var from, to ResourceAttributes
+	assertUnmarshalNoError(t, unmarshal([]byte(`{
+		"userName": "alice",
+		"emails": [
+			{"value": "x\"y@example.com", "type": "work"},
+			{"value": "a\\b@example.com", "type": "home"},
+			{"value": "alice@example.com", "type": "other"}
+		]
+	}`), &from))
+	assertUnmarshalNoError(t, unmarshal([]byte(`{
+		"userName": "alice",
+		"emails": [
+			{"value": "a\\b@example.com", "type": "home", "primary": true},
+			{"value": "alice@example.com", "type": "other"}
+		]
+	}`), &to))
+
+	operations, err := resourceType.Diff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(operations) != 2 {
+		t.Fatalf("expected 2 operations, got %v", operations)
+	}
+	remove := operations[0].Path.ValueExpression.(*filter.AttributeExpression)
+	assertEqual(t, `x"y@example.com`, remove.CompareValue)
+
+	patched, changed, err := resourceType.ApplyPatch(from, operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("expected the operations to change the attributes")
+	}
+	remaining, err := resourceType.Diff(patched, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("unexpected remaining operations: %v", remaining)
+	}
+}
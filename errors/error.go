@@ -70,6 +70,24 @@ var (
 		Detail:   "The specified request cannot be completed, due to the passing of sensitive information in a request URI.",
 		Status:   http.StatusForbidden,
 	}
+	// ScimErrorInvalidCursor returns an 400 SCIM error with a detailed message.
+	ScimErrorInvalidCursor = ScimError{
+		ScimType: ScimTypeInvalidCursor,
+		Detail:   "The cursor value is invalid.",
+		Status:   http.StatusBadRequest,
+	}
+	// ScimErrorExpiredCursor returns an 400 SCIM error with a detailed message.
+	ScimErrorExpiredCursor = ScimError{
+		ScimType: ScimTypeExpiredCursor,
+		Detail:   "The cursor has expired.",
+		Status:   http.StatusBadRequest,
+	}
+	// ScimErrorPreconditionFailed returns an 412 SCIM error for a modification of a resource that was modified in the
+	// meantime.
+	ScimErrorPreconditionFailed = ScimError{
+		Detail: "The resource was modified in the meantime.",
+		Status: http.StatusPreconditionFailed,
+	}
 	// ScimErrorInternal returns an 500 SCIM error without a message.
 	ScimErrorInternal = ScimError{
 		Status: http.StatusInternalServerError,
@@ -266,4 +284,8 @@ const (
 	ScimTypeInvalidVersion ScimType = "invalidVers"
 	// ScimTypeSensitive indicates that the specified request cannot be completed, due to the passing of sensitive information in a request URI.
 	ScimTypeSensitive ScimType = "sensitive"
+	// ScimTypeInvalidCursor indicates that the cursor value is invalid, e.g., unrecognized or malformed. Source: RFC9865.
+	ScimTypeInvalidCursor ScimType = "invalidCursor"
+	// ScimTypeExpiredCursor indicates that the cursor has expired. Source: RFC9865.
+	ScimTypeExpiredCursor ScimType = "expiredCursor"
 )
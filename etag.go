@@ -0,0 +1,101 @@
+package scim
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+// ExpectedVersion returns the version that the resource targeted by the given request had when its "If-Match" and
+// "If-None-Match" preconditions were evaluated. It is only present on PUT, PATCH and DELETE requests that carry a
+// precondition and on the Replace call of a PATCH request that is applied by the server, see ResourcePatcher. Resource
+// handlers can use it to perform an atomic compare-and-swap and return a 412 Precondition Failed SCIM error if the
+// resource was modified in the meantime.
+func ExpectedVersion(r *http.Request) (string, bool) {
+	version, ok := r.Context().Value(expectedVersionKey{}).(string)
+	return version, ok
+}
+
+// entityTagsMatch reports whether one of the given entity tags, or "*", matches the given version. Tags are compared
+// using the weak comparison function, as described in RFC 7232 Section 2.3.2.
+func entityTagsMatch(tags []string, version string) bool {
+	for _, header := range tags {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" {
+				return true
+			}
+			if tag != "" && version != "" && opaqueTag(tag) == opaqueTag(version) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasPreconditions reports whether the given request contains an "If-Match" or "If-None-Match" header.
+func hasPreconditions(r *http.Request) bool {
+	return len(r.Header.Values("If-Match")) != 0 || len(r.Header.Values("If-None-Match")) != 0
+}
+
+// opaqueTag strips the weakness indicator and the surrounding quotes from the given entity tag.
+func opaqueTag(tag string) string {
+	tag = strings.TrimPrefix(tag, "W/")
+	if len(tag) >= 2 && strings.HasPrefix(tag, `"`) && strings.HasSuffix(tag, `"`) {
+		return tag[1 : len(tag)-1]
+	}
+	return tag
+}
+
+// preconditionFailed returns the SCIM error for a request whose preconditions did not pass.
+func preconditionFailed() *errors.ScimError {
+	return &errors.ScimError{
+		Detail: "The version of the resource does not match the precondition of the request.",
+		Status: http.StatusPreconditionFailed,
+	}
+}
+
+// preconditionStatus evaluates the preconditions of the given request against the current version of the targeted
+// resource. It returns 0 if all preconditions pass, 304 Not Modified if a GET request does not need to be served and
+// 412 Precondition Failed otherwise.
+func preconditionStatus(r *http.Request, version string) int {
+	if ifMatch := r.Header.Values("If-Match"); len(ifMatch) != 0 && !entityTagsMatch(ifMatch, version) {
+		return http.StatusPreconditionFailed
+	}
+	if ifNoneMatch := r.Header.Values("If-None-Match"); len(ifNoneMatch) != 0 && entityTagsMatch(ifNoneMatch, version) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return http.StatusNotModified
+		}
+		return http.StatusPreconditionFailed
+	}
+	return 0
+}
+
+// checkPreconditions evaluates the preconditions of a PUT, PATCH or DELETE request by fetching the current version of
+// the targeted resource. It returns the request to pass to the resource handler, which carries the fetched version, and
+// false if the preconditions did not pass, in which case an error has already been written.
+func (s Server) checkPreconditions(w http.ResponseWriter, r *http.Request, id string, resourceType ResourceType) (*http.Request, bool) {
+	if !s.config.SupportETag || !hasPreconditions(r) {
+		return r, true
+	}
+
+	resource, getErr := resourceType.Handler.Get(r, id)
+	if getErr != nil {
+		scimErr := errors.CheckScimError(getErr, r.Method)
+		s.errorHandler(w, &scimErr)
+		return r, false
+	}
+
+	if preconditionStatus(r, resource.Meta.Version) != 0 {
+		s.errorHandler(w, preconditionFailed())
+		return r, false
+	}
+
+	ctx := context.WithValue(r.Context(), expectedVersionKey{}, resource.Meta.Version)
+	return r.WithContext(ctx), true
+}
+
+// expectedVersionKey is the context key of the version returned by ExpectedVersion.
+type expectedVersionKey struct{}
@@ -0,0 +1,243 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/optional"
+)
+
+func TestEntityTagsMatch(t *testing.T) {
+	tests := []struct {
+		tags     []string
+		version  string
+		expected bool
+	}{
+		{tags: []string{`"v1"`}, version: "v1", expected: true},
+		{tags: []string{`W/"v1"`}, version: "v1", expected: true},
+		{tags: []string{`"v1"`}, version: `W/"v1"`, expected: true},
+		{tags: []string{`"v0", "v1"`}, version: "v1", expected: true},
+		{tags: []string{`"v0"`, `"v1"`}, version: "v1", expected: true},
+		{tags: []string{"*"}, version: "", expected: true},
+		{tags: []string{`"v2"`}, version: "v1", expected: false},
+		{tags: []string{`""`}, version: "", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.tags, ","), func(t *testing.T) {
+			assertEqual(t, test.expected, entityTagsMatch(test.tags, test.version))
+		})
+	}
+}
+
+func TestServerETagAdvertised(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil)
+	rr := httptest.NewRecorder()
+	newTestServerWithETag(t).ServeHTTP(rr, req)
+
+	var config struct {
+		ETag struct {
+			Supported bool
+		}
+	}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &config))
+	assertTrue(t, config.ETag.Supported)
+}
+
+func TestServerETagExpectedVersion(t *testing.T) {
+	handler := &testVersionRecordingHandler{testResourceHandler: newTestResourceHandler().(testResourceHandler)}
+	s := newTestServerWithETag(t)
+	s.resourceTypes[0].Handler = handler
+
+	req := httptest.NewRequest(http.MethodDelete, "/Users/0001", nil)
+	req.Header.Set("If-Match", `W/"v1"`)
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusNoContent, rr.Code)
+	assertTrue(t, handler.present)
+	assertEqual(t, "v1", handler.version)
+
+	req = httptest.NewRequest(http.MethodDelete, "/Users/0002", nil)
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusNoContent, rr.Code)
+	assertFalse(t, handler.present)
+}
+
+func TestServerETagPreconditions(t *testing.T) {
+	const (
+		user  = `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "test01"}`
+		patch = `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [{"op": "replace", "path": "userName", "value": "patched"}]
+		}`
+	)
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		header         string
+		tag            string
+		expectedStatus int
+	}{
+		{
+			name:           "GET with matching If-None-Match",
+			method:         http.MethodGet,
+			target:         "/Users/0001",
+			header:         "If-None-Match",
+			tag:            `W/"v1"`,
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "GET with different If-None-Match",
+			method:         http.MethodGet,
+			target:         "/Users/0001",
+			header:         "If-None-Match",
+			tag:            `W/"v2"`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "GET with different If-Match",
+			method:         http.MethodGet,
+			target:         "/Users/0001",
+			header:         "If-Match",
+			tag:            `"v2"`,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "PUT with matching If-Match",
+			method:         http.MethodPut,
+			target:         "/Users/0001",
+			body:           user,
+			header:         "If-Match",
+			tag:            `"v1"`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "PUT with wildcard If-Match",
+			method:         http.MethodPut,
+			target:         "/Users/0001",
+			body:           user,
+			header:         "If-Match",
+			tag:            "*",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "PUT with different If-Match",
+			method:         http.MethodPut,
+			target:         "/Users/0001",
+			body:           user,
+			header:         "If-Match",
+			tag:            `"v2"`,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "PATCH with matching If-Match",
+			method:         http.MethodPatch,
+			target:         "/Users/0001",
+			body:           patch,
+			header:         "If-Match",
+			tag:            `W/"v1"`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "PATCH with different If-Match",
+			method:         http.MethodPatch,
+			target:         "/Users/0001",
+			body:           patch,
+			header:         "If-Match",
+			tag:            `W/"v2"`,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "DELETE with matching If-Match",
+			method:         http.MethodDelete,
+			target:         "/Users/0001",
+			header:         "If-Match",
+			tag:            `"v1"`,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "DELETE with wildcard If-None-Match",
+			method:         http.MethodDelete,
+			target:         "/Users/0001",
+			header:         "If-None-Match",
+			tag:            "*",
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "DELETE of unknown resource",
+			method:         http.MethodDelete,
+			target:         "/Users/9999",
+			header:         "If-Match",
+			tag:            `"v1"`,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			req.Header.Set(test.header, test.tag)
+			rr := httptest.NewRecorder()
+			newTestServerWithETag(t).ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, test.expectedStatus, rr.Code)
+			if rr.Code == http.StatusNotModified {
+				assertEqual(t, "v1", rr.Header().Get("Etag"))
+				assertEqual(t, 0, rr.Body.Len())
+			}
+		})
+	}
+}
+
+func TestServerETagUnsupported(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/Users/0001", nil)
+	req.Header.Set("If-Match", `"v2"`)
+	rr := httptest.NewRecorder()
+	newTestServer(t).ServeHTTP(rr, req)
+
+	assertEqualStatusCode(t, http.StatusNoContent, rr.Code)
+}
+
+func newTestServerWithETag(t *testing.T) Server {
+	s, err := NewServer(
+		&ServerArgs{
+			ServiceProviderConfig: &ServiceProviderConfig{
+				SupportETag: true,
+			},
+			ResourceTypes: []ResourceType{
+				{
+					ID:       optional.NewString("User"),
+					Name:     "User",
+					Endpoint: "/Users",
+					Schema:   getUserSchema(),
+					Handler:  newTestResourceHandler(),
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// testVersionRecordingHandler records the expected version of the last delete request.
+type testVersionRecordingHandler struct {
+	testResourceHandler
+	version string
+	present bool
+}
+
+func (h *testVersionRecordingHandler) Delete(r *http.Request, id string) error {
+	h.version, h.present = ExpectedVersion(r)
+	return h.testResourceHandler.Delete(r, id)
+}
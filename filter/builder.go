@@ -0,0 +1,339 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+const (
+	precedenceOr = iota + 1
+	precedenceAnd
+	precedencePrimary
+)
+
+// Attr returns a builder of expressions on the attribute with the given path, e.g., "userName", "name.givenName" or
+// "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber".
+func Attr(path string) AttributeBuilder {
+	attrPath, err := filter.ParseAttrPath([]byte(path))
+	if err != nil {
+		return AttributeBuilder{err: fmt.Errorf("invalid attribute path %q: %v", path, err)}
+	}
+	return AttributeBuilder{path: path, attrPath: attrPath}
+}
+
+// Not returns a builder of the negation of the given filter.
+func Not(b Builder) Builder {
+	if b.err == nil && b.text == "" {
+		return Builder{err: fmt.Errorf("can not negate an empty filter")}
+	}
+	return Builder{
+		text:       "not (" + b.text + ")",
+		expr:       &filter.NotExpression{Expression: b.expr},
+		precedence: precedencePrimary,
+		err:        b.err,
+	}
+}
+
+// compareValue returns the given compare value as it is contained in a filter returned by ParseFilter, i.e., an
+// unescaped string, an int, a float64, a bool or nil.
+func compareValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, string:
+		return v, nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case json.Number:
+		if i, err := strconv.Atoi(v.String()); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	default:
+		return strconv.Atoi(fmt.Sprintf("%d", v))
+	}
+}
+
+// formatValue returns the given compare value as (canonical) filter text. Strings are quoted and escaped, time.Time
+// values are formatted as xsd:dateTime strings and whole floats keep a fraction, so that they are parsed as decimals.
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case string:
+		return quote(v), nil
+	case time.Time:
+		return quote(v.Format(time.RFC3339Nano)), nil
+	case json.Number:
+		if _, err := v.Float64(); err != nil {
+			return "", fmt.Errorf("invalid number: %s", v)
+		}
+		return v.String(), nil
+	case float32:
+		return formatFloat(float64(v), 32)
+	case float64:
+		return formatFloat(v, 64)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), nil
+	default:
+		return "", fmt.Errorf("unsupported compare value of type %T", value)
+	}
+}
+
+// formatFloat returns the given float as filter text.
+func formatFloat(f float64, bitSize int) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("invalid number: %v", f)
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s, nil
+}
+
+// join returns a builder that joins the given filters with the given logical operator.
+func join(op filter.LogicalOperator, precedence int, bs []Builder) Builder {
+	var (
+		texts = make([]string, len(bs))
+		expr  filter.Expression
+	)
+	for i, b := range bs {
+		if b.err != nil {
+			return Builder{err: b.err}
+		}
+		if b.text == "" {
+			return Builder{err: fmt.Errorf("can not combine an empty filter")}
+		}
+		texts[i] = b.text
+		if b.precedence < precedence {
+			texts[i] = "(" + b.text + ")"
+		}
+		// Just like the parser, the logical operators are left-associative.
+		if expr == nil {
+			expr = b.expr
+		} else {
+			expr = &filter.LogicalExpression{Left: expr, Right: b.expr, Operator: op}
+		}
+	}
+	return Builder{
+		text:       strings.Join(texts, " "+string(op)+" "),
+		expr:       expr,
+		precedence: precedence,
+	}
+}
+
+// quote returns the given string as a quoted and escaped filter string. Only the escape sequences of JSON strings are
+// used, with upper case hexadecimal digits.
+func quote(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04X`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// AttributeBuilder builds expressions on an attribute, see Attr.
+type AttributeBuilder struct {
+	path     string
+	attrPath filter.AttributePath
+	err      error
+}
+
+// Co returns a builder of the expression that the attribute contains the given value.
+func (a AttributeBuilder) Co(value interface{}) Builder {
+	return a.compare(filter.CO, value)
+}
+
+// Eq returns a builder of the expression that the attribute equals the given value.
+func (a AttributeBuilder) Eq(value interface{}) Builder {
+	return a.compare(filter.EQ, value)
+}
+
+// Ew returns a builder of the expression that the attribute ends with the given value.
+func (a AttributeBuilder) Ew(value interface{}) Builder {
+	return a.compare(filter.EW, value)
+}
+
+// Ge returns a builder of the expression that the attribute is greater than or equal to the given value.
+func (a AttributeBuilder) Ge(value interface{}) Builder {
+	return a.compare(filter.GE, value)
+}
+
+// Gt returns a builder of the expression that the attribute is greater than the given value.
+func (a AttributeBuilder) Gt(value interface{}) Builder {
+	return a.compare(filter.GT, value)
+}
+
+// Le returns a builder of the expression that the attribute is less than or equal to the given value.
+func (a AttributeBuilder) Le(value interface{}) Builder {
+	return a.compare(filter.LE, value)
+}
+
+// Lt returns a builder of the expression that the attribute is less than the given value.
+func (a AttributeBuilder) Lt(value interface{}) Builder {
+	return a.compare(filter.LT, value)
+}
+
+// Ne returns a builder of the expression that the attribute does not equal the given value.
+func (a AttributeBuilder) Ne(value interface{}) Builder {
+	return a.compare(filter.NE, value)
+}
+
+// Pr returns a builder of the expression that the attribute has a value.
+func (a AttributeBuilder) Pr() Builder {
+	if a.err != nil {
+		return Builder{err: a.err}
+	}
+	return Builder{
+		text: a.path + " " + string(filter.PR),
+		expr: &filter.AttributeExpression{
+			AttributePath: a.attrPath,
+			Operator:      filter.PR,
+		},
+		precedence: precedencePrimary,
+	}
+}
+
+// Sw returns a builder of the expression that the attribute starts with the given value.
+func (a AttributeBuilder) Sw(value interface{}) Builder {
+	return a.compare(filter.SW, value)
+}
+
+// Where returns a builder of the value path that applies the given filter to the values of the (multi-valued)
+// attribute, e.g., `Attr("members").Where(Attr("value").Eq("2819c223"))` builds `members[value eq "2819c223"]`.
+func (a AttributeBuilder) Where(b Builder) Builder {
+	switch {
+	case a.err != nil:
+		return Builder{err: a.err}
+	case b.err != nil:
+		return Builder{err: b.err}
+	case b.text == "":
+		return Builder{err: fmt.Errorf("the value filter of %s is empty", a.path)}
+	}
+	return Builder{
+		text: a.path + "[" + b.text + "]",
+		expr: &filter.ValuePath{
+			AttributePath: a.attrPath,
+			ValueFilter:   b.expr,
+		},
+		precedence: precedencePrimary,
+	}
+}
+
+// compare returns a builder of the expression that compares the attribute with the given value.
+func (a AttributeBuilder) compare(op filter.CompareOperator, value interface{}) Builder {
+	if a.err != nil {
+		return Builder{err: a.err}
+	}
+	text, err := formatValue(value)
+	if err != nil {
+		return Builder{err: fmt.Errorf("invalid value for %s %s: %v", a.path, op, err)}
+	}
+	v, err := compareValue(value)
+	if err != nil {
+		return Builder{err: fmt.Errorf("invalid value for %s %s: %v", a.path, op, err)}
+	}
+	return Builder{
+		text: a.path + " " + string(op) + " " + text,
+		expr: &filter.AttributeExpression{
+			AttributePath: a.attrPath,
+			Operator:      op,
+			CompareValue:  v,
+		},
+		precedence: precedencePrimary,
+	}
+}
+
+// Builder builds filters programmatically, e.g., `Attr("userName").Eq("bjensen").And(Attr("title").Pr())`. Builders
+// are immutable and errors, e.g., invalid attribute paths or unsupported compare values, are reported by Expression
+// and Validator.
+type Builder struct {
+	// text is the canonical filter text.
+	text string
+	// expr is the filter, which contains the compare values as given, i.e., without the escape sequences of the text.
+	expr filter.Expression
+	// precedence is the precedence of the outer operator of the filter.
+	precedence int
+	err        error
+}
+
+// And returns a builder of the conjunction of the filter and the given filters.
+func (b Builder) And(bs ...Builder) Builder {
+	return join(filter.AND, precedenceAnd, append([]Builder{b}, bs...))
+}
+
+// Expression returns the filter, equal to the result of ParseFilter on its text. Its string compare values are not
+// escaped, so that they equal the given values.
+func (b Builder) Expression() (filter.Expression, error) {
+	switch {
+	case b.err != nil:
+		return nil, b.err
+	case b.expr == nil:
+		return nil, fmt.Errorf("empty filter")
+	}
+	return b.expr, nil
+}
+
+// Or returns a builder of the disjunction of the filter and the given filters.
+func (b Builder) Or(bs ...Builder) Builder {
+	return join(filter.OR, precedenceOr, append([]Builder{b}, bs...))
+}
+
+// String returns the filter as canonical filter text: lower case operators, escaped strings and only the parentheses
+// that are needed. An empty string is returned if the filter is invalid.
+func (b Builder) String() string {
+	if b.err != nil {
+		return ""
+	}
+	return b.text
+}
+
+// Validator returns a validator of the filter, that is validated against the given reference schema and extensions.
+// Just like NewValidator, the common attributes (e.g., "meta") are only valid if they are part of the schema.
+func (b Builder) Validator(s schema.Schema, exts ...schema.Schema) (Validator, error) {
+	e, err := b.Expression()
+	if err != nil {
+		return Validator{}, err
+	}
+	v := NewFilterValidator(e, s, exts...)
+	if err := v.Validate(); err != nil {
+		return Validator{}, err
+	}
+	return v, nil
+}
@@ -0,0 +1,168 @@
+package filter_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	internal "github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+func TestBuilder(t *testing.T) {
+	for _, test := range []struct {
+		builder  internal.Builder
+		expected string
+	}{
+		{builder: internal.Attr("userName").Eq("bjensen"), expected: `userName eq "bjensen"`},
+		{builder: internal.Attr("userName").Eq(`a "quoted" \ value`), expected: `userName eq "a \"quoted\" \\ value"`},
+		{builder: internal.Attr("userName").Eq("tab\tnul\x00é"), expected: `userName eq "tab\tnul\u0000é"`},
+		{builder: internal.Attr("title").Pr(), expected: `title pr`},
+		{builder: internal.Attr("active").Ne(false), expected: `active ne false`},
+		{builder: internal.Attr("x509Certificates").Eq(nil), expected: `x509Certificates eq null`},
+		{builder: internal.Attr("age").Gt(int64(18)), expected: `age gt 18`},
+		{builder: internal.Attr("weight").Le(2.0), expected: `weight le 2.0`},
+		{builder: internal.Attr("weight").Lt(json.Number("1.5e3")), expected: `weight lt 1.5e3`},
+		{
+			builder:  internal.Attr("meta.lastModified").Gt(time.Date(2011, 5, 13, 4, 42, 34, 0, time.UTC)),
+			expected: `meta.lastModified gt "2011-05-13T04:42:34Z"`,
+		},
+		{
+			builder:  internal.Attr("members").Where(internal.Attr("value").Eq("2819c223")),
+			expected: `members[value eq "2819c223"]`,
+		},
+		{
+			builder: internal.Attr("userType").Eq("Employee").And(
+				internal.Attr("emails").Co("example.com").Or(internal.Attr("emails.value").Co("example.org")),
+			),
+			expected: `userType eq "Employee" and (emails co "example.com" or emails.value co "example.org")`,
+		},
+		{
+			builder: internal.Attr("title").Pr().And(internal.Attr("userType").Eq("Employee")).Or(
+				internal.Attr("userType").Eq("Intern"),
+				internal.Attr("userType").Eq("Contractor"),
+			),
+			expected: `title pr and userType eq "Employee" or userType eq "Intern" or userType eq "Contractor"`,
+		},
+		{
+			builder:  internal.Not(internal.Attr("userName").Sw("a").Or(internal.Attr("userName").Ew("z"))),
+			expected: `not (userName sw "a" or userName ew "z")`,
+		},
+		{
+			builder: internal.Attr("emails").Where(
+				internal.Attr("type").Eq("work").And(internal.Attr("value").Co("@example.com")),
+			),
+			expected: `emails[type eq "work" and value co "@example.com"]`,
+		},
+	} {
+		t.Run(test.expected, func(t *testing.T) {
+			if s := test.builder.String(); s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+			if _, err := test.builder.Expression(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBuilder_invalid(t *testing.T) {
+	for name, b := range map[string]internal.Builder{
+		"path":          internal.Attr(`userName eq "a" or id`).Pr(),
+		"value":         internal.Attr("userName").Eq(struct{}{}),
+		"NaN":           internal.Attr("weight").Eq(math.NaN()),
+		"empty":         internal.Attr("title").Pr().And(internal.Builder{}),
+		"not empty":     internal.Not(internal.Builder{}),
+		"value path":    internal.Attr("emails").Where(internal.Builder{}),
+		"nested errors": internal.Not(internal.Attr("a").Pr().Or(internal.Attr("b").Eq(make(chan int)))),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := b.Expression(); err == nil {
+				t.Error("expected an error")
+			}
+			if s := b.String(); s != "" {
+				t.Errorf("expected no filter text, got %s", s)
+			}
+		})
+	}
+}
+
+func TestBuilder_Validator(t *testing.T) {
+	userSchema := schema.CoreUserSchema()
+	userSchema.Attributes = append(userSchema.Attributes, schema.CommonAttributes()...)
+
+	validator, err := internal.Attr("emails").Where(internal.Attr("type").Eq("work")).
+		And(internal.Attr("meta.lastModified").Gt("2011-05-13T04:42:34Z")).
+		Validator(userSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validator.PassesFilter(map[string]interface{}{
+		"emails": []interface{}{map[string]interface{}{"type": "work"}},
+		"meta":   map[string]interface{}{"lastModified": "2020-01-01T00:00:00Z"},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	for _, b := range []internal.Builder{
+		internal.Attr("unknown").Eq("a"),
+		internal.Attr("active").Gt(true),
+		internal.Attr("employeeNumber").Pr(),
+	} {
+		if _, err := b.Validator(schema.CoreUserSchema()); err == nil {
+			t.Errorf("(%s) expected an error", b)
+		}
+	}
+	if _, err := internal.Attr("employeeNumber").Pr().Validator(schema.CoreUserSchema(), schema.ExtensionEnterpriseUser()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBuilder_compareValues(t *testing.T) {
+	raw := "a\"b\\c\n"
+	b := internal.Attr("userName").Eq(raw)
+	e, err := b.Expression()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := e.(*filter.AttributeExpression).CompareValue; v != raw {
+		t.Errorf("expected the raw compare value %q, got %q", raw, v)
+	}
+	// The filter text is still a valid (escaped) filter.
+	if _, err := filter.ParseFilter([]byte(b.String())); err != nil {
+		t.Error(err)
+	}
+
+	validator, err := b.Validator(schema.CoreUserSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validator.PassesFilter(map[string]interface{}{"userName": raw}); err != nil {
+		t.Error(err)
+	}
+	if err := validator.PassesFilter(map[string]interface{}{"userName": `a\"b\\c\n`}); err == nil {
+		t.Error("expected the escaped value not to match")
+	}
+
+	// Without escape sequences, the expression equals the parsed filter text.
+	for _, b := range []internal.Builder{
+		internal.Attr("a").Eq("x").And(internal.Attr("b").Gt(1), internal.Attr("c").Pr()),
+		internal.Attr("a").Eq(json.Number("1.5e3")).Or(internal.Not(internal.Attr("b").Le(2.0))),
+		internal.Attr("emails").Where(internal.Attr("type").Eq("work").Or(internal.Attr("primary").Eq(true))),
+	} {
+		e, err := b.Expression()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := filter.ParseFilter([]byte(b.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e, parsed) {
+			t.Errorf("(%s) expected %#v, got %#v", b, parsed, e)
+		}
+	}
+}
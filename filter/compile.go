@@ -0,0 +1,415 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	datetime "github.com/di-wu/xsd-datetime"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+// compareFloats returns -1, 0 or 1 if the given value is less than, equal to or greater than the reference.
+func compareFloats(v, ref float64) int {
+	switch {
+	case v < ref:
+		return -1
+	case v > ref:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareInts returns -1, 0 or 1 if the given value is less than, equal to or greater than the reference.
+func compareInts(v, ref int) int {
+	switch {
+	case v < ref:
+		return -1
+	case v > ref:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareResult converts the result of a three-way comparison to the result of the given operator.
+func compareResult(op filter.CompareOperator, c int) bool {
+	switch op {
+	case filter.EQ:
+		return c == 0
+	case filter.NE:
+		return c != 0
+	case filter.GT:
+		return c > 0
+	case filter.GE:
+		return c >= 0
+	case filter.LT:
+		return c < 0
+	case filter.LE:
+		return c <= 0
+	default:
+		return false
+	}
+}
+
+// compareTimes returns -1, 0 or 1 if the given value is before, equal to or after the reference.
+func compareTimes(v, ref time.Time) int {
+	switch {
+	case v.Before(ref):
+		return -1
+	case v.After(ref):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compileComparison returns a matcher that compares a single value of the given (non-complex) attribute to the given
+// compare value. The compare value is converted once, so that the matcher only has to convert the given value.
+func compileComparison(op filter.CompareOperator, compareValue interface{}, attr schema.CoreAttribute) (matcher, error) {
+	name, typ := attr.Name(), attr.AttributeType()
+	switch typ {
+	case "boolean", "binary":
+		switch op {
+		case filter.GT, filter.LT, filter.GE, filter.LE:
+			return nil, fmt.Errorf("operator %q is not supported for %s attributes", op, typ)
+		}
+	}
+
+	switch typ {
+	case "binary", "reference", "string":
+		ref, ok := compareValue.(string)
+		if !ok {
+			return nil, fmt.Errorf("a %s attribute needs to be compared to a string", typ)
+		}
+		caseExact := typ == "binary" || attr.CaseExact()
+		toString := func(i interface{}) (string, error) {
+			v, ok := i.(string)
+			if !ok {
+				return "", &TypeError{Attribute: name, Type: typ, Value: i}
+			}
+			return v, nil
+		}
+		if isSubstringOperator(op) {
+			return substringMatcher(op, ref, caseExact, toString), nil
+		}
+		if caseExact {
+			return func(i interface{}) (bool, error) {
+				v, err := toString(i)
+				if err != nil {
+					return false, err
+				}
+				return compareResult(op, strings.Compare(v, ref)), nil
+			}, nil
+		}
+		lowerRef := strings.ToLower(ref)
+		return func(i interface{}) (bool, error) {
+			v, err := toString(i)
+			if err != nil {
+				return false, err
+			}
+			switch op {
+			case filter.EQ:
+				return strings.EqualFold(v, ref), nil
+			case filter.NE:
+				return !strings.EqualFold(v, ref), nil
+			default:
+				return compareResult(op, strings.Compare(strings.ToLower(v), lowerRef)), nil
+			}
+		}, nil
+	case "boolean":
+		ref, ok := compareValue.(bool)
+		if !ok {
+			return nil, fmt.Errorf("a boolean attribute needs to be compared to a boolean")
+		}
+		if isSubstringOperator(op) {
+			return substringMatcher(op, strconv.FormatBool(ref), true, func(i interface{}) (string, error) {
+				v, ok := i.(bool)
+				if !ok {
+					return "", &TypeError{Attribute: name, Type: typ, Value: i}
+				}
+				return strconv.FormatBool(v), nil
+			}), nil
+		}
+		return func(i interface{}) (bool, error) {
+			v, ok := i.(bool)
+			if !ok {
+				return false, &TypeError{Attribute: name, Type: typ, Value: i}
+			}
+			return (v == ref) == (op == filter.EQ), nil
+		}, nil
+	case "dateTime":
+		date, ok := compareValue.(string)
+		if !ok {
+			return nil, fmt.Errorf("a dateTime attribute needs to be compared to a string")
+		}
+		ref, err := datetime.Parse(date)
+		if err != nil {
+			return nil, fmt.Errorf("a dateTime attribute needs to be compared to a dateTime")
+		}
+		if isSubstringOperator(op) {
+			return substringMatcher(op, date, false, func(i interface{}) (string, error) {
+				v, ok := i.(string)
+				if !ok {
+					return "", &TypeError{Attribute: name, Type: typ, Value: i}
+				}
+				return v, nil
+			}), nil
+		}
+		return func(i interface{}) (bool, error) {
+			var v time.Time
+			switch value := i.(type) {
+			case string:
+				t, err := datetime.Parse(value)
+				if err != nil {
+					return false, &TypeError{Attribute: name, Type: typ, Value: i}
+				}
+				v = t
+			case time.Time:
+				v = value
+			default:
+				return false, &TypeError{Attribute: name, Type: typ, Value: i}
+			}
+			return compareResult(op, compareTimes(v, ref)), nil
+		}, nil
+	case "decimal":
+		ref, ok := toFloat(compareValue)
+		if !ok {
+			return nil, fmt.Errorf("a decimal attribute needs to be compared to a float/int")
+		}
+		if isSubstringOperator(op) {
+			return substringMatcher(op, fmt.Sprint(ref), true, func(i interface{}) (string, error) {
+				v, ok := toFloat(i)
+				if !ok {
+					return "", &TypeError{Attribute: name, Type: typ, Value: i}
+				}
+				return fmt.Sprint(v), nil
+			}), nil
+		}
+		return func(i interface{}) (bool, error) {
+			v, ok := toFloat(i)
+			if !ok {
+				return false, &TypeError{Attribute: name, Type: typ, Value: i}
+			}
+			return compareResult(op, compareFloats(v, ref)), nil
+		}, nil
+	case "integer":
+		ref, ok := toInteger(compareValue)
+		if !ok {
+			return nil, fmt.Errorf("a integer attribute needs to be compared to a int")
+		}
+		if isSubstringOperator(op) {
+			return substringMatcher(op, strconv.Itoa(ref), true, func(i interface{}) (string, error) {
+				v, ok := toInteger(i)
+				if !ok {
+					return "", &TypeError{Attribute: name, Type: typ, Value: i}
+				}
+				return strconv.Itoa(v), nil
+			}), nil
+		}
+		return func(i interface{}) (bool, error) {
+			v, ok := toInteger(i)
+			if !ok {
+				return false, &TypeError{Attribute: name, Type: typ, Value: i}
+			}
+			return compareResult(op, compareInts(v, ref)), nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("a %s attribute can not be compared to a value", typ)
+	}
+}
+
+// isSubstringOperator checks whether the given operator is one of co, sw or ew.
+func isSubstringOperator(op filter.CompareOperator) bool {
+	return op == filter.CO || op == filter.SW || op == filter.EW
+}
+
+// lookupValue returns the value of the attribute with the given name within the given resource. If the resource does
+// not contain the name, the qualified name (i.e. prefixed with the id of its schema) is tried, if not empty.
+func lookupValue(resource map[string]interface{}, name, qualified string) (interface{}, bool) {
+	if value, ok := resource[name]; ok {
+		return value, true
+	}
+	if qualified == "" {
+		return nil, false
+	}
+	value, ok := resource[qualified]
+	return value, ok
+}
+
+// matchAny applies the matcher to the given value, or to each of its elements if it is multi-valued. A null value or
+// an empty list does not match.
+func matchAny(value interface{}, m matcher) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case []interface{}:
+		var err error
+		for _, value := range v {
+			if value == nil {
+				continue
+			}
+			ok, e := m(value)
+			if ok {
+				return true, nil
+			}
+			if err == nil {
+				err = e
+			}
+		}
+		return false, err
+	default:
+		return m(v)
+	}
+}
+
+// matchSubAttribute applies the matcher to the sub-attribute with the given name of the given complex value, or of
+// each of its elements if it is multi-valued.
+func matchSubAttribute(attr, subAttr string, value interface{}, m matcher) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case map[string]interface{}:
+		return matchAny(v[subAttr], m)
+	case []interface{}:
+		var err error
+		for _, value := range v {
+			var (
+				ok bool
+				e  error
+			)
+			switch value := value.(type) {
+			case nil:
+				continue
+			case map[string]interface{}:
+				ok, e = matchAny(value[subAttr], m)
+			default:
+				e = &TypeError{Attribute: attr, Type: "complex", Value: value}
+			}
+			if ok {
+				return true, nil
+			}
+			if err == nil {
+				err = e
+			}
+		}
+		return false, err
+	default:
+		return false, &TypeError{Attribute: attr, Type: "complex", Value: value}
+	}
+}
+
+// present checks whether the given value is non-empty. Per RFC 7644 Section 3.4.2.2, an attribute is present if it
+// has a non-empty or non-null value, or if it contains a non-empty node for complex attributes.
+func present(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case string:
+		return v != "", nil
+	case map[string]interface{}:
+		for _, value := range v {
+			if ok, _ := present(value); ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return true, nil
+	}
+}
+
+// qualifiedName returns the name of the attribute prefixed with the given schema id, or an empty string if there is
+// no schema id.
+func qualifiedName(id, name string) string {
+	if id == "" {
+		return ""
+	}
+	return id + ":" + name
+}
+
+// substringMatcher returns a matcher that checks whether the string representation of a given value contains, starts
+// with or ends with the reference string, depending on the given operator.
+func substringMatcher(op filter.CompareOperator, ref string, caseExact bool, toString func(interface{}) (string, error)) matcher {
+	if !caseExact {
+		ref = strings.ToLower(ref)
+	}
+	return func(i interface{}) (bool, error) {
+		v, err := toString(i)
+		if err != nil {
+			return false, err
+		}
+		if !caseExact {
+			v = strings.ToLower(v)
+		}
+		switch op {
+		case filter.CO:
+			return strings.Contains(v, ref), nil
+		case filter.SW:
+			return strings.HasPrefix(v, ref), nil
+		default:
+			return strings.HasSuffix(v, ref), nil
+		}
+	}
+}
+
+// toFloat converts the given numeric value to a float64.
+func toFloat(i interface{}) (float64, bool) {
+	switch v := i.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		if v, ok := toInt(i); ok {
+			return float64(v), true
+		}
+		return 0, false
+	}
+}
+
+// toInteger converts the given integer value to an int. Besides the integer types, json numbers and floats without a
+// fractional part are accepted.
+func toInteger(i interface{}) (int, bool) {
+	switch v := i.(type) {
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, false
+		}
+		return int(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err == nil
+	default:
+		return toInt(i)
+	}
+}
+
+// Predicate reports whether the given resource passes a compiled filter. An error is only returned if the resource
+// contains a value that does not match the type of its attribute, in which case it is a *TypeError.
+type Predicate func(resource map[string]interface{}) (bool, error)
+
+// TypeError is returned by a predicate if a value within the resource does not match the type of its attribute.
+type TypeError struct {
+	// Attribute is the name of the attribute that contains the value.
+	Attribute string
+	// Type is the type of the attribute, e.g., "string" or "complex".
+	Type string
+	// Value is the value that does not match the type.
+	Value interface{}
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("the value of the %s attribute %q is not valid: %v", e.Type, e.Attribute, e.Value)
+}
+
+// matcher reports whether a single (non-null) value matches.
+type matcher func(value interface{}) (bool, error)
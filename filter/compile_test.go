@@ -0,0 +1,231 @@
+package filter_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+)
+
+func BenchmarkPredicate(b *testing.B) {
+	userSchema := schema.CoreUserSchema()
+	userSchema.Attributes = append(userSchema.Attributes, schema.CommonAttributes()...)
+	validator, err := filter.NewValidator(
+		`userName sw "user1" and (emails[type eq "work" and value ew "@example.com"] or not (active eq false))`,
+		userSchema,
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	predicate, err := validator.Compile()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	resources := make([]map[string]interface{}, 1000)
+	for i := range resources {
+		resources[i] = map[string]interface{}{
+			"userName": fmt.Sprintf("user%d", i),
+			"active":   i%2 == 0,
+			"emails": []interface{}{
+				map[string]interface{}{"type": "home", "value": fmt.Sprintf("user%d@example.org", i)},
+				map[string]interface{}{"type": "work", "value": fmt.Sprintf("user%d@example.com", i)},
+			},
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := predicate(resources[i%len(resources)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestValidator_Compile(t *testing.T) {
+	userSchema := schema.CoreUserSchema()
+	userSchema.Attributes = append(userSchema.Attributes, schema.SchemasAttributes())
+	userSchema.Attributes = append(userSchema.Attributes, schema.CommonAttributes()...)
+
+	for _, test := range []struct {
+		amount int
+		filter string
+	}{
+		{amount: 1, filter: `userName eq "di-wu"`},
+		{amount: 1, filter: `userName eq "DI-WU"`},
+		{amount: 5, filter: `userName ne "di-wu"`},
+		{amount: 3, filter: `userName co "u"`},
+		{amount: 2, filter: `name.familyName co "d"`},
+		{amount: 2, filter: `userName sw "a"`},
+		{amount: 2, filter: `urn:ietf:params:scim:schemas:core:2.0:User:userName sw "a"`},
+		{amount: 2, filter: `userName ew "n"`},
+		{amount: 6, filter: `userName pr`},
+		{amount: 2, filter: `userName gt "guest"`},
+		{amount: 3, filter: `userName ge "guest"`},
+		{amount: 3, filter: `userName lt "guest"`},
+		{amount: 4, filter: `userName le "guest"`},
+		{amount: 2, filter: `emails[type eq "work"]`},
+		{amount: 1, filter: `name.familyName eq "ad" and userType eq "admin"`},
+		{amount: 2, filter: `name.familyName eq "ad" or userType eq "admin"`},
+		{amount: 5, filter: `not (userName eq "di-wu")`},
+		{amount: 1, filter: `meta.lastModified gt "2011-05-13T04:42:34Z"`},
+		{amount: 2, filter: `schemas eq "urn:ietf:params:scim:schemas:core:2.0:User"`},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			validator, err := filter.NewValidator(test.filter, userSchema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			predicate, err := validator.Compile()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var amount int
+			for _, resource := range testResources() {
+				ok, err := predicate(resource)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ok {
+					amount++
+				}
+				if passes := validator.PassesFilter(resource) == nil; passes != ok {
+					t.Errorf("(%v) predicate returned %t, PassesFilter %t", resource, ok, passes)
+				}
+			}
+			if amount != test.amount {
+				t.Errorf("Expected %d resources to pass, got %d.", test.amount, amount)
+			}
+		})
+	}
+}
+
+func TestValidator_Compile_extensions(t *testing.T) {
+	for _, f := range []string{
+		`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.displayName eq "di-wu"`,
+		`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:organization eq "Elimity"`,
+		`organization eq "Elimity"`,
+	} {
+		validator, err := filter.NewValidator(f, schema.CoreUserSchema(), schema.ExtensionEnterpriseUser())
+		if err != nil {
+			t.Fatal(err)
+		}
+		predicate, err := validator.Compile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var amount int
+		for _, resource := range testResources() {
+			if ok, _ := predicate(resource); ok {
+				amount++
+			}
+		}
+		if amount != 1 {
+			t.Errorf("(%s) expected 1 resource to pass, got %d", f, amount)
+		}
+	}
+}
+
+func TestValidator_Compile_invalid(t *testing.T) {
+	for _, f := range []string{
+		`unknown eq "a"`,
+		`name.unknown eq "a"`,
+		`name eq "a"`,
+		`userName eq 1`,
+		`active gt true`,
+		`meta.created eq "yesterday"`,
+		`name[givenName eq "a"]`,
+	} {
+		userSchema := schema.CoreUserSchema()
+		userSchema.Attributes = append(userSchema.Attributes, schema.CommonAttributes()...)
+		validator, err := filter.NewValidator(f, userSchema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := validator.Compile(); err == nil {
+			t.Errorf("(%s) expected an error", f)
+		}
+	}
+}
+
+func TestValidator_Compile_values(t *testing.T) {
+	ref := schema.Schema{
+		Attributes: []schema.CoreAttribute{
+			schema.SimpleCoreAttribute(schema.SimpleNumberParams(schema.NumberParams{
+				Name: "int",
+				Type: schema.AttributeTypeInteger(),
+			})),
+			schema.SimpleCoreAttribute(schema.SimpleNumberParams(schema.NumberParams{
+				Name: "dec",
+				Type: schema.AttributeTypeDecimal(),
+			})),
+			schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+				Name:        "tags",
+				MultiValued: true,
+			})),
+			schema.ComplexCoreAttribute(schema.ComplexParams{
+				Name: "complex",
+				SubAttributes: []schema.SimpleParams{
+					schema.SimpleStringParams(schema.StringParams{Name: "value"}),
+				},
+			}),
+		},
+	}
+
+	for _, test := range []struct {
+		filter   string
+		resource map[string]interface{}
+		ok       bool
+		err      bool
+	}{
+		{filter: `int eq 1`, resource: map[string]interface{}{"int": int64(1)}, ok: true},
+		{filter: `int eq 1`, resource: map[string]interface{}{"int": 1.0}, ok: true},
+		{filter: `int gt 1`, resource: map[string]interface{}{"int": json.Number("2")}, ok: true},
+		{filter: `int eq 1`, resource: map[string]interface{}{"int": nil}},
+		{filter: `int eq 1`, resource: map[string]interface{}{"int": "1"}, err: true},
+		{filter: `not (int eq 1)`, resource: map[string]interface{}{"int": "1"}, err: true},
+		{filter: `int eq 1 or int pr`, resource: map[string]interface{}{"int": 1.5}, ok: true},
+		{filter: `int pr or int eq 1`, resource: map[string]interface{}{"int": 1.5}, ok: true},
+		{filter: `int eq 1 or int eq 2`, resource: map[string]interface{}{"int": 1.5}, err: true},
+		{filter: `int eq 1 or dec eq 1`, resource: map[string]interface{}{"int": 1.5, "dec": 2.0}, err: true},
+		{filter: `dec ge 1`, resource: map[string]interface{}{"dec": 1.5}, ok: true},
+		{filter: `dec lt 1.5`, resource: map[string]interface{}{"dec": json.Number("1.25")}, ok: true},
+		{filter: `dec sw 1.2`, resource: map[string]interface{}{"dec": 1.25}, ok: true},
+		{filter: `dec eq 1`, resource: map[string]interface{}{"dec": true}, err: true},
+		{filter: `tags eq "a"`, resource: map[string]interface{}{"tags": []interface{}{1, "a"}}, ok: true},
+		{filter: `tags eq "b"`, resource: map[string]interface{}{"tags": []interface{}{1, "a"}}, err: true},
+		{filter: `tags eq "a"`, resource: map[string]interface{}{"tags": "a"}, ok: true},
+		{filter: `tags eq "a\"b"`, resource: map[string]interface{}{"tags": `a"b`}, ok: true},
+		{filter: `tags eq "a\u00E9"`, resource: map[string]interface{}{"tags": "aé"}, ok: true},
+		{filter: `tags pr`, resource: map[string]interface{}{"tags": []interface{}{}}},
+		{filter: `tags pr`, resource: map[string]interface{}{"tags": []interface{}{""}}},
+		{filter: `complex pr`, resource: map[string]interface{}{"complex": map[string]interface{}{}}},
+		{filter: `complex pr`, resource: map[string]interface{}{"complex": map[string]interface{}{"value": "a"}}, ok: true},
+		{filter: `complex eq "a"`, resource: map[string]interface{}{"complex": map[string]interface{}{"value": "a"}}, ok: true},
+		{filter: `complex.value eq "a"`, resource: map[string]interface{}{"complex": "a"}, err: true},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			validator, err := filter.NewValidator(test.filter, ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			predicate, err := validator.Compile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			ok, err := predicate(test.resource)
+			if ok != test.ok {
+				t.Errorf("expected %t, got %t", test.ok, ok)
+			}
+			var typeErr *filter.TypeError
+			if errors.As(err, &typeErr) != test.err {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package filter
+
+import (
+	"github.com/elimity-com/scim/internal/translate"
+	"github.com/scim2/filter-parser/v2"
+)
+
+// NestingDepth returns the maximum nesting depth of the parentheses and brackets of the given filter text, e.g., 1 for
+// `emails[type eq "work"]` and 2 for `not (emails[type eq "work"])`, ignoring those within strings. It does not parse
+// the filter, so that the nesting of a filter can be limited before it is parsed.
+func NestingDepth(f string) int {
+	var depth, maxDepth int
+	var inString, escaped bool
+	for _, r := range f {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && r == '\\':
+			escaped = true
+		case r == '"':
+			inString = !inString
+		case inString:
+		case r == '(' || r == '[':
+			depth++
+			maxDepth = maxInt(maxDepth, depth)
+		case r == ')' || r == ']':
+			depth--
+		}
+	}
+	return maxDepth
+}
+
+// maxInt returns the largest of the given integers.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Complexity describes the complexity of a filter expression.
+type Complexity struct {
+	// Clauses is the number of attribute expressions, including those within value paths.
+	Clauses int
+	// Depth is the depth of the expression tree, e.g., 1 for `title pr` and 3 for `not (title pr and userType pr)`.
+	// Chains of logical expressions with the same operator are flattened, so `a pr or b pr or c pr` has depth 2.
+	Depth int
+	// ValuePathDepth is the maximum number of nested value paths, e.g., 1 for `emails[type eq "work"]`.
+	ValuePathDepth int
+}
+
+// MeasureComplexity returns the complexity of the given expression.
+func MeasureComplexity(e filter.Expression) Complexity {
+	switch e := e.(type) {
+	case *filter.AttributeExpression:
+		return Complexity{
+			Clauses: 1,
+			Depth:   1,
+		}
+	case *filter.LogicalExpression:
+		var c Complexity
+		for _, operand := range translate.LogicalOperands(e.Operator, e) {
+			o := MeasureComplexity(operand)
+			c.Clauses += o.Clauses
+			c.Depth = maxInt(c.Depth, o.Depth)
+			c.ValuePathDepth = maxInt(c.ValuePathDepth, o.ValuePathDepth)
+		}
+		c.Depth++
+		return c
+	case *filter.NotExpression:
+		c := MeasureComplexity(e.Expression)
+		c.Depth++
+		return c
+	case *filter.ValuePath:
+		c := MeasureComplexity(e.ValueFilter)
+		c.Depth++
+		c.ValuePathDepth++
+		return c
+	default:
+		return Complexity{}
+	}
+}
@@ -0,0 +1,54 @@
+package filter_test
+
+import (
+	"testing"
+
+	internal "github.com/elimity-com/scim/filter"
+	"github.com/scim2/filter-parser/v2"
+)
+
+func TestMeasureComplexity(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected internal.Complexity
+	}{
+		{filter: `title pr`, expected: internal.Complexity{Clauses: 1, Depth: 1}},
+		{filter: `not (title pr and userType pr)`, expected: internal.Complexity{Clauses: 2, Depth: 3}},
+		{filter: `a pr or b pr or c pr`, expected: internal.Complexity{Clauses: 3, Depth: 2}},
+		{filter: `a pr or b pr and (c pr or d pr)`, expected: internal.Complexity{Clauses: 4, Depth: 4}},
+		{
+			filter:   `userType eq "Employee" and emails[type eq "work" and value co "@example.com"]`,
+			expected: internal.Complexity{Clauses: 3, Depth: 4, ValuePathDepth: 1},
+		},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			e, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c := internal.MeasureComplexity(e); c != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, c)
+			}
+		})
+	}
+}
+
+func TestNestingDepth(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected int
+	}{
+		{filter: `title pr`, expected: 0},
+		{filter: `emails[type eq "work"]`, expected: 1},
+		{filter: `not (emails[type eq "work"]) or (title pr)`, expected: 2},
+		{filter: `title eq "((([[["`, expected: 0},
+		{filter: `title eq "\"((" or (title pr)`, expected: 1},
+		{filter: `((((title pr`, expected: 4},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			if depth := internal.NestingDepth(test.filter); depth != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, depth)
+			}
+		})
+	}
+}
@@ -6,6 +6,56 @@ import (
 	"github.com/scim2/filter-parser/v2"
 )
 
+// ParseFilter parses the given filter. Contrary to filter.ParseFilter, the string compare values of the returned
+// expression are unescaped, e.g., `userName eq "a\"b"` compares with `a"b`, just like the expressions built by a
+// Builder.
+func ParseFilter(exp string) (filter.Expression, error) {
+	e, err := filter.ParseFilter([]byte(exp))
+	if err != nil {
+		return nil, err
+	}
+	return unescape(e)
+}
+
+// ParsePath parses the given path. Contrary to filter.ParsePath, the string compare values of its value expression are
+// unescaped, see ParseFilter.
+func ParsePath(path string) (filter.Path, error) {
+	p, err := filter.ParsePath([]byte(path))
+	if err != nil {
+		return filter.Path{}, err
+	}
+	if p.ValueExpression != nil {
+		if p.ValueExpression, err = unescape(p.ValueExpression); err != nil {
+			return filter.Path{}, err
+		}
+	}
+	return p, nil
+}
+
+// ValidateAttributePath parses the given attribute path, e.g., "name.givenName", and checks whether it is a valid path
+// within the given reference schema or one of its extensions. The returned path is qualified with the id of the
+// extension schema if the attribute belongs to one of the extensions. The returned attribute is the top-level
+// attribute the path refers to.
+func ValidateAttributePath(path string, s schema.Schema, exts ...schema.Schema) (filter.AttributePath, schema.CoreAttribute, error) {
+	attrPath, err := filter.ParseAttrPath([]byte(path))
+	if err != nil {
+		return filter.AttributePath{}, schema.CoreAttribute{}, err
+	}
+
+	attr, err := validateAttributePath(s, attrPath)
+	if err == nil {
+		return attrPath, attr, nil
+	}
+	for _, e := range exts {
+		if attr, err := validateAttributePath(e, attrPath); err == nil {
+			id := e.ID
+			attrPath.URIPrefix = &id
+			return attrPath, attr, nil
+		}
+	}
+	return filter.AttributePath{}, schema.CoreAttribute{}, err
+}
+
 // validateAttributePath checks whether the given attribute path is a valid path within the given reference schema.
 func validateAttributePath(ref schema.Schema, attrPath filter.AttributePath) (schema.CoreAttribute, error) {
 	if uri := attrPath.URI(); uri != "" && uri != ref.ID {
@@ -123,7 +173,7 @@ func NewFilterValidator(exp filter.Expression, s schema.Schema, exts ...schema.S
 
 // NewValidator constructs a new filter validator.
 func NewValidator(exp string, s schema.Schema, exts ...schema.Schema) (Validator, error) {
-	e, err := filter.ParseFilter([]byte(exp))
+	e, err := ParseFilter(exp)
 	if err != nil {
 		return Validator{}, err
 	}
@@ -134,11 +184,62 @@ func NewValidator(exp string, s schema.Schema, exts ...schema.Schema) (Validator
 	}, nil
 }
 
+// Compile resolves the filter of the validator against its schemas and returns a predicate that can be reused to
+// evaluate the filter on many resources. Resources are expected in the same form as by PassesFilter.
+//
+// Contrary to PassesFilter, the predicate never panics: values that do not match the type of their attribute result in
+// a *TypeError, missing and null values do not match. Filters on a complex attribute without a sub-attribute, e.g.,
+// `emails eq "bjensen@example.com"`, apply to its "value" sub-attribute.
+func (v Validator) Compile() (Predicate, error) {
+	return v.compile(v.filter)
+}
+
 // GetFilter returns the filter contained within the validator.
 func (v Validator) GetFilter() filter.Expression {
 	return v.filter
 }
 
+// Normalize returns the normalized filter, so that equivalent filters result in the same (simpler) filter:
+//   - attribute names are spelled as defined by the schemas, only attributes of extensions keep the schema id,
+//   - negations are pushed inward by applying De Morgan's laws (see PushDownNot),
+//   - chains of "and" and "or" expressions are flattened, deduplicated and sorted,
+//   - subtrees that are always true or false are folded, e.g., `title pr and not (title pr)`.
+func (v Validator) Normalize() (Normalized, error) {
+	e, err := v.Rewrite(func(n Node) (filter.Expression, error) {
+		path := n.Path
+		switch {
+		case n.ValuePath != nil:
+			// The attribute paths within a value filter are relative to the value path.
+			path = filter.AttributePath{AttributeName: *path.SubAttribute}
+		case n.Schema.ID == v.schema.ID:
+			path.URIPrefix = nil
+		}
+		switch e := n.Expression.(type) {
+		case *filter.AttributeExpression:
+			return &filter.AttributeExpression{
+				AttributePath: path,
+				Operator:      e.Operator,
+				CompareValue:  e.CompareValue,
+			}, nil
+		case *filter.ValuePath:
+			return &filter.ValuePath{
+				AttributePath: path,
+				ValueFilter:   e.ValueFilter,
+			}, nil
+		default:
+			return e, nil
+		}
+	})
+	if err != nil {
+		return Normalized{}, err
+	}
+	t := normalize(PushDownNot(e))
+	return Normalized{
+		Expression: t.expression,
+		Value:      t.value,
+	}, nil
+}
+
 // PassesFilter checks whether given resources passes the filter.
 func (v Validator) PassesFilter(resource map[string]interface{}) error {
 	switch e := v.filter.(type) {
@@ -213,14 +314,29 @@ func (v Validator) PassesFilter(resource map[string]interface{}) error {
 				return fmt.Errorf("the resource has no sub-attribute named: %s", subAttrName)
 			}
 
-			attr, ok := value.(map[string]interface{})
-			if !ok {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				value, ok = v[subAttr.Name()]
+				if !ok {
+					return fmt.Errorf("the resource does contain the attribute specified in the filter")
+				}
+			case []interface{}:
+				// The sub-attribute of a multi-valued attribute matches if any of the values matches.
+				var values []interface{}
+				for _, a := range v {
+					if a, ok := a.(map[string]interface{}); ok {
+						if subValue, ok := a[subAttr.Name()]; ok {
+							values = append(values, subValue)
+						}
+					}
+				}
+				if len(values) == 0 {
+					return fmt.Errorf("the resource does contain the attribute specified in the filter")
+				}
+				value = values
+			default:
 				return fmt.Errorf("the target is not a complex attribute")
 			}
-			value, ok = attr[subAttr.Name()]
-			if !ok {
-				return fmt.Errorf("the resource does contain the attribute specified in the filter")
-			}
 
 			cmpAttr = subAttr
 		}
@@ -304,6 +420,41 @@ func (v Validator) PassesFilter(resource map[string]interface{}) error {
 	}
 }
 
+// Rewrite returns a copy of the filter in which each attribute expression and value path is replaced by the (non-nil)
+// expression returned by the given function, e.g., to rename attributes. The value filter of a value path is rewritten
+// before the value path itself, of which the node contains the rewritten value filter. The filter of the validator is
+// left unchanged.
+func (v Validator) Rewrite(rewrite func(n Node) (filter.Expression, error)) (filter.Expression, error) {
+	return v.rewrite(v.filter, nil, rewrite)
+}
+
+// Split splits the filter into two filters, of which the conjunction is equivalent to the filter: one that only
+// contains attribute expressions and value paths accepted by the given function (e.g., that can be pushed down to a
+// data store) and a residual filter with the remaining conditions. Either of the returned filters is nil if there are
+// no such conditions. Negations are pushed down first, so that negated disjunctions can be split as well.
+func (v Validator) Split(pushdown func(n Node) bool) (filter.Expression, filter.Expression, error) {
+	var supported, residual []filter.Expression
+	for _, e := range conjuncts(PushDownNot(v.filter)) {
+		accepted := true
+		if err := (Validator{
+			filter:     e,
+			schema:     v.schema,
+			extensions: v.extensions,
+		}).Walk(func(n Node) error {
+			accepted = accepted && pushdown(n)
+			return nil
+		}); err != nil {
+			return nil, nil, err
+		}
+		if accepted {
+			supported = append(supported, e)
+		} else {
+			residual = append(residual, e)
+		}
+	}
+	return conjunction(supported), conjunction(residual), nil
+}
+
 // Validate checks whether the expression is a valid path within the given reference schemas.
 func (v Validator) Validate() error {
 	err := validateExpression(v.schema, v.filter)
@@ -318,6 +469,226 @@ func (v Validator) Validate() error {
 	return err
 }
 
+// Walk calls the given function for each attribute expression and value path within the filter, in the order in which
+// they occur. A value path is visited before the expressions within its value filter. Walking stops at the first error
+// returned by the function, which is then returned.
+func (v Validator) Walk(visit func(n Node) error) error {
+	return v.walk(v.filter, nil, visit)
+}
+
+// compile returns the predicate of the given expression.
+func (v Validator) compile(e filter.Expression) (Predicate, error) {
+	switch e := e.(type) {
+	case *filter.ValuePath:
+		return v.compileValuePath(e)
+	case *filter.AttributeExpression:
+		return v.compileAttributeExpression(e)
+	case *filter.LogicalExpression:
+		left, err := v.compile(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := v.compile(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		if e.Operator == filter.OR {
+			// The right operand is evaluated before an error of the left operand is returned, since it can still match.
+			return func(resource map[string]interface{}) (bool, error) {
+				ok, err := left(resource)
+				if ok {
+					return true, nil
+				}
+				if ok, rightErr := right(resource); ok || err == nil {
+					return ok, rightErr
+				}
+				return false, err
+			}, nil
+		}
+		return func(resource map[string]interface{}) (bool, error) {
+			if ok, err := left(resource); !ok || err != nil {
+				return false, err
+			}
+			return right(resource)
+		}, nil
+	case *filter.NotExpression:
+		p, err := v.compile(e.Expression)
+		if err != nil {
+			return nil, err
+		}
+		return func(resource map[string]interface{}) (bool, error) {
+			ok, err := p(resource)
+			if err != nil {
+				return false, err
+			}
+			return !ok, nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown expression type: %s", e)
+	}
+}
+
+// compileAttributeExpression returns the predicate of the given attribute expression.
+func (v Validator) compileAttributeExpression(e *filter.AttributeExpression) (Predicate, error) {
+	ref, attr, ok := v.referenceContains(e.AttributePath)
+	if !ok {
+		return nil, fmt.Errorf("could not find an attribute that matches the attribute path: %s", e.AttributePath)
+	}
+
+	var (
+		// cmpAttr is the attribute to which the compare value applies.
+		cmpAttr     = attr
+		subAttrName = e.AttributePath.SubAttributeName()
+	)
+	if subAttrName != "" {
+		if cmpAttr, ok = attr.SubAttributes().ContainsAttribute(subAttrName); !ok {
+			return nil, fmt.Errorf("the attribute has no sub-attributes named: %s", subAttrName)
+		}
+		subAttrName = cmpAttr.Name()
+	} else if attr.HasSubAttributes() && e.Operator != filter.PR {
+		if cmpAttr, ok = attr.SubAttributes().ContainsAttribute("value"); !ok {
+			return nil, fmt.Errorf("the complex attribute %s can not be compared to a value", attr.Name())
+		}
+		subAttrName = cmpAttr.Name()
+	}
+
+	m := matcher(present)
+	if e.Operator != filter.PR {
+		var err error
+		if m, err = compileComparison(e.Operator, e.CompareValue, cmpAttr); err != nil {
+			return nil, err
+		}
+	}
+
+	name, qualified := attr.Name(), qualifiedName(ref.ID, attr.Name())
+	if subAttrName == "" {
+		return func(resource map[string]interface{}) (bool, error) {
+			value, ok := lookupValue(resource, name, qualified)
+			if !ok {
+				return false, nil
+			}
+			return matchAny(value, m)
+		}, nil
+	}
+	return func(resource map[string]interface{}) (bool, error) {
+		value, ok := lookupValue(resource, name, qualified)
+		if !ok {
+			return false, nil
+		}
+		return matchSubAttribute(name, subAttrName, value, m)
+	}, nil
+}
+
+// compileValuePath returns the predicate of the given value path.
+func (v Validator) compileValuePath(e *filter.ValuePath) (Predicate, error) {
+	ref, attr, ok := v.referenceContains(e.AttributePath)
+	if !ok {
+		return nil, fmt.Errorf("could not find an attribute that matches the attribute path: %s", e.AttributePath)
+	}
+	if !attr.MultiValued() || !attr.HasSubAttributes() {
+		return nil, fmt.Errorf("value path filters can only be applied to complex multi-valued attributes")
+	}
+
+	valueFilter, err := Validator{
+		schema: schema.Schema{
+			ID:         ref.ID,
+			Attributes: attr.SubAttributes(),
+		},
+	}.compile(e.ValueFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	name, qualified := attr.Name(), qualifiedName(ref.ID, attr.Name())
+	return func(resource map[string]interface{}) (bool, error) {
+		value, ok := lookupValue(resource, name, qualified)
+		if !ok {
+			return false, nil
+		}
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		var err error
+		for _, value := range values {
+			var (
+				ok bool
+				e  error
+			)
+			switch value := value.(type) {
+			case nil:
+				continue
+			case map[string]interface{}:
+				ok, e = valueFilter(value)
+			default:
+				e = &TypeError{Attribute: name, Type: "complex", Value: value}
+			}
+			if ok {
+				return true, nil
+			}
+			if err == nil {
+				err = e
+			}
+		}
+		return false, err
+	}, nil
+}
+
+// node resolves the given attribute expression or value path. If a value path is given, the expression is part of
+// its value filter and its attribute path refers to one of the sub-attributes of the value path.
+func (v Validator) node(e filter.Expression, attrPath filter.AttributePath, vp *Node) (Node, error) {
+	if vp != nil {
+		if _, ok := e.(*filter.ValuePath); ok {
+			return Node{}, fmt.Errorf("value paths can not be nested: %s", attrPath)
+		}
+		if attrPath.URI() != "" || attrPath.SubAttributeName() != "" {
+			return Node{}, fmt.Errorf("invalid attribute path within value filter: %s", attrPath)
+		}
+		subAttr, ok := vp.Attribute.SubAttributes().ContainsAttribute(attrPath.AttributeName)
+		if !ok {
+			return Node{}, fmt.Errorf("the attribute has no sub-attributes named: %s", attrPath.AttributeName)
+		}
+		path := vp.Path
+		name := subAttr.Name()
+		path.SubAttribute = &name
+		return Node{
+			Expression:   e,
+			Schema:       vp.Schema,
+			Attribute:    vp.Attribute,
+			SubAttribute: &subAttr,
+			Path:         path,
+			ValuePath:    vp.Expression.(*filter.ValuePath),
+		}, nil
+	}
+
+	ref, attr, ok := v.referenceContains(attrPath)
+	if !ok {
+		return Node{}, fmt.Errorf("could not find an attribute that matches the attribute path: %s", attrPath)
+	}
+	id := ref.ID
+	n := Node{
+		Expression: e,
+		Schema:     ref,
+		Attribute:  attr,
+		Path: filter.AttributePath{
+			URIPrefix:     &id,
+			AttributeName: attr.Name(),
+		},
+	}
+	if id == "" {
+		n.Path.URIPrefix = nil
+	}
+	if subAttrName := attrPath.SubAttributeName(); subAttrName != "" {
+		subAttr, ok := attr.SubAttributes().ContainsAttribute(subAttrName)
+		if !ok {
+			return Node{}, fmt.Errorf("the attribute has no sub-attributes named: %s", subAttrName)
+		}
+		name := subAttr.Name()
+		n.SubAttribute, n.Path.SubAttribute = &subAttr, &name
+	}
+	return n, nil
+}
+
 // referenceContains returns the schema and attribute to which the attribute path applies.
 func (v Validator) referenceContains(attrPath filter.AttributePath) (schema.Schema, schema.CoreAttribute, bool) {
 	for _, s := range append([]schema.Schema{v.schema}, v.extensions...) {
@@ -330,3 +701,90 @@ func (v Validator) referenceContains(attrPath filter.AttributePath) (schema.Sche
 	}
 	return schema.Schema{}, schema.CoreAttribute{}, false
 }
+
+// rewrite returns the given expression in which attribute expressions and value paths are rewritten.
+func (v Validator) rewrite(e filter.Expression, vp *Node, rewrite func(n Node) (filter.Expression, error)) (filter.Expression, error) {
+	var (
+		n   Node
+		err error
+	)
+	switch e := e.(type) {
+	case *filter.LogicalExpression:
+		left, err := v.rewrite(e.Left, vp, rewrite)
+		if err != nil {
+			return nil, err
+		}
+		right, err := v.rewrite(e.Right, vp, rewrite)
+		if err != nil {
+			return nil, err
+		}
+		return &filter.LogicalExpression{
+			Left:     left,
+			Operator: e.Operator,
+			Right:    right,
+		}, nil
+	case *filter.NotExpression:
+		expression, err := v.rewrite(e.Expression, vp, rewrite)
+		if err != nil {
+			return nil, err
+		}
+		return &filter.NotExpression{Expression: expression}, nil
+	case *filter.AttributeExpression:
+		if n, err = v.node(e, e.AttributePath, vp); err != nil {
+			return nil, err
+		}
+	case *filter.ValuePath:
+		if n, err = v.node(e, e.AttributePath, vp); err != nil {
+			return nil, err
+		}
+		valueFilter, err := v.rewrite(e.ValueFilter, &n, rewrite)
+		if err != nil {
+			return nil, err
+		}
+		n.Expression = &filter.ValuePath{
+			AttributePath: e.AttributePath,
+			ValueFilter:   valueFilter,
+		}
+	default:
+		return nil, fmt.Errorf("unknown expression type: %s", e)
+	}
+
+	rewritten, err := rewrite(n)
+	if err != nil {
+		return nil, err
+	}
+	if rewritten == nil {
+		return nil, fmt.Errorf("the rewrite of %s returned no expression", n.Path)
+	}
+	return rewritten, nil
+}
+
+// walk calls the given function for each attribute expression and value path within the given expression.
+func (v Validator) walk(e filter.Expression, vp *Node, visit func(n Node) error) error {
+	switch e := e.(type) {
+	case *filter.LogicalExpression:
+		if err := v.walk(e.Left, vp, visit); err != nil {
+			return err
+		}
+		return v.walk(e.Right, vp, visit)
+	case *filter.NotExpression:
+		return v.walk(e.Expression, vp, visit)
+	case *filter.AttributeExpression:
+		n, err := v.node(e, e.AttributePath, vp)
+		if err != nil {
+			return err
+		}
+		return visit(n)
+	case *filter.ValuePath:
+		n, err := v.node(e, e.AttributePath, vp)
+		if err != nil {
+			return err
+		}
+		if err := visit(n); err != nil {
+			return err
+		}
+		return v.walk(e.ValueFilter, &n, visit)
+	default:
+		return fmt.Errorf("unknown expression type: %s", e)
+	}
+}
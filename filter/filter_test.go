@@ -58,6 +58,48 @@ func TestPathValidator_Validate(t *testing.T) {
 	})
 }
 
+func TestValidateAttributePath(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		for _, test := range []struct {
+			path          string
+			attributeName string
+			uri           string
+		}{
+			{path: `userName`, attributeName: "userName"},
+			{path: `name.familyName`, attributeName: "name"},
+			{path: `urn:ietf:params:scim:schemas:core:2.0:User:emails.value`, attributeName: "emails", uri: "urn:ietf:params:scim:schemas:core:2.0:User"},
+			{path: `employeeNumber`, attributeName: "employeeNumber", uri: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"},
+			{path: `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.displayName`, attributeName: "manager", uri: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"},
+		} {
+			path, attr, err := filter.ValidateAttributePath(test.path, schema.CoreUserSchema(), schema.ExtensionEnterpriseUser())
+			if err != nil {
+				t.Errorf("(%s) %v", test.path, err)
+				continue
+			}
+			if attr.Name() != test.attributeName {
+				t.Errorf("(%s) expected attribute %s, got %s", test.path, test.attributeName, attr.Name())
+			}
+			if path.URI() != test.uri {
+				t.Errorf("(%s) expected uri %q, got %q", test.path, test.uri, path.URI())
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, path := range []string{
+			`invalid`,
+			`name.invalid`,
+			`userName.invalid`,
+			`emails[type eq "work"]`,
+			`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:userName`,
+		} {
+			if _, _, err := filter.ValidateAttributePath(path, schema.CoreUserSchema(), schema.ExtensionEnterpriseUser()); err == nil {
+				t.Errorf("(%s) should not be valid", path)
+			}
+		}
+	})
+}
+
 func TestValidator_PassesFilter(t *testing.T) {
 	t.Run("simple", func(t *testing.T) {
 		for _, test := range []struct {
@@ -91,6 +133,26 @@ func TestValidator_PassesFilter(t *testing.T) {
 					},
 				},
 			},
+			{
+				filter: `emails.value eq "john@example.com"`,
+				valid: map[string]interface{}{
+					"emails": []interface{}{
+						map[string]interface{}{
+							"value": "doe@example.com",
+						},
+						map[string]interface{}{
+							"value": "john@example.com",
+						},
+					},
+				},
+				invalid: map[string]interface{}{
+					"emails": []interface{}{
+						map[string]interface{}{
+							"value": "doe@example.com",
+						},
+					},
+				},
+			},
 		} {
 			validator, err := filter.NewValidator(test.filter, schema.CoreUserSchema())
 			if err != nil {
@@ -0,0 +1,110 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/scim2/filter-parser/v2"
+)
+
+// Format returns the given expression as canonical filter text: lower case operators, a space between "not" and its
+// parenthesised expression and only the parentheses that are needed, contrary to the String methods of the
+// expressions. String compare values are escaped, so that ParseFilter returns an equal expression.
+func Format(e filter.Expression) string {
+	s, _ := format(e)
+	return s
+}
+
+// format returns the given expression as filter text, together with the precedence of its outer operator.
+func format(e filter.Expression) (string, int) {
+	switch e := e.(type) {
+	case *filter.AttributeExpression:
+		s := e.AttributePath.String() + " " + string(e.Operator)
+		if e.Operator != filter.PR {
+			s += " " + formatCompareValue(e.CompareValue)
+		}
+		return s, precedencePrimary
+	case *filter.LogicalExpression:
+		precedence := precedenceAnd
+		if e.Operator == filter.OR {
+			precedence = precedenceOr
+		}
+		left, leftPrecedence := format(e.Left)
+		if leftPrecedence < precedence {
+			left = "(" + left + ")"
+		}
+		right, rightPrecedence := format(e.Right)
+		if rightPrecedence < precedence {
+			right = "(" + right + ")"
+		}
+		return left + " " + string(e.Operator) + " " + right, precedence
+	case *filter.NotExpression:
+		s, _ := format(e.Expression)
+		return "not (" + s + ")", precedencePrimary
+	case *filter.ValuePath:
+		s, _ := format(e.ValueFilter)
+		return e.AttributePath.String() + "[" + s + "]", precedencePrimary
+	default:
+		return fmt.Sprint(e), precedencePrimary
+	}
+}
+
+// formatCompareValue returns the given compare value as filter text.
+func formatCompareValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		if s, err := formatFloat(v, 64); err == nil {
+			return s
+		}
+	case json.Number:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
+// unescape returns a copy of the given parsed expression of which the string compare values are unescaped, e.g.,
+// `a\"b` becomes `a"b`. The given expression is left unchanged.
+func unescape(e filter.Expression) (filter.Expression, error) {
+	switch e := e.(type) {
+	case *filter.AttributeExpression:
+		c := *e
+		if s, ok := e.CompareValue.(string); ok {
+			if err := json.Unmarshal([]byte(`"`+s+`"`), &s); err != nil {
+				return nil, fmt.Errorf("invalid string %q: %v", s, err)
+			}
+			c.CompareValue = s
+		}
+		return &c, nil
+	case *filter.LogicalExpression:
+		left, err := unescape(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := unescape(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &filter.LogicalExpression{Left: left, Right: right, Operator: e.Operator}, nil
+	case *filter.NotExpression:
+		c, err := unescape(e.Expression)
+		if err != nil {
+			return nil, err
+		}
+		return &filter.NotExpression{Expression: c}, nil
+	case *filter.ValuePath:
+		c, err := unescape(e.ValueFilter)
+		if err != nil {
+			return nil, err
+		}
+		return &filter.ValuePath{AttributePath: e.AttributePath, ValueFilter: c}, nil
+	default:
+		return e, nil
+	}
+}
@@ -0,0 +1,314 @@
+package ldapfilter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	datetime "github.com/di-wu/xsd-datetime"
+	"github.com/elimity-com/scim/internal/translate"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+// ErrUnsupported is returned, wrapped in a more detailed error, for filters that can not be expressed in LDAP.
+var ErrUnsupported = errors.New("not expressible in LDAP")
+
+// assertionValue returns the escaped LDAP assertion value of the given compare value of an attribute of the given type.
+func assertionValue(typ string, value interface{}) (string, error) {
+	switch typ {
+	case "boolean":
+		if b, ok := value.(bool); ok {
+			return strings.ToUpper(strconv.FormatBool(b)), nil
+		}
+	case "integer":
+		switch v := value.(type) {
+		case int:
+			return strconv.Itoa(v), nil
+		case float64:
+			if v == math.Trunc(v) {
+				return strconv.FormatInt(int64(v), 10), nil
+			}
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				return strconv.FormatInt(i, 10), nil
+			}
+		}
+	case "decimal":
+		switch v := value.(type) {
+		case int:
+			return strconv.Itoa(v), nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case json.Number:
+			return escape(v.String()), nil
+		}
+	case "dateTime":
+		if s, ok := value.(string); ok {
+			date, err := datetime.Parse(s)
+			if err != nil {
+				return "", fmt.Errorf("invalid dateTime: %s", s)
+			}
+			// The Generalized Time syntax, see RFC 4517, Section 3.3.13.
+			return date.UTC().Format("20060102150405.999999999Z"), nil
+		}
+	default:
+		if s, ok := value.(string); ok {
+			return escape(s), nil
+		}
+	}
+	return "", fmt.Errorf("a %s attribute can not be compared to %v", typ, value)
+}
+
+// escape escapes the given assertion value as described in RFC 4515, Section 3.
+func escape(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// unsupported returns an error, wrapping ErrUnsupported, with the given message.
+func unsupported(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), ErrUnsupported)
+}
+
+// Mapping maps the attributes of a resource type to LDAP attribute descriptions.
+type Mapping struct {
+	// Attributes maps attribute paths, e.g., "userName", "name.familyName", "emails.value", "meta.created" or
+	// "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber", to LDAP attribute descriptions,
+	// e.g., "uid", "sn", "mail", "createTimestamp" and "employeeNumber". Attribute paths are case-insensitive and
+	// extension attributes may also be mapped without their URI prefix. The values of multi-valued attributes without
+	// sub-attributes are mapped by the name of the attribute, e.g., "x509Certificates".
+	Attributes map[string]string
+	// Ordered contains the LDAP attributes with an ORDERING matching rule, which are required for the "gt", "ge", "lt"
+	// and "le" operators on string, reference and decimal attributes. Integer and dateTime attributes are assumed to
+	// be mapped to LDAP attributes with an ordering rule, e.g., of the Integer or Generalized Time syntax.
+	Ordered []string
+}
+
+// attribute returns the LDAP attribute of the attribute with the given path.
+func (m Mapping) attribute(s schema.Schema, path string) (string, bool) {
+	if attr, ok := translate.Lookup(m.Attributes, s.ID+":"+path); ok {
+		return attr, true
+	}
+	return translate.Lookup(m.Attributes, path)
+}
+
+// ordered checks whether the given LDAP attribute has an ordering rule.
+func (m Mapping) ordered(attr string) bool {
+	for _, a := range m.Ordered {
+		if strings.EqualFold(a, attr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Translator translates filter expressions into LDAP search filters as described in RFC 4515.
+//
+// Since LDAP attributes are compared with the matching rules of the directory, the case sensitivity of comparisons
+// depends on the directory rather than the "caseExact" characteristic of the attributes. Values of multi-valued
+// attributes are not correlated in LDAP, so value paths can only refer to a single sub-attribute.
+type Translator struct {
+	mapping Mapping
+	schemas translate.Schemas
+}
+
+// NewTranslator returns a translator that translates filters of resources with the given schema, including its common
+// attributes, and extensions, using the given attribute mapping.
+func NewTranslator(mapping Mapping, s schema.Schema, exts ...schema.Schema) Translator {
+	return Translator{
+		mapping: mapping,
+		schemas: translate.NewSchemas(s, exts...),
+	}
+}
+
+// Translate translates the given filter expression into an LDAP search filter. The expression is expected to be
+// validated against the schema of the translator, e.g., the filter of a validator within the list request parameters.
+// Filters that can not be expressed in LDAP result in an error that wraps ErrUnsupported.
+func (t Translator) Translate(e filter.Expression) (string, error) {
+	return t.expression(e, nil)
+}
+
+// attributeExpression translates the given attribute expression. If a value path is given, the attribute path refers
+// to one of the sub-attributes of the multi-valued attribute of that value path.
+func (t Translator) attributeExpression(e *filter.AttributeExpression, vp *valuePath) (string, error) {
+	var (
+		s    schema.Schema
+		attr schema.CoreAttribute
+		path string
+	)
+	if vp != nil {
+		sub, ok := vp.attr.SubAttributes().ContainsAttribute(e.AttributePath.AttributeName)
+		if !ok {
+			return "", fmt.Errorf("unknown sub-attribute: %s.%s", vp.attr.Name(), e.AttributePath.AttributeName)
+		}
+		if vp.sub != "" && !strings.EqualFold(vp.sub, sub.Name()) {
+			return "", unsupported(
+				"value filters on %s can only refer to a single sub-attribute, since values are not correlated",
+				vp.attr.Name(),
+			)
+		}
+		vp.sub = sub.Name()
+		s, attr, path = vp.schema, sub, vp.attr.Name()+"."+sub.Name()
+	} else {
+		var err error
+		if s, _, attr, err = t.schemas.Resolve(e.AttributePath); err != nil {
+			return "", err
+		}
+		path = attr.Name()
+		if sub := e.AttributePath.SubAttributeName(); sub != "" {
+			subAttr, ok := attr.SubAttributes().ContainsAttribute(sub)
+			if !ok {
+				return "", fmt.Errorf("unknown sub-attribute: %s", e.AttributePath)
+			}
+			attr, path = subAttr, path+"."+subAttr.Name()
+		} else if attr.MultiValued() && attr.HasSubAttributes() {
+			// e.g. emails eq "bjensen@example.com" applies to the value sub-attribute.
+			if sub, ok := attr.SubAttributes().ContainsAttribute("value"); ok {
+				attr, path = sub, path+".value"
+			}
+		}
+	}
+
+	if attr.HasSubAttributes() {
+		if e.Operator != filter.PR {
+			return "", unsupported("complex attribute %s can not be compared", path)
+		}
+		// A complex attribute is present if one of its sub-attributes is present.
+		var presences []string
+		for _, sub := range attr.SubAttributes() {
+			if ldapAttr, ok := t.mapping.attribute(s, path+"."+sub.Name()); ok {
+				presences = append(presences, fmt.Sprintf("(%s=*)", ldapAttr))
+			}
+		}
+		if len(presences) == 0 {
+			return "", fmt.Errorf("no LDAP attributes mapped for complex attribute: %s", path)
+		}
+		if len(presences) == 1 {
+			return presences[0], nil
+		}
+		return fmt.Sprintf("(|%s)", strings.Join(presences, "")), nil
+	}
+	ldapAttr, ok := t.mapping.attribute(s, path)
+	if !ok {
+		return "", fmt.Errorf("no LDAP attribute mapped for attribute: %s", path)
+	}
+	return t.compare(ldapAttr, attr, e.Operator, e.CompareValue)
+}
+
+// compare returns an LDAP filter that compares the given LDAP attribute of the given attribute with the compare value.
+func (t Translator) compare(ldapAttr string, attr schema.CoreAttribute, op filter.CompareOperator, value interface{}) (string, error) {
+	if op == filter.PR {
+		return fmt.Sprintf("(%s=*)", ldapAttr), nil
+	}
+	if value == nil {
+		return "", unsupported("comparison of %s with null", attr.Name())
+	}
+
+	typ := attr.AttributeType()
+	if (op == filter.CO || op == filter.SW || op == filter.EW) && typ != "string" && typ != "reference" {
+		return "", unsupported("substring match on %s attribute %s", typ, attr.Name())
+	}
+	v, err := assertionValue(typ, value)
+	if err != nil {
+		return "", err
+	}
+	switch op {
+	case filter.EQ:
+		return fmt.Sprintf("(%s=%s)", ldapAttr, v), nil
+	case filter.NE:
+		return fmt.Sprintf("(!(%s=%s))", ldapAttr, v), nil
+	case filter.CO, filter.SW, filter.EW:
+		if op != filter.SW {
+			v = "*" + v
+		}
+		if op != filter.EW {
+			v += "*"
+		}
+		return fmt.Sprintf("(%s=%s)", ldapAttr, v), nil
+	case filter.GT, filter.GE, filter.LT, filter.LE:
+		switch typ {
+		case "integer", "dateTime":
+		case "boolean", "binary":
+			return "", unsupported("ordering of %s attribute %s", typ, attr.Name())
+		default:
+			if !t.mapping.ordered(ldapAttr) {
+				return "", unsupported("ordering of %s attribute %s, since %s has no ordering rule", typ, attr.Name(), ldapAttr)
+			}
+		}
+		// LDAP only supports "greater or equal" and "less or equal".
+		switch op {
+		case filter.GT:
+			return fmt.Sprintf("(&(%s>=%s)(!(%s=%s)))", ldapAttr, v, ldapAttr, v), nil
+		case filter.GE:
+			return fmt.Sprintf("(%s>=%s)", ldapAttr, v), nil
+		case filter.LT:
+			return fmt.Sprintf("(&(%s<=%s)(!(%s=%s)))", ldapAttr, v, ldapAttr, v), nil
+		default:
+			return fmt.Sprintf("(%s<=%s)", ldapAttr, v), nil
+		}
+	default:
+		return "", fmt.Errorf("unknown operator: %s", op)
+	}
+}
+
+// expression translates the given expression.
+func (t Translator) expression(e filter.Expression, vp *valuePath) (string, error) {
+	switch e := e.(type) {
+	case *filter.AttributeExpression:
+		return t.attributeExpression(e, vp)
+	case *filter.LogicalExpression:
+		var operands []string
+		for _, operand := range translate.LogicalOperands(e.Operator, e) {
+			f, err := t.expression(operand, vp)
+			if err != nil {
+				return "", err
+			}
+			operands = append(operands, f)
+		}
+		operator := "&"
+		if e.Operator == filter.OR {
+			operator = "|"
+		}
+		return fmt.Sprintf("(%s%s)", operator, strings.Join(operands, "")), nil
+	case *filter.NotExpression:
+		f, err := t.expression(e.Expression, vp)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(!%s)", f), nil
+	case *filter.ValuePath:
+		if vp != nil {
+			return "", fmt.Errorf("nested value paths are not supported")
+		}
+		s, _, attr, err := t.schemas.Resolve(e.AttributePath)
+		if err != nil {
+			return "", err
+		}
+		if !attr.MultiValued() || !attr.HasSubAttributes() {
+			return "", fmt.Errorf("value paths are only supported on complex multi-valued attributes: %s", attr.Name())
+		}
+		return t.expression(e.ValueFilter, &valuePath{schema: s, attr: attr})
+	default:
+		return "", fmt.Errorf("unknown expression type: %T", e)
+	}
+}
+
+// valuePath is the multi-valued attribute to which the attribute paths within a value path apply.
+type valuePath struct {
+	schema schema.Schema
+	attr   schema.CoreAttribute
+	// sub is the name of the sub-attribute that the value filter refers to, if known yet.
+	sub string
+}
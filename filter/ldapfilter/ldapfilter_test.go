@@ -0,0 +1,108 @@
+package ldapfilter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+func TestEscape(t *testing.T) {
+	if escaped := escape("*()\\\x00é"); escaped != `\2a\28\29\5c\00é` {
+		t.Error(escaped)
+	}
+}
+
+func TestTranslatorTranslate(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected string
+	}{
+		{filter: `userName eq "bjensen"`, expected: `(uid=bjensen)`},
+		{filter: `userName eq "a*b(c)"`, expected: `(uid=a\2ab\28c\29)`},
+		{filter: `userName ne "bjensen"`, expected: `(!(uid=bjensen))`},
+		{filter: `name.familyName co "en*"`, expected: `(sn=*en\2a*)`},
+		{filter: `name.familyName sw "J"`, expected: `(sn=J*)`},
+		{filter: `name.familyName ew "sen"`, expected: `(sn=*sen)`},
+		{filter: `title pr`, expected: `(title=*)`},
+		{filter: `name pr`, expected: `(|(sn=*)(givenName=*))`},
+		{filter: `active eq true`, expected: `(accountActive=TRUE)`},
+		{filter: `meta.created gt "2020-01-02T03:04:05Z"`, expected: `(&(createTimestamp>=20200102030405Z)(!(createTimestamp=20200102030405Z)))`},
+		{filter: `meta.lastModified le "2020-01-02T03:04:05.5+01:00"`, expected: `(modifyTimestamp<=20200102020405.5Z)`},
+		{filter: `displayName ge "M"`, expected: `(displayName>=M)`},
+		{filter: `emails eq "bjensen@example.com"`, expected: `(mail=bjensen@example.com)`},
+		{filter: `emails[value ew "@example.com"]`, expected: `(mail=*@example.com)`},
+		{filter: `emails[value sw "b" or value sw "j"]`, expected: `(|(mail=b*)(mail=j*))`},
+		{
+			filter:   `userName eq "a" and title pr and (displayName co "b" or not (userName eq "c"))`,
+			expected: `(&(uid=a)(title=*)(|(displayName=*b*)(!(uid=c))))`,
+		},
+		{
+			filter:   `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber eq "42"`,
+			expected: `(employeeNumber=42)`,
+		},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			e, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := testTranslator().Translate(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, f)
+			}
+		})
+	}
+}
+
+func TestTranslatorTranslateErrors(t *testing.T) {
+	for _, test := range []struct {
+		filter      string
+		unsupported bool
+	}{
+		{filter: `userName gt "a"`, unsupported: true},
+		{filter: `active lt true`, unsupported: true},
+		{filter: `meta.created co "2020"`, unsupported: true},
+		{filter: `emails[type eq "work" and value co "@example.com"]`, unsupported: true},
+		{filter: `name eq "a"`, unsupported: true},
+		{filter: `nickName eq "a"`},
+		{filter: `unknown eq "a"`},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			e, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = testTranslator().Translate(e)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Is(err, ErrUnsupported) != test.unsupported {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func testTranslator() Translator {
+	return NewTranslator(Mapping{
+		Attributes: map[string]string{
+			"userName":          "uid",
+			"name.familyName":   "sn",
+			"name.givenName":    "givenName",
+			"displayName":       "displayName",
+			"title":             "title",
+			"active":            "accountActive",
+			"emails.value":      "mail",
+			"emails.type":       "mailType",
+			"meta.created":      "createTimestamp",
+			"meta.lastModified": "modifyTimestamp",
+			"employeeNumber":    "employeeNumber",
+		},
+		Ordered: []string{"displayName"},
+	}, schema.CoreUserSchema(), schema.ExtensionEnterpriseUser())
+}
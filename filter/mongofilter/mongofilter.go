@@ -0,0 +1,290 @@
+package mongofilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+
+	datetime "github.com/di-wu/xsd-datetime"
+	"github.com/elimity-com/scim/internal/translate"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+var orderingOperators = map[filter.CompareOperator]string{
+	filter.GT: "$gt",
+	filter.GE: "$gte",
+	filter.LT: "$lt",
+	filter.LE: "$lte",
+}
+
+// compareValue converts the given compare value of an attribute of the given type to the value within a query
+// document. DateTime values are converted to time.Time, integers to int64 and decimals to float64.
+func compareValue(typ string, value interface{}) (interface{}, error) {
+	switch typ {
+	case "boolean":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case "integer":
+		switch v := value.(type) {
+		case int:
+			return int64(v), nil
+		case float64:
+			if v == math.Trunc(v) {
+				return int64(v), nil
+			}
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				return i, nil
+			}
+		}
+	case "decimal":
+		switch v := value.(type) {
+		case int:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case json.Number:
+			if f, err := v.Float64(); err == nil {
+				return f, nil
+			}
+		}
+	case "dateTime":
+		if s, ok := value.(string); ok {
+			date, err := datetime.Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid dateTime: %s", s)
+			}
+			return date, nil
+		}
+	default:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("a %s attribute can not be compared to %v", typ, value)
+}
+
+// regex returns a regular expression condition, that matches case-insensitively if caseExact is false.
+func regex(pattern string, caseExact bool) map[string]interface{} {
+	condition := map[string]interface{}{"$regex": pattern}
+	if !caseExact {
+		condition["$options"] = "i"
+	}
+	return condition
+}
+
+// Mapping maps the attributes of a resource type to the keys of the documents in which the resources are stored.
+type Mapping struct {
+	// Extensions maps the identifiers of schema extensions, e.g.,
+	// "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User", to the keys of the nested documents that contain
+	// their attributes, e.g., "enterprise". Since the identifiers contain dots, they can not be used as keys in queries.
+	Extensions map[string]string
+	// Keys maps attribute paths, e.g., "id" or "meta.created", to the (dot notation) keys of their values, e.g., "_id"
+	// or "meta.created". Attribute paths are case-insensitive and extension attributes must be prefixed with the
+	// identifier of their schema. Attributes that are not mapped use their path, with the names of the attributes as
+	// defined by the schema, and extension attributes are nested in the document of their extension.
+	Keys map[string]string
+}
+
+// key returns the key of the attribute with the given path within the given schema, e.g., "name.givenName".
+func (m Mapping) key(s schema.Schema, ext bool, path string) (string, error) {
+	if !ext {
+		if key, ok := translate.Lookup(m.Keys, path); ok {
+			return key, nil
+		}
+		return path, nil
+	}
+	if key, ok := translate.Lookup(m.Keys, s.ID+":"+path); ok {
+		return key, nil
+	}
+	prefix, ok := translate.Lookup(m.Extensions, s.ID)
+	if !ok {
+		return "", fmt.Errorf("no document key mapped for schema extension: %s", s.ID)
+	}
+	return prefix + "." + path, nil
+}
+
+// Translator translates filter expressions into MongoDB query documents, represented by plain maps that can be
+// marshalled by any BSON encoder.
+//
+// Strings that are not case-exact are matched by case-insensitive regular expressions. Ordering comparisons, i.e.,
+// "gt", "ge", "lt" and "le", always compare strings as stored, since case-insensitive ordering requires a collation.
+// DateTime values are compared as time.Time, so they must be stored as dates.
+type Translator struct {
+	mapping Mapping
+	schemas translate.Schemas
+}
+
+// NewTranslator returns a translator that translates filters of resources with the given schema, including its common
+// attributes, and extensions, using the given document key mapping.
+func NewTranslator(mapping Mapping, s schema.Schema, exts ...schema.Schema) Translator {
+	return Translator{
+		mapping: mapping,
+		schemas: translate.NewSchemas(s, exts...),
+	}
+}
+
+// Translate translates the given filter expression into a query document. The expression is expected to be validated
+// against the schema of the translator, e.g., the filter of a validator within the list request parameters.
+func (t Translator) Translate(e filter.Expression) (map[string]interface{}, error) {
+	return t.expression(e, nil)
+}
+
+// attributeExpression translates the given attribute expression. If a value path attribute is given, the attribute
+// path refers to one of its sub-attributes and the key is relative to the elements of the attribute.
+func (t Translator) attributeExpression(e *filter.AttributeExpression, vp *schema.CoreAttribute) (map[string]interface{}, error) {
+	if vp != nil {
+		sub, ok := vp.SubAttributes().ContainsAttribute(e.AttributePath.AttributeName)
+		if !ok {
+			return nil, fmt.Errorf("unknown sub-attribute: %s.%s", vp.Name(), e.AttributePath.AttributeName)
+		}
+		return t.compare(sub.Name(), sub, e.Operator, e.CompareValue)
+	}
+
+	s, ext, attr, err := t.schemas.Resolve(e.AttributePath)
+	if err != nil {
+		return nil, err
+	}
+	path := attr.Name()
+	if sub := e.AttributePath.SubAttributeName(); sub != "" {
+		subAttr, ok := attr.SubAttributes().ContainsAttribute(sub)
+		if !ok {
+			return nil, fmt.Errorf("unknown sub-attribute: %s", e.AttributePath)
+		}
+		attr, path = subAttr, path+"."+subAttr.Name()
+	} else if attr.MultiValued() && attr.HasSubAttributes() && e.Operator != filter.PR {
+		// e.g. emails eq "bjensen@example.com" applies to the value sub-attribute.
+		if sub, ok := attr.SubAttributes().ContainsAttribute("value"); ok {
+			attr, path = sub, path+".value"
+		}
+	}
+	key, err := t.mapping.key(s, ext, path)
+	if err != nil {
+		return nil, err
+	}
+	return t.compare(key, attr, e.Operator, e.CompareValue)
+}
+
+// compare returns a query document that compares the value with the given key of the given attribute with the compare
+// value.
+func (t Translator) compare(key string, attr schema.CoreAttribute, op filter.CompareOperator, value interface{}) (map[string]interface{}, error) {
+	typ := attr.AttributeType()
+	if op == filter.PR {
+		condition := map[string]interface{}{"$exists": true, "$ne": nil}
+		if typ == "string" || typ == "reference" || typ == "binary" {
+			condition = map[string]interface{}{"$exists": true, "$nin": []interface{}{nil, ""}}
+		}
+		return map[string]interface{}{key: condition}, nil
+	}
+	if attr.HasSubAttributes() {
+		return nil, fmt.Errorf("complex attribute %s can not be compared", attr.Name())
+	}
+
+	if value == nil {
+		switch op {
+		case filter.EQ:
+			return map[string]interface{}{key: map[string]interface{}{"$eq": nil}}, nil
+		case filter.NE:
+			return map[string]interface{}{key: map[string]interface{}{"$ne": nil}}, nil
+		default:
+			return nil, fmt.Errorf("invalid operator for null value: %s", op)
+		}
+	}
+	v, err := compareValue(typ, value)
+	if err != nil {
+		return nil, err
+	}
+
+	// References and binary values are always case-exact.
+	caseExact := typ != "string" || attr.CaseExact()
+	var condition map[string]interface{}
+	switch op {
+	case filter.EQ:
+		condition = map[string]interface{}{"$eq": v}
+		if !caseExact {
+			condition = regex("^"+regexp.QuoteMeta(v.(string))+"$", false)
+		}
+	case filter.NE:
+		condition = map[string]interface{}{"$exists": true, "$ne": v}
+		if !caseExact {
+			condition = map[string]interface{}{
+				"$exists": true,
+				"$not":    regex("^"+regexp.QuoteMeta(v.(string))+"$", false),
+			}
+		}
+	case filter.CO, filter.SW, filter.EW:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid operator for %s attribute: %s", typ, op)
+		}
+		pattern := regexp.QuoteMeta(s)
+		if op == filter.SW {
+			pattern = "^" + pattern
+		}
+		if op == filter.EW {
+			pattern += "$"
+		}
+		condition = regex(pattern, caseExact)
+	case filter.GT, filter.GE, filter.LT, filter.LE:
+		if typ == "boolean" || typ == "binary" {
+			return nil, fmt.Errorf("invalid operator for %s attribute: %s", typ, op)
+		}
+		condition = map[string]interface{}{orderingOperators[op]: v}
+	default:
+		return nil, fmt.Errorf("unknown operator: %s", op)
+	}
+	return map[string]interface{}{key: condition}, nil
+}
+
+// expression translates the given expression.
+func (t Translator) expression(e filter.Expression, vp *schema.CoreAttribute) (map[string]interface{}, error) {
+	switch e := e.(type) {
+	case *filter.AttributeExpression:
+		return t.attributeExpression(e, vp)
+	case *filter.LogicalExpression:
+		var operands []interface{}
+		for _, operand := range translate.LogicalOperands(e.Operator, e) {
+			query, err := t.expression(operand, vp)
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, query)
+		}
+		if e.Operator == filter.OR {
+			return map[string]interface{}{"$or": operands}, nil
+		}
+		return map[string]interface{}{"$and": operands}, nil
+	case *filter.NotExpression:
+		query, err := t.expression(e.Expression, vp)
+		if err != nil {
+			return nil, err
+		}
+		// MongoDB has no top-level $not operator.
+		return map[string]interface{}{"$nor": []interface{}{query}}, nil
+	case *filter.ValuePath:
+		if vp != nil {
+			return nil, fmt.Errorf("nested value paths are not supported")
+		}
+		s, ext, attr, err := t.schemas.Resolve(e.AttributePath)
+		if err != nil {
+			return nil, err
+		}
+		if !attr.MultiValued() || !attr.HasSubAttributes() {
+			return nil, fmt.Errorf("value paths are only supported on complex multi-valued attributes: %s", attr.Name())
+		}
+		key, err := t.mapping.key(s, ext, attr.Name())
+		if err != nil {
+			return nil, err
+		}
+		query, err := t.expression(e.ValueFilter, &attr)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{key: map[string]interface{}{"$elemMatch": query}}, nil
+	default:
+		return nil, fmt.Errorf("unknown expression type: %T", e)
+	}
+}
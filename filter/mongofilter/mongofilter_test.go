@@ -0,0 +1,145 @@
+package mongofilter
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+type m = map[string]interface{}
+
+func TestTranslatorTranslate(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected m
+	}{
+		{
+			filter:   `userName eq "B.Jensen"`,
+			expected: m{"userName": m{"$regex": `^B\.Jensen$`, "$options": "i"}},
+		},
+		{
+			filter:   `id eq "2819c223"`,
+			expected: m{"_id": m{"$eq": "2819c223"}},
+		},
+		{
+			filter:   `externalId ne "a"`,
+			expected: m{"externalId": m{"$exists": true, "$ne": "a"}},
+		},
+		{
+			filter:   `displayName ne "Babs"`,
+			expected: m{"displayName": m{"$exists": true, "$not": m{"$regex": "^Babs$", "$options": "i"}}},
+		},
+		{
+			filter:   `name.familyName co "J*"`,
+			expected: m{"name.familyName": m{"$regex": `J\*`, "$options": "i"}},
+		},
+		{
+			filter:   `externalId sw "a"`,
+			expected: m{"externalId": m{"$regex": "^a"}},
+		},
+		{
+			filter:   `profileUrl ew ".com"`,
+			expected: m{"profileUrl": m{"$regex": `\.com$`}},
+		},
+		{
+			filter:   `title pr`,
+			expected: m{"title": m{"$exists": true, "$nin": []interface{}{nil, ""}}},
+		},
+		{
+			filter:   `active eq true`,
+			expected: m{"active": m{"$eq": true}},
+		},
+		{
+			filter:   `meta.lastModified ge "2011-05-13T04:42:34Z"`,
+			expected: m{"meta.lastModified": m{"$gte": time.Date(2011, 5, 13, 4, 42, 34, 0, time.UTC)}},
+		},
+		{
+			filter:   `emails eq "bjensen@example.com"`,
+			expected: m{"emails.value": m{"$regex": `^bjensen@example\.com$`, "$options": "i"}},
+		},
+		{
+			filter: `emails[type eq "work" and primary eq true]`,
+			expected: m{"emails": m{"$elemMatch": m{"$and": []interface{}{
+				m{"type": m{"$regex": "^work$", "$options": "i"}},
+				m{"primary": m{"$eq": true}},
+			}}}},
+		},
+		{
+			filter: `userName sw "a" or userName sw "b" or not (active eq false)`,
+			expected: m{"$or": []interface{}{
+				m{"userName": m{"$regex": "^a", "$options": "i"}},
+				m{"userName": m{"$regex": "^b", "$options": "i"}},
+				m{"$nor": []interface{}{m{"active": m{"$eq": false}}}},
+			}},
+		},
+		{
+			filter:   `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber eq "42"`,
+			expected: m{"enterprise.employeeNumber": m{"$regex": "^42$", "$options": "i"}},
+		},
+		{
+			filter:   `manager.value eq "26118915"`,
+			expected: m{"enterprise.manager.value": m{"$regex": "^26118915$", "$options": "i"}},
+		},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			e, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := testTranslator().Translate(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(test.expected, query) {
+				expected, _ := json.Marshal(test.expected)
+				actual, _ := json.Marshal(query)
+				t.Errorf("expected %s, got %s", expected, actual)
+			}
+		})
+	}
+}
+
+func TestTranslatorTranslateErrors(t *testing.T) {
+	for _, f := range []string{
+		`active gt true`,
+		`name eq "a"`,
+		`unknown eq "a"`,
+		`meta.created gt "yesterday"`,
+	} {
+		t.Run(f, func(t *testing.T) {
+			e, err := filter.ParseFilter([]byte(f))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := testTranslator().Translate(e); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	t.Run("unmapped extension", func(t *testing.T) {
+		e, err := filter.ParseFilter([]byte(`employeeNumber eq "42"`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		translator := NewTranslator(Mapping{}, schema.CoreUserSchema(), schema.ExtensionEnterpriseUser())
+		if _, err := translator.Translate(e); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func testTranslator() Translator {
+	return NewTranslator(Mapping{
+		Extensions: map[string]string{
+			"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": "enterprise",
+		},
+		Keys: map[string]string{
+			"id": "_id",
+		},
+	}, schema.CoreUserSchema(), schema.ExtensionEnterpriseUser())
+}
@@ -0,0 +1,133 @@
+package filter
+
+import (
+	"sort"
+
+	"github.com/scim2/filter-parser/v2"
+)
+
+// normalize returns the normalized term of the given expression, in which negations are expected to be pushed down.
+func normalize(e filter.Expression) term {
+	switch e := e.(type) {
+	case *filter.LogicalExpression:
+		return normalizeLogical(e.Operator, e)
+	case *filter.NotExpression:
+		t := normalize(e.Expression)
+		if t.expression == nil {
+			return term{value: !t.value}
+		}
+		return newTerm(&filter.NotExpression{Expression: t.expression})
+	case *filter.ValuePath:
+		t := normalize(e.ValueFilter)
+		if t.expression == nil {
+			if !t.value {
+				return term{value: false}
+			}
+			// A value filter that passes for every value only requires a value.
+			return newTerm(&filter.AttributeExpression{
+				AttributePath: e.AttributePath,
+				Operator:      filter.PR,
+			})
+		}
+		return newTerm(&filter.ValuePath{
+			AttributePath: e.AttributePath,
+			ValueFilter:   t.expression,
+		})
+	default:
+		return newTerm(e)
+	}
+}
+
+// normalizeLogical returns the normalized term of the given chain of logical expressions with the given operator. The
+// chain is flattened, constant operands are folded and operands are deduplicated and sorted.
+func normalizeLogical(op filter.LogicalOperator, e filter.Expression) term {
+	// absorbing is the value of the chain if one of its operands has that value, e.g. false for "and".
+	absorbing := op == filter.OR
+
+	var (
+		terms = make(map[string]term)
+		queue = []filter.Expression{e}
+	)
+	for len(queue) != 0 {
+		e := queue[0]
+		queue = queue[1:]
+		if l, ok := e.(*filter.LogicalExpression); ok && l.Operator == op {
+			queue = append(queue, l.Left, l.Right)
+			continue
+		}
+
+		t := normalize(e)
+		if t.expression == nil {
+			if t.value == absorbing {
+				return t
+			}
+			continue
+		}
+		if l, ok := t.expression.(*filter.LogicalExpression); ok && l.Operator == op {
+			// Folding can result in a chain with the same operator, e.g. "a or (b and c)" with c being true.
+			queue = append(queue, l.Left, l.Right)
+			continue
+		}
+		terms[t.text] = t
+	}
+
+	texts := make([]string, 0, len(terms))
+	for text := range terms {
+		// e.g. "a and not (a)" is always false, "a or not (a)" always true.
+		if _, ok := terms["not ("+text+")"]; ok {
+			return term{value: absorbing}
+		}
+		texts = append(texts, text)
+	}
+	if len(texts) == 0 {
+		return term{value: !absorbing}
+	}
+	sort.Strings(texts)
+
+	e = terms[texts[0]].expression
+	for _, text := range texts[1:] {
+		e = &filter.LogicalExpression{
+			Left:     e,
+			Operator: op,
+			Right:    terms[text].expression,
+		}
+	}
+	return newTerm(e)
+}
+
+// Normalized is a normalized filter, see Validator.Normalize.
+type Normalized struct {
+	// Expression is the normalized filter, or nil if the filter is constant.
+	Expression filter.Expression
+	// Value is the result of the filter for every resource if the filter is constant, i.e., if Expression is nil.
+	Value bool
+}
+
+// String returns the canonical filter text of the normalized filter, see Format. Equivalent filters that normalize to
+// the same filter have the same text, so it can be used as cache key. Since constant filters have no filter text, they
+// are represented by "true" and "false".
+func (n Normalized) String() string {
+	if n.Expression == nil {
+		if n.Value {
+			return "true"
+		}
+		return "false"
+	}
+	return Format(n.Expression)
+}
+
+// term is a normalized (sub-)filter, which is either an expression or a constant value.
+type term struct {
+	expression filter.Expression
+	// text is the canonical filter text of the expression, which is used to compare terms.
+	text  string
+	value bool
+}
+
+// newTerm returns the term of the given normalized expression.
+func newTerm(e filter.Expression) term {
+	return term{
+		expression: e,
+		text:       Format(e),
+	}
+}
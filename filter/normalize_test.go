@@ -0,0 +1,175 @@
+package filter_test
+
+import (
+	"reflect"
+	"testing"
+
+	internal "github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+func TestFormat(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected string
+	}{
+		{filter: `userName Eq "a\"b"`, expected: `userName eq "a\"b"`},
+		{filter: `(a eq 1 or b eq 1.5) and not(c pr)`, expected: `(a eq 1 or b eq 1.5) and not (c pr)`},
+		{filter: `a eq null or b eq true and c eq false`, expected: `a eq null or b eq true and c eq false`},
+		{filter: `emails[not (type eq "work")]`, expected: `emails[not (type eq "work")]`},
+		{filter: `a pr and (b pr and c pr)`, expected: `a pr and b pr and c pr`},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			e, err := internal.ParseFilter(test.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := internal.Format(e); s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+		})
+	}
+}
+
+func TestFormat_builder(t *testing.T) {
+	for _, b := range []internal.Builder{
+		internal.Attr("userName").Eq("a\"b\\c\n"),
+		internal.Attr("emails").Where(internal.Attr("value").Eq(`x"y@example.com`).Or(internal.Attr("primary").Eq(true))),
+		internal.Not(internal.Attr("title").Pr()).And(internal.Attr("age").Gt(18)),
+	} {
+		e, err := b.Expression()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := internal.Format(e); s != b.String() {
+			t.Errorf("expected %s, got %s", b, s)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	e, err := internal.ParseFilter(`userName eq "a\"b\\c\n" or emails[not (value ew "\u00E9")]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := internal.Attr("userName").Eq("a\"b\\c\n").
+		Or(internal.Attr("emails").Where(internal.Not(internal.Attr("value").Ew("é")))).
+		Expression()
+	if !reflect.DeepEqual(e, expected) {
+		t.Errorf("expected %v, got %v", expected, e)
+	}
+	if _, err := internal.ParseFilter(`userName eq "a\qb"`); err == nil {
+		t.Error("expected an error for an invalid escape sequence")
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	p, err := internal.ParsePath(`emails[value eq "a\"b"].display`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := internal.Attr("value").Eq(`a"b`).Expression()
+	if !reflect.DeepEqual(p.ValueExpression, expected) {
+		t.Errorf("expected %v, got %v", expected, p.ValueExpression)
+	}
+	if p.SubAttribute == nil || *p.SubAttribute != "display" {
+		t.Errorf("unexpected sub-attribute: %v", p.SubAttribute)
+	}
+}
+
+func TestValidator_Normalize(t *testing.T) {
+	userSchema := schema.CoreUserSchema()
+	userSchema.Attributes = append(userSchema.Attributes, schema.CommonAttributes()...)
+
+	for _, test := range []struct {
+		filter   string
+		expected string
+	}{
+		{filter: `(userName eq "a") or (userName eq "a")`, expected: `userName eq "a"`},
+		{filter: `USERNAME eq "a" or urn:ietf:params:scim:schemas:core:2.0:User:username eq "a"`, expected: `userName eq "a"`},
+		{filter: `Name.GivenName sw "b"`, expected: `name.givenName sw "b"`},
+		{filter: `EMPLOYEENUMBER pr`, expected: `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber pr`},
+		{filter: `emails[TYPE eq "work"]`, expected: `emails[type eq "work"]`},
+		{filter: `title pr and userName eq "a"`, expected: `title pr and userName eq "a"`},
+		{filter: `userName eq "a" and title pr`, expected: `title pr and userName eq "a"`},
+		{
+			filter:   `not (not (not (title pr or userType eq "a")))`,
+			expected: `not (title pr) and not (userType eq "a")`,
+		},
+		{
+			filter:   `(userName eq "a" and (title pr and userName eq "a")) or (nickName pr or (nickName pr))`,
+			expected: `nickName pr or title pr and userName eq "a"`,
+		},
+		{filter: `title pr or not (title pr)`, expected: `true`},
+		{filter: `title pr and not (title pr)`, expected: `false`},
+		{filter: `userName eq "a" or (title pr and not (title pr))`, expected: `userName eq "a"`},
+		{filter: `userName eq "a" and (title pr or not (title pr))`, expected: `userName eq "a"`},
+		{filter: `not (userName eq "a" and (title pr or not (title pr)))`, expected: `not (userName eq "a")`},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			validator, err := internal.NewValidator(test.filter, userSchema, schema.ExtensionEnterpriseUser())
+			if err != nil {
+				t.Fatal(err)
+			}
+			normalized, err := validator.Normalize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := normalized.String(); s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+			if normalized.Expression == nil {
+				return
+			}
+			// The normalized filter must be a valid filter.
+			if _, err := internal.NewValidator(normalized.String(), userSchema, schema.ExtensionEnterpriseUser()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	t.Run("value path", func(t *testing.T) {
+		// The parser does not support negations within logical expressions of value filters.
+		typePr := func() filter.Expression {
+			return &filter.AttributeExpression{
+				AttributePath: filter.AttributePath{AttributeName: "type"},
+				Operator:      filter.PR,
+			}
+		}
+		valuePath := func(op filter.LogicalOperator) filter.Expression {
+			return &filter.ValuePath{
+				AttributePath: filter.AttributePath{AttributeName: "emails"},
+				ValueFilter: &filter.LogicalExpression{
+					Left:     typePr(),
+					Operator: op,
+					Right:    &filter.NotExpression{Expression: typePr()},
+				},
+			}
+		}
+		for _, test := range []struct {
+			expression filter.Expression
+			expected   string
+		}{
+			{expression: valuePath(filter.AND), expected: `false`},
+			{expression: valuePath(filter.OR), expected: `emails pr`},
+			{expression: &filter.NotExpression{Expression: valuePath(filter.OR)}, expected: `not (emails pr)`},
+		} {
+			normalized, err := internal.NewFilterValidator(test.expression, userSchema).Normalize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := normalized.String(); s != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, s)
+			}
+		}
+	})
+
+	validator, err := internal.NewValidator(`unknown pr`, userSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := validator.Normalize(); err == nil {
+		t.Error("expected an error")
+	}
+}
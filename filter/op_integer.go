@@ -8,7 +8,7 @@ import (
 
 func cmpInt(ref int, cmp func(v, ref int) error) func(interface{}) error {
 	return func(i interface{}) error {
-		v, ok := i.(int)
+		v, ok := toInt(i)
 		if !ok {
 			panic(fmt.Sprintf("given value is not an integer: %v", i))
 		}
@@ -18,10 +18,11 @@ func cmpInt(ref int, cmp func(v, ref int) error) func(interface{}) error {
 
 func cmpIntStr(ref int, cmp func(v, ref string) error) (func(interface{}) error, error) {
 	return func(i interface{}) error {
-		if _, ok := i.(int); !ok {
+		v, ok := toInt(i)
+		if !ok {
 			panic(fmt.Sprintf("given value is not an integer: %v", i))
 		}
-		return cmp(fmt.Sprintf("%d", i), fmt.Sprintf("%d", ref))
+		return cmp(fmt.Sprintf("%d", v), fmt.Sprintf("%d", ref))
 	}, nil
 }
 
@@ -99,3 +100,21 @@ func cmpInteger(e *filter.AttributeExpression, ref int) (func(interface{}) error
 		panic(fmt.Sprintf("unknown operator in expression: %s", e))
 	}
 }
+
+// toInt converts the given integer value to an int. Validated integer attributes are of type int64.
+func toInt(i interface{}) (int, bool) {
+	switch v := i.(type) {
+	case int:
+		return v, true
+	case int8:
+		return int(v), true
+	case int16:
+		return int(v), true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
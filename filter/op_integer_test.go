@@ -56,3 +56,33 @@ func TestValidatorInteger(t *testing.T) {
 		})
 	}
 }
+
+func TestValidatorIntegerTypes(t *testing.T) {
+	ref := schema.Schema{
+		Attributes: []schema.CoreAttribute{
+			schema.SimpleCoreAttribute(schema.SimpleNumberParams(schema.NumberParams{
+				Name: "int",
+				Type: schema.AttributeTypeInteger(),
+			})),
+		},
+	}
+	validator, err := internal.NewValidator("int ge 10", ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		attr  map[string]interface{}
+		valid bool
+	}{
+		{map[string]interface{}{"int": int8(10)}, true},
+		{map[string]interface{}{"int": int16(9)}, false},
+		{map[string]interface{}{"int": int32(10)}, true},
+		{map[string]interface{}{"int": int64(11)}, true},
+		{map[string]interface{}{"int": int64(-1)}, false},
+	} {
+		if err := validator.PassesFilter(test.attr); (err == nil) != test.valid {
+			t.Errorf("%v | actual %v, expected %v", test.attr, err, test.valid)
+		}
+	}
+}
@@ -53,7 +53,7 @@ type PathValidator struct {
 
 // NewPathValidator constructs a new path validator.
 func NewPathValidator(pathFilter string, s schema.Schema, exts ...schema.Schema) (PathValidator, error) {
-	f, err := filter.ParsePath([]byte(pathFilter))
+	f, err := ParsePath(pathFilter)
 	if err != nil {
 		return PathValidator{}, err
 	}
@@ -0,0 +1,149 @@
+package sqlfilter
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	// MySQL is the dialect of MySQL and MariaDB. Case-exact strings are compared as binary strings.
+	MySQL = Dialect{
+		Placeholder: func(int) string {
+			return "?"
+		},
+		Compare: func(expr, operator, placeholder string, caseInsensitive bool) string {
+			if caseInsensitive {
+				return fmt.Sprintf("LOWER(%s) %s LOWER(%s)", expr, operator, placeholder)
+			}
+			return fmt.Sprintf("%s %s %s", expr, operator, placeholder)
+		},
+		Match: func(expr, placeholder string, caseInsensitive bool) string {
+			if caseInsensitive {
+				return fmt.Sprintf("LOWER(%s) LIKE LOWER(%s) ESCAPE '!'", expr, placeholder)
+			}
+			return fmt.Sprintf("CAST(%s AS BINARY) LIKE CAST(%s AS BINARY) ESCAPE '!'", expr, placeholder)
+		},
+		CompareExact: func(expr, operator, placeholder string) string {
+			return fmt.Sprintf("CAST(%s AS BINARY) %s CAST(%s AS BINARY)", expr, operator, placeholder)
+		},
+		Not:     notCoalesce,
+		Pattern: likePattern,
+	}
+	// PostgreSQL is the dialect of PostgreSQL, which uses numbered placeholders.
+	PostgreSQL = Dialect{
+		Placeholder: func(position int) string {
+			return fmt.Sprintf("$%d", position)
+		},
+		Compare: func(expr, operator, placeholder string, caseInsensitive bool) string {
+			if caseInsensitive {
+				return fmt.Sprintf("LOWER(%s) %s LOWER(%s)", expr, operator, placeholder)
+			}
+			return fmt.Sprintf("%s %s %s", expr, operator, placeholder)
+		},
+		Match: func(expr, placeholder string, caseInsensitive bool) string {
+			if caseInsensitive {
+				return fmt.Sprintf("LOWER(%s) LIKE LOWER(%s) ESCAPE '!'", expr, placeholder)
+			}
+			return fmt.Sprintf("%s LIKE %s ESCAPE '!'", expr, placeholder)
+		},
+		Not: func(condition string) string {
+			return fmt.Sprintf("(%s) IS NOT TRUE", condition)
+		},
+		Pattern: likePattern,
+	}
+	// SQLite is the dialect of SQLite. Since LIKE is case-insensitive in SQLite, case-exact strings are matched with
+	// GLOB.
+	SQLite = Dialect{
+		Placeholder: func(int) string {
+			return "?"
+		},
+		Compare: func(expr, operator, placeholder string, caseInsensitive bool) string {
+			if caseInsensitive {
+				return fmt.Sprintf("LOWER(%s) %s LOWER(%s)", expr, operator, placeholder)
+			}
+			return fmt.Sprintf("%s %s %s", expr, operator, placeholder)
+		},
+		Match: func(expr, placeholder string, caseInsensitive bool) string {
+			if caseInsensitive {
+				return fmt.Sprintf("LOWER(%s) LIKE LOWER(%s) ESCAPE '!'", expr, placeholder)
+			}
+			return fmt.Sprintf("%s GLOB %s", expr, placeholder)
+		},
+		Not: notCoalesce,
+		Pattern: func(value string, anchorStart, anchorEnd, caseInsensitive bool) string {
+			if caseInsensitive {
+				return likePattern(value, anchorStart, anchorEnd, caseInsensitive)
+			}
+			return globPattern(value, anchorStart, anchorEnd)
+		},
+	}
+)
+
+// globPattern returns a GLOB pattern that matches values that contain the given value. If anchorStart or anchorEnd is
+// true, the value must be at the start or the end respectively.
+func globPattern(value string, anchorStart, anchorEnd bool) string {
+	pattern := strings.NewReplacer("[", "[[]", "*", "[*]", "?", "[?]").Replace(value)
+	if !anchorStart {
+		pattern = "*" + pattern
+	}
+	if !anchorEnd {
+		pattern += "*"
+	}
+	return pattern
+}
+
+// likePattern returns a LIKE pattern, with "!" as escape character, that matches values that contain the given value.
+// If anchorStart or anchorEnd is true, the value must be at the start or the end respectively.
+func likePattern(value string, anchorStart, anchorEnd, _ bool) string {
+	pattern := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+	if !anchorStart {
+		pattern = "%" + pattern
+	}
+	if !anchorEnd {
+		pattern += "%"
+	}
+	return pattern
+}
+
+// notCoalesce returns the negation of the given condition, which is true if the condition is false or unknown.
+func notCoalesce(condition string) string {
+	return fmt.Sprintf("NOT COALESCE((%s), FALSE)", condition)
+}
+
+// Dialect contains the hooks that generate the SQL of a specific database. The given expressions are column
+// expressions of a Mapping and placeholders are generated by Placeholder.
+type Dialect struct {
+	// Placeholder returns the placeholder of the bind argument at the given 1-based position, e.g., "$1" or "?".
+	Placeholder func(position int) string
+	// Compare returns a condition that compares the expression with the placeholder using the given operator, one of
+	// "=", "<>", ">", ">=", "<" and "<=". If caseInsensitive is true, strings are compared case-insensitively.
+	Compare func(expr, operator, placeholder string, caseInsensitive bool) string
+	// CompareExact is an optional hook that returns a condition that compares case-exact strings. Defaults to Compare.
+	CompareExact func(expr, operator, placeholder string) string
+	// Match returns a condition that matches the expression with the pattern of the placeholder, as created by
+	// Pattern. If caseInsensitive is true, the pattern is matched case-insensitively.
+	Match func(expr, placeholder string, caseInsensitive bool) string
+	// Not is an optional hook that returns the negation of the given condition. The negation must be true if the
+	// condition is unknown, i.e., NULL because of a missing value, since "not" filters match resources without a value.
+	// Defaults to NOT COALESCE((condition), FALSE).
+	Not func(condition string) string
+	// Pattern returns the pattern that matches strings that contain the given value, used for the "co", "sw" and "ew"
+	// operators. If anchorStart or anchorEnd is true, the value must be at the start or the end respectively.
+	Pattern func(value string, anchorStart, anchorEnd, caseInsensitive bool) string
+}
+
+// not returns the negation of the given condition.
+func (d Dialect) not(condition string) string {
+	if d.Not != nil {
+		return d.Not(condition)
+	}
+	return notCoalesce(condition)
+}
+
+// compareString returns a condition that compares a string expression with the placeholder.
+func (d Dialect) compareString(expr, operator, placeholder string, caseExact bool) string {
+	if caseExact && d.CompareExact != nil {
+		return d.CompareExact(expr, operator, placeholder)
+	}
+	return d.Compare(expr, operator, placeholder, !caseExact)
+}
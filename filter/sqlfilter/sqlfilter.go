@@ -0,0 +1,389 @@
+package sqlfilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	datetime "github.com/di-wu/xsd-datetime"
+	"github.com/elimity-com/scim/internal/translate"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+var operators = map[filter.CompareOperator]string{
+	filter.EQ: "=",
+	filter.NE: "<>",
+	filter.GT: ">",
+	filter.GE: ">=",
+	filter.LT: "<",
+	filter.LE: "<=",
+}
+
+// sqlOperator returns the SQL comparison operator of the given compare operator.
+func sqlOperator(op filter.CompareOperator) (string, error) {
+	operator, ok := operators[op]
+	if !ok {
+		return "", fmt.Errorf("unsupported operator: %s", op)
+	}
+	return operator, nil
+}
+
+// stringPtr returns a pointer to the given string.
+func stringPtr(s string) *string {
+	return &s
+}
+
+// toFloat converts the given compare value to a float.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// toInt converts the given compare value to an integer.
+func toInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, false
+		}
+		return int64(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// Mapping maps the attributes of a resource type to SQL. The column expressions and table names are embedded in the
+// generated SQL as is, so they must never contain user input.
+type Mapping struct {
+	// Columns maps attribute paths, e.g., "userName", "name.familyName", "meta.created" or
+	// "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber", to column expressions. Attribute
+	// paths are case-insensitive and extension attributes may also be mapped without their URI prefix.
+	Columns map[string]string
+	// Tables maps the names of multi-valued attributes, e.g., "emails", to the child tables that contain their values.
+	Tables map[string]Table
+}
+
+// column returns the column expression of the given attribute path.
+func (m Mapping) column(s schema.Schema, attrPath filter.AttributePath) (string, bool) {
+	path := attrPath.AttributeName
+	if sub := attrPath.SubAttributeName(); sub != "" {
+		path += "." + sub
+	}
+	if column, ok := translate.Lookup(m.Columns, s.ID+":"+path); ok {
+		return column, true
+	}
+	return translate.Lookup(m.Columns, path)
+}
+
+// table returns the child table of the multi-valued attribute with the given name.
+func (m Mapping) table(s schema.Schema, name string) (Table, bool) {
+	for _, key := range []string{s.ID + ":" + name, name} {
+		if table, ok := m.Tables[key]; ok {
+			return table, true
+		}
+		for k, table := range m.Tables {
+			if strings.EqualFold(k, key) {
+				return table, true
+			}
+		}
+	}
+	return Table{}, false
+}
+
+// Table describes the child table that contains the values of a multi-valued attribute. Each value is stored in a
+// separate row.
+type Table struct {
+	// Name is the name of the table, e.g., "user_emails".
+	Name string
+	// Join is the condition that joins the rows of the table to their resource, e.g., "user_emails.user_id = users.id".
+	Join string
+	// Columns maps sub-attribute names, e.g., "value" or "type", to column expressions. The values of multi-valued
+	// attributes without sub-attributes are mapped with "value".
+	Columns map[string]string
+}
+
+// Translator translates filter expressions into parameterised SQL conditions that can be used in a WHERE clause.
+type Translator struct {
+	dialect Dialect
+	mapping Mapping
+	schemas translate.Schemas
+}
+
+// NewTranslator returns a translator that translates filters of resources with the given schema, including its common
+// attributes, and extensions, using the given dialect and attribute mapping.
+func NewTranslator(dialect Dialect, mapping Mapping, s schema.Schema, exts ...schema.Schema) Translator {
+	return Translator{
+		dialect: dialect,
+		mapping: mapping,
+		schemas: translate.NewSchemas(s, exts...),
+	}
+}
+
+// Translate translates the given filter expression into a SQL condition and its bind arguments. Placeholders are
+// numbered starting from one. The expression is expected to be validated against the schema of the translator, e.g.,
+// the filter of a validator within the list request parameters.
+func (t Translator) Translate(e filter.Expression) (string, []interface{}, error) {
+	tr := translation{Translator: t}
+	condition, err := tr.expression(e, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return condition, tr.args, nil
+}
+
+// scope is the multi-valued attribute, and its child table, to which the attribute paths within a value path apply.
+type scope struct {
+	attr  schema.CoreAttribute
+	table Table
+}
+
+// translation keeps track of the bind arguments of a single translation.
+type translation struct {
+	Translator
+	args []interface{}
+}
+
+// attributeExpression translates the given attribute expression. If a scope is given, the attribute path refers to
+// one of the sub-attributes of the multi-valued attribute of that scope.
+func (t *translation) attributeExpression(e *filter.AttributeExpression, sc *scope) (string, error) {
+	if sc != nil {
+		attr := sc.attr
+		if attr.HasSubAttributes() {
+			sub, ok := attr.SubAttributes().ContainsAttribute(e.AttributePath.AttributeName)
+			if !ok {
+				return "", fmt.Errorf("unknown sub-attribute: %s", e.AttributePath)
+			}
+			attr = sub
+		}
+		column, ok := translate.Lookup(sc.table.Columns, e.AttributePath.AttributeName)
+		if !ok {
+			return "", fmt.Errorf("no column mapped for attribute: %s.%s", sc.attr.Name(), e.AttributePath.AttributeName)
+		}
+		return t.compare(column, attr, e.Operator, e.CompareValue)
+	}
+
+	s, _, attr, err := t.schemas.Resolve(e.AttributePath)
+	if err != nil {
+		return "", err
+	}
+	subName := e.AttributePath.SubAttributeName()
+
+	if attr.MultiValued() {
+		table, ok := t.mapping.table(s, attr.Name())
+		if !ok {
+			return "", fmt.Errorf("no table mapped for multi-valued attribute: %s", attr.Name())
+		}
+		if subName == "" {
+			if e.Operator == filter.PR {
+				return t.exists(table, ""), nil
+			}
+			subName = "value"
+		}
+		condition, err := t.attributeExpression(&filter.AttributeExpression{
+			AttributePath: filter.AttributePath{AttributeName: subName},
+			Operator:      e.Operator,
+			CompareValue:  e.CompareValue,
+		}, &scope{attr: attr, table: table})
+		if err != nil {
+			return "", err
+		}
+		return t.exists(table, condition), nil
+	}
+
+	if subName == "" && attr.HasSubAttributes() {
+		if e.Operator != filter.PR {
+			return "", fmt.Errorf("complex attribute %s only supports the pr operator", attr.Name())
+		}
+		var conditions []string
+		for _, sub := range attr.SubAttributes() {
+			column, ok := t.mapping.column(s, filter.AttributePath{
+				AttributeName: attr.Name(),
+				SubAttribute:  stringPtr(sub.Name()),
+			})
+			if !ok {
+				continue
+			}
+			condition, _ := t.compare(column, sub, filter.PR, nil)
+			conditions = append(conditions, condition)
+		}
+		if len(conditions) == 0 {
+			return "", fmt.Errorf("no columns mapped for complex attribute: %s", attr.Name())
+		}
+		return "(" + strings.Join(conditions, " OR ") + ")", nil
+	}
+
+	column, ok := t.mapping.column(s, e.AttributePath)
+	if !ok {
+		return "", fmt.Errorf("no column mapped for attribute: %s", e.AttributePath)
+	}
+	if subName != "" {
+		sub, ok := attr.SubAttributes().ContainsAttribute(subName)
+		if !ok {
+			return "", fmt.Errorf("unknown sub-attribute: %s", e.AttributePath)
+		}
+		attr = sub
+	}
+	return t.compare(column, attr, e.Operator, e.CompareValue)
+}
+
+// bind adds the given bind argument and returns its placeholder.
+func (t *translation) bind(arg interface{}) string {
+	t.args = append(t.args, arg)
+	return t.dialect.Placeholder(len(t.args))
+}
+
+// compare returns a condition that compares the given column of the given attribute with the compare value.
+func (t *translation) compare(column string, attr schema.CoreAttribute, op filter.CompareOperator, value interface{}) (string, error) {
+	typ := attr.AttributeType()
+	if op == filter.PR {
+		if typ == "string" || typ == "reference" || typ == "binary" {
+			return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", column, column), nil
+		}
+		return fmt.Sprintf("%s IS NOT NULL", column), nil
+	}
+
+	if value == nil {
+		switch op {
+		case filter.EQ:
+			return fmt.Sprintf("%s IS NULL", column), nil
+		case filter.NE:
+			return fmt.Sprintf("%s IS NOT NULL", column), nil
+		default:
+			return "", fmt.Errorf("invalid operator for null value: %s", op)
+		}
+	}
+
+	switch typ {
+	case "string", "reference", "binary":
+		str, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("a %s attribute can not be compared to %v", typ, value)
+		}
+		// References and binary values are always case-exact.
+		caseExact := attr.CaseExact() || typ != "string"
+		switch op {
+		case filter.CO, filter.SW, filter.EW:
+			pattern := t.dialect.Pattern(str, op == filter.SW, op == filter.EW, !caseExact)
+			return t.dialect.Match(column, t.bind(pattern), !caseExact), nil
+		}
+		operator, err := sqlOperator(op)
+		if err != nil {
+			return "", err
+		}
+		return t.dialect.compareString(column, operator, t.bind(str), caseExact), nil
+	case "dateTime":
+		str, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("a dateTime attribute can not be compared to %v", value)
+		}
+		date, err := datetime.Parse(str)
+		if err != nil {
+			return "", fmt.Errorf("invalid dateTime: %s", str)
+		}
+		return t.compareValue(column, op, date)
+	case "integer":
+		i, ok := toInt(value)
+		if !ok {
+			return "", fmt.Errorf("an integer attribute can not be compared to %v", value)
+		}
+		return t.compareValue(column, op, i)
+	case "decimal":
+		f, ok := toFloat(value)
+		if !ok {
+			return "", fmt.Errorf("a decimal attribute can not be compared to %v", value)
+		}
+		return t.compareValue(column, op, f)
+	case "boolean":
+		b, ok := value.(bool)
+		if !ok {
+			return "", fmt.Errorf("a boolean attribute can not be compared to %v", value)
+		}
+		if op != filter.EQ && op != filter.NE {
+			return "", fmt.Errorf("invalid operator for boolean attribute: %s", op)
+		}
+		return t.compareValue(column, op, b)
+	default:
+		return "", fmt.Errorf("attributes of type %s can not be compared", typ)
+	}
+}
+
+// compareValue returns a condition that compares the given column with the given non-string value.
+func (t *translation) compareValue(column string, op filter.CompareOperator, value interface{}) (string, error) {
+	operator, err := sqlOperator(op)
+	if err != nil {
+		return "", err
+	}
+	return t.dialect.Compare(column, operator, t.bind(value), false), nil
+}
+
+// exists returns a condition that checks whether the given table contains a row, that matches the given condition,
+// for the resource.
+func (t *translation) exists(table Table, condition string) string {
+	where := table.Join
+	if condition != "" {
+		where = fmt.Sprintf("%s AND %s", where, condition)
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %s)", table.Name, where)
+}
+
+// expression translates the given expression.
+func (t *translation) expression(e filter.Expression, sc *scope) (string, error) {
+	switch e := e.(type) {
+	case *filter.AttributeExpression:
+		return t.attributeExpression(e, sc)
+	case *filter.LogicalExpression:
+		left, err := t.expression(e.Left, sc)
+		if err != nil {
+			return "", err
+		}
+		right, err := t.expression(e.Right, sc)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(string(e.Operator)), right), nil
+	case *filter.NotExpression:
+		condition, err := t.expression(e.Expression, sc)
+		if err != nil {
+			return "", err
+		}
+		return t.dialect.not(condition), nil
+	case *filter.ValuePath:
+		if sc != nil {
+			return "", fmt.Errorf("nested value paths are not supported")
+		}
+		s, _, attr, err := t.schemas.Resolve(e.AttributePath)
+		if err != nil {
+			return "", err
+		}
+		if !attr.MultiValued() {
+			return "", fmt.Errorf("value paths are only supported on multi-valued attributes: %s", attr.Name())
+		}
+		table, ok := t.mapping.table(s, attr.Name())
+		if !ok {
+			return "", fmt.Errorf("no table mapped for multi-valued attribute: %s", attr.Name())
+		}
+		condition, err := t.expression(e.ValueFilter, &scope{attr: attr, table: table})
+		if err != nil {
+			return "", err
+		}
+		return t.exists(table, condition), nil
+	default:
+		return "", fmt.Errorf("unknown expression type: %T", e)
+	}
+}
@@ -0,0 +1,238 @@
+package sqlfilter
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+	_ "modernc.org/sqlite"
+)
+
+func TestTranslatorTranslate(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, test := range []struct {
+		filter   string
+		dialect  Dialect
+		expected string
+		args     []interface{}
+	}{
+		{
+			filter:   `userName eq "Alice"`,
+			dialect:  PostgreSQL,
+			expected: `LOWER(users.user_name) = LOWER($1)`,
+			args:     []interface{}{"Alice"},
+		},
+		{
+			filter:   `id eq "0001" and externalId ne "a"`,
+			dialect:  PostgreSQL,
+			expected: `(users.id = $1 AND users.external_id <> $2)`,
+			args:     []interface{}{"0001", "a"},
+		},
+		{
+			filter:   `id eq "0001"`,
+			dialect:  MySQL,
+			expected: `CAST(users.id AS BINARY) = CAST(? AS BINARY)`,
+			args:     []interface{}{"0001"},
+		},
+		{
+			filter:   `name.familyName co "50%_off!"`,
+			dialect:  PostgreSQL,
+			expected: `LOWER(users.family_name) LIKE LOWER($1) ESCAPE '!'`,
+			args:     []interface{}{"%50!%!_off!!%"},
+		},
+		{
+			filter:   `externalId sw "a*b"`,
+			dialect:  SQLite,
+			expected: `users.external_id GLOB ?`,
+			args:     []interface{}{"a[*]b*"},
+		},
+		{
+			filter:   `externalId ew "a_"`,
+			dialect:  PostgreSQL,
+			expected: `users.external_id LIKE $1 ESCAPE '!'`,
+			args:     []interface{}{"%a!_"},
+		},
+		{
+			filter:   `title pr or not (active eq true)`,
+			dialect:  SQLite,
+			expected: `((users.title IS NOT NULL AND users.title <> '') OR NOT COALESCE((users.active = ?), FALSE))`,
+			args:     []interface{}{true},
+		},
+		{
+			filter:   `not (title eq "Boss")`,
+			dialect:  PostgreSQL,
+			expected: `(LOWER(users.title) = LOWER($1)) IS NOT TRUE`,
+			args:     []interface{}{"Boss"},
+		},
+		{
+			filter:   `not (title eq "Boss")`,
+			dialect:  MySQL,
+			expected: `NOT COALESCE((LOWER(users.title) = LOWER(?)), FALSE)`,
+			args:     []interface{}{"Boss"},
+		},
+		{
+			filter:   `meta.created gt "2020-01-02T03:04:05Z"`,
+			dialect:  PostgreSQL,
+			expected: `users.created > $1`,
+			args:     []interface{}{created},
+		},
+		{
+			filter:   `name pr`,
+			dialect:  PostgreSQL,
+			expected: `((users.family_name IS NOT NULL AND users.family_name <> '') OR (users.given_name IS NOT NULL AND users.given_name <> ''))`,
+		},
+		{
+			filter:   `emails pr`,
+			dialect:  PostgreSQL,
+			expected: `EXISTS (SELECT 1 FROM user_emails WHERE user_emails.user_id = users.id)`,
+		},
+		{
+			filter:   `emails eq "alice@example.com"`,
+			dialect:  PostgreSQL,
+			expected: `EXISTS (SELECT 1 FROM user_emails WHERE user_emails.user_id = users.id AND LOWER(user_emails.value) = LOWER($1))`,
+			args:     []interface{}{"alice@example.com"},
+		},
+		{
+			filter:   `userName eq "alice" and emails[type eq "work" and value ew "@example.com"]`,
+			dialect:  PostgreSQL,
+			expected: `(LOWER(users.user_name) = LOWER($1) AND EXISTS (SELECT 1 FROM user_emails WHERE user_emails.user_id = users.id AND (LOWER(user_emails.type) = LOWER($2) AND LOWER(user_emails.value) LIKE LOWER($3) ESCAPE '!')))`,
+			args:     []interface{}{"alice", "work", "%@example.com"},
+		},
+		{
+			filter:   `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber eq "42"`,
+			dialect:  MySQL,
+			expected: `LOWER(users.employee_number) = LOWER(?)`,
+			args:     []interface{}{"42"},
+		},
+		{
+			filter:   `manager.value eq "0002"`,
+			dialect:  PostgreSQL,
+			expected: `LOWER(users.manager_id) = LOWER($1)`,
+			args:     []interface{}{"0002"},
+		},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			e, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			condition, args, err := testTranslator(test.dialect).Translate(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if condition != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, condition)
+			}
+			if !reflect.DeepEqual(test.args, args) {
+				t.Errorf("expected %v, got %v", test.args, args)
+			}
+		})
+	}
+}
+
+func TestTranslatorTranslateMissingValues(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`
+		CREATE TABLE users (id TEXT, user_name TEXT, title TEXT, active BOOLEAN);
+		INSERT INTO users VALUES ('1', 'alice', 'Boss', TRUE), ('2', 'bob', NULL, NULL), ('3', 'charlie', 'Dev', FALSE);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Resources without a value do not match the negated expression, so they do match its negation.
+	for _, test := range []struct {
+		filter   string
+		expected []string
+	}{
+		{filter: `not (title eq "Boss")`, expected: []string{"bob", "charlie"}},
+		{filter: `not (active eq true)`, expected: []string{"bob", "charlie"}},
+		{filter: `not (title eq "Dev" or active eq true)`, expected: []string{"bob"}},
+		{filter: `not (not (title pr))`, expected: []string{"alice", "charlie"}},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			e, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			condition, args, err := testTranslator(SQLite).Translate(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, err := db.Query("SELECT users.user_name FROM users WHERE "+condition+" ORDER BY users.user_name", args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			var userNames []string
+			for rows.Next() {
+				var userName string
+				if err := rows.Scan(&userName); err != nil {
+					t.Fatal(err)
+				}
+				userNames = append(userNames, userName)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(userNames, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("expected %v, got %v", test.expected, userNames)
+			}
+		})
+	}
+}
+
+func TestTranslatorTranslateErrors(t *testing.T) {
+	for _, f := range []string{
+		`nickName eq "a"`,
+		`unknown eq "a"`,
+		`phoneNumbers[type eq "work"]`,
+		`name eq "a"`,
+		`active gt true`,
+		`meta.created gt "yesterday"`,
+	} {
+		t.Run(f, func(t *testing.T) {
+			e, err := filter.ParseFilter([]byte(f))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := testTranslator(PostgreSQL).Translate(e); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func testTranslator(dialect Dialect) Translator {
+	return NewTranslator(dialect, Mapping{
+		Columns: map[string]string{
+			"id":              "users.id",
+			"externalId":      "users.external_id",
+			"userName":        "users.user_name",
+			"name.familyName": "users.family_name",
+			"name.givenName":  "users.given_name",
+			"title":           "users.title",
+			"active":          "users.active",
+			"meta.created":    "users.created",
+			"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber": "users.employee_number",
+			"manager.value": "users.manager_id",
+		},
+		Tables: map[string]Table{
+			"emails": {
+				Name: "user_emails",
+				Join: "user_emails.user_id = users.id",
+				Columns: map[string]string{
+					"value": "user_emails.value",
+					"type":  "user_emails.type",
+				},
+			},
+		},
+	}, schema.CoreUserSchema(), schema.ExtensionEnterpriseUser())
+}
@@ -0,0 +1,94 @@
+package filter
+
+import (
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+// PushDownNot returns an equivalent expression in which negations only apply to attribute expressions and value
+// paths, by applying De Morgan's laws and removing double negations. The given expression is left unchanged.
+//
+// Negated attribute expressions are not replaced by their complement, e.g., `not (title eq "a")` does not become
+// `title ne "a"`, since they differ for resources without a title.
+func PushDownNot(e filter.Expression) filter.Expression {
+	return pushDownNot(e, false)
+}
+
+// conjunction returns the conjunction of the given expressions, or nil if there are no expressions.
+func conjunction(es []filter.Expression) filter.Expression {
+	if len(es) == 0 {
+		return nil
+	}
+	e := es[0]
+	for _, right := range es[1:] {
+		e = &filter.LogicalExpression{
+			Left:     e,
+			Operator: filter.AND,
+			Right:    right,
+		}
+	}
+	return e
+}
+
+// conjuncts returns the operands of the given expression if it is a conjunction, nested conjunctions are flattened.
+func conjuncts(e filter.Expression) []filter.Expression {
+	l, ok := e.(*filter.LogicalExpression)
+	if !ok || l.Operator != filter.AND {
+		return []filter.Expression{e}
+	}
+	return append(conjuncts(l.Left), conjuncts(l.Right)...)
+}
+
+// pushDownNot returns the (negated if negate is true) expression in which negations are pushed down.
+func pushDownNot(e filter.Expression, negate bool) filter.Expression {
+	switch e := e.(type) {
+	case *filter.NotExpression:
+		return pushDownNot(e.Expression, !negate)
+	case *filter.LogicalExpression:
+		op := e.Operator
+		if negate {
+			// e.g. not (a or b) = not a and not b
+			if op == filter.AND {
+				op = filter.OR
+			} else {
+				op = filter.AND
+			}
+		}
+		return &filter.LogicalExpression{
+			Left:     pushDownNot(e.Left, negate),
+			Operator: op,
+			Right:    pushDownNot(e.Right, negate),
+		}
+	case *filter.ValuePath:
+		var vp filter.Expression = &filter.ValuePath{
+			AttributePath: e.AttributePath,
+			ValueFilter:   pushDownNot(e.ValueFilter, false),
+		}
+		if negate {
+			return &filter.NotExpression{Expression: vp}
+		}
+		return vp
+	default:
+		if negate {
+			return &filter.NotExpression{Expression: e}
+		}
+		return e
+	}
+}
+
+// Node represents an attribute expression or value path within a filter, resolved against the schemas of a validator.
+type Node struct {
+	// Expression is either an *filter.AttributeExpression or a *filter.ValuePath.
+	Expression filter.Expression
+	// Schema is the schema or extension that defines the attribute.
+	Schema schema.Schema
+	// Attribute is the top-level attribute to which the expression applies.
+	Attribute schema.CoreAttribute
+	// SubAttribute is the sub-attribute to which the expression applies, or nil if it applies to the attribute itself.
+	SubAttribute *schema.CoreAttribute
+	// Path is the full path of the (sub-)attribute, qualified with the id of the schema and with the names of the
+	// attributes as defined by the schema, e.g., "urn:ietf:params:scim:schemas:core:2.0:User:emails.type".
+	Path filter.AttributePath
+	// ValuePath is the value path of which the value filter contains the expression, or nil if there is none.
+	ValuePath *filter.ValuePath
+}
@@ -0,0 +1,182 @@
+package filter_test
+
+import (
+	"fmt"
+	"testing"
+
+	internal "github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+func TestPushDownNot(t *testing.T) {
+	for _, test := range []struct {
+		filter   string
+		expected string
+	}{
+		{filter: `not (title pr)`, expected: `not (title pr)`},
+		{filter: `not (not (title pr))`, expected: `title pr`},
+		{
+			filter:   `not (title pr or userType eq "a")`,
+			expected: `not (title pr) and not (userType eq "a")`,
+		},
+		{
+			filter:   `not (title pr and not (userType eq "a" or active eq true))`,
+			expected: `not (title pr) or userType eq "a" or active eq true`,
+		},
+		{
+			filter:   `not (emails[not (type eq "work")])`,
+			expected: `not (emails[not (type eq "work")])`,
+		},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			e, err := filter.ParseFilter([]byte(test.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := filter.ParseFilter([]byte(test.expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := internal.PushDownNot(e); fmt.Sprint(actual) != fmt.Sprint(expected) {
+				t.Errorf("expected %s, got %s", expected, actual)
+			}
+		})
+	}
+}
+
+func TestValidator_Rewrite(t *testing.T) {
+	validator, err := internal.NewValidator(
+		`USERNAME eq "a" and emails[type eq "work"] and employeeNumber pr`,
+		schema.CoreUserSchema(), schema.ExtensionEnterpriseUser(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]string{
+		"urn:ietf:params:scim:schemas:core:2.0:User:userName":                       "login",
+		"urn:ietf:params:scim:schemas:core:2.0:User:emails.type":                    "kind",
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber": "number",
+	}
+	e, err := validator.Rewrite(func(n internal.Node) (filter.Expression, error) {
+		name, ok := names[n.Path.String()]
+		if !ok {
+			return n.Expression, nil
+		}
+		e := *n.Expression.(*filter.AttributeExpression)
+		e.AttributePath = filter.AttributePath{AttributeName: name}
+		return &e, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `login eq "a" and emails[kind eq "work"] and number pr`; fmt.Sprint(e) != expected {
+		t.Errorf("expected %s, got %s", expected, e)
+	}
+	if original := fmt.Sprint(validator.GetFilter()); original != `USERNAME eq "a" and emails[type eq "work"] and employeeNumber pr` {
+		t.Errorf("the original filter was modified: %s", original)
+	}
+}
+
+func TestValidator_Split(t *testing.T) {
+	userSchema := schema.CoreUserSchema()
+	userSchema.Attributes = append(userSchema.Attributes, schema.CommonAttributes()...)
+
+	// Only single-valued attributes of the core schema can be pushed down.
+	pushdown := func(n internal.Node) bool {
+		return n.Schema.ID == userSchema.ID && !n.Attribute.MultiValued()
+	}
+	for _, test := range []struct {
+		filter   string
+		pushdown string
+		residual string
+	}{
+		{filter: `userName eq "a"`, pushdown: `userName eq "a"`},
+		{filter: `emails[type eq "work"]`, residual: `emails[type eq "work"]`},
+		{
+			filter:   `userName eq "a" and (emails.value co "b" and meta.created gt "2020-01-01T00:00:00Z")`,
+			pushdown: `userName eq "a" and meta.created gt "2020-01-01T00:00:00Z"`,
+			residual: `emails.value co "b"`,
+		},
+		{
+			filter:   `userName eq "a" or employeeNumber eq "1"`,
+			residual: `userName eq "a" or employeeNumber eq "1"`,
+		},
+		{
+			filter:   `not (title pr or employeeNumber eq "1")`,
+			pushdown: `not (title pr)`,
+			residual: `not (employeeNumber eq "1")`,
+		},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			validator, err := internal.NewValidator(test.filter, userSchema, schema.ExtensionEnterpriseUser())
+			if err != nil {
+				t.Fatal(err)
+			}
+			pushdownFilter, residualFilter, err := validator.Split(pushdown)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range []struct {
+				expected string
+				actual   filter.Expression
+			}{
+				{expected: test.pushdown, actual: pushdownFilter},
+				{expected: test.residual, actual: residualFilter},
+			} {
+				if f.expected == "" {
+					if f.actual != nil {
+						t.Errorf("expected no filter, got %s", f.actual)
+					}
+					continue
+				}
+				expected, err := filter.ParseFilter([]byte(f.expected))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fmt.Sprint(f.actual) != fmt.Sprint(expected) {
+					t.Errorf("expected %s, got %s", expected, f.actual)
+				}
+			}
+		})
+	}
+}
+
+func TestValidator_Walk(t *testing.T) {
+	validator, err := internal.NewValidator(
+		`name.GivenName eq "a" and not (emails[type eq "work" or value ew "@example.com"]) and manager.value pr`,
+		schema.CoreUserSchema(), schema.ExtensionEnterpriseUser(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visited []string
+	if err := validator.Walk(func(n internal.Node) error {
+		typ := n.Attribute.AttributeType()
+		if n.SubAttribute != nil {
+			typ = n.SubAttribute.AttributeType()
+		}
+		visited = append(visited, fmt.Sprintf("%s (%s, %t)", n.Path, typ, n.ValuePath != nil))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"urn:ietf:params:scim:schemas:core:2.0:User:name.givenName (string, false)",
+		"urn:ietf:params:scim:schemas:core:2.0:User:emails (complex, false)",
+		"urn:ietf:params:scim:schemas:core:2.0:User:emails.type (string, true)",
+		"urn:ietf:params:scim:schemas:core:2.0:User:emails.value (string, true)",
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value (string, false)",
+	}
+	if fmt.Sprint(visited) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, visited)
+	}
+
+	invalid, err := internal.NewValidator(`userName eq "a" or unknown pr`, schema.CoreUserSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := invalid.Walk(func(internal.Node) error { return nil }); err == nil {
+		t.Error("expected an error")
+	}
+}
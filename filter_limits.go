@@ -0,0 +1,103 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/elimity-com/scim/errors"
+	f "github.com/elimity-com/scim/filter"
+	"github.com/scim2/filter-parser/v2"
+)
+
+const (
+	defaultFilterMaxClauses        = 100
+	defaultFilterMaxDepth          = 32
+	defaultFilterMaxLength         = 4096
+	defaultFilterMaxValuePathDepth = 1
+)
+
+// filterLimit returns the given limit, or the given default if the limit is zero.
+func filterLimit(limit, def int) int {
+	if limit == 0 {
+		return def
+	}
+	return limit
+}
+
+// filterTooMany returns a "tooMany" error with the given detail.
+func filterTooMany(format string, a ...interface{}) *errors.ScimError {
+	return &errors.ScimError{
+		ScimType: errors.ScimTypeTooMany,
+		Detail:   fmt.Sprintf(format, a...),
+		Status:   http.StatusBadRequest,
+	}
+}
+
+// FilterLimits limits the complexity of the filters that the server accepts in list and search requests, see
+// WithFilterLimits. Filters that exceed one of the limits are rejected with a "tooMany" error. A limit of zero means
+// that the default limit is used and a negative limit means that there is no limit.
+type FilterLimits struct {
+	// MaxLength is the maximum length of a filter in bytes, which is checked before the filter is parsed. The default
+	// is 4096.
+	MaxLength int
+	// MaxDepth is the maximum depth of the expression tree of a filter, see filter.Complexity. The nesting of the
+	// parentheses and brackets of a filter is checked against it before the filter is parsed, see filter.NestingDepth.
+	// The default is 32.
+	MaxDepth int
+	// MaxClauses is the maximum number of attribute expressions in a filter, e.g., `userName eq "a"`. The default is
+	// 100.
+	MaxClauses int
+	// MaxValuePathDepth is the maximum number of nested value paths in a filter, e.g., `emails[type eq "work"]`. The
+	// default is 1.
+	MaxValuePathDepth int
+}
+
+// checkComplexity returns a "tooMany" error if the given (parsed) filter exceeds the limits.
+func (l FilterLimits) checkComplexity(e filter.Expression) *errors.ScimError {
+	var (
+		maxClauses        = filterLimit(l.MaxClauses, defaultFilterMaxClauses)
+		maxDepth          = filterLimit(l.MaxDepth, defaultFilterMaxDepth)
+		maxValuePathDepth = filterLimit(l.MaxValuePathDepth, defaultFilterMaxValuePathDepth)
+	)
+	c := f.MeasureComplexity(e)
+	switch {
+	case 0 < maxClauses && maxClauses < c.Clauses:
+		return filterTooMany("The filter contains %d clauses, which exceeds the limit MaxClauses of %d.", c.Clauses, maxClauses)
+	case 0 < maxDepth && maxDepth < c.Depth:
+		return filterTooMany("The filter is nested %d levels deep, which exceeds the limit MaxDepth of %d.", c.Depth, maxDepth)
+	case 0 < maxValuePathDepth && maxValuePathDepth < c.ValuePathDepth:
+		return filterTooMany(
+			"The filter contains %d nested value paths, which exceeds the limit MaxValuePathDepth of %d.",
+			c.ValuePathDepth, maxValuePathDepth,
+		)
+	}
+	return nil
+}
+
+// checkRootFilter returns a "tooMany" error if the given filter of a query across all resource types exceeds the
+// limits. Since such filters are passed to the RootQueryHandler as is, filters that can not be parsed are only checked
+// before parsing.
+func (l FilterLimits) checkRootFilter(text string) *errors.ScimError {
+	if scimErr := l.checkText(text); scimErr != nil {
+		return scimErr
+	}
+	e, err := filter.ParseFilter([]byte(text))
+	if err != nil {
+		return nil
+	}
+	return l.checkComplexity(e)
+}
+
+// checkText returns a "tooMany" error if the given filter exceeds the maximum length, or if its parentheses and
+// brackets are nested deeper than the maximum depth. Unlike the other limits, these are checked before parsing.
+func (l FilterLimits) checkText(text string) *errors.ScimError {
+	maxLength := filterLimit(l.MaxLength, defaultFilterMaxLength)
+	if 0 < maxLength && maxLength < len(text) {
+		return filterTooMany("The filter is %d bytes long, which exceeds the limit MaxLength of %d.", len(text), maxLength)
+	}
+	maxDepth := filterLimit(l.MaxDepth, defaultFilterMaxDepth)
+	if depth := f.NestingDepth(text); 0 < maxDepth && maxDepth < depth {
+		return filterTooMany("The filter is nested %d levels deep, which exceeds the limit MaxDepth of %d.", depth, maxDepth)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+func TestServerFilterLimits(t *testing.T) {
+	s := newTestServerWithSearchHandler(t)
+	WithRootQueryHandler(testRootQueryHandler{})(&s)
+	WithFilterLimits(FilterLimits{
+		MaxLength:         200,
+		MaxDepth:          4,
+		MaxClauses:        3,
+		MaxValuePathDepth: 1,
+	})(&s)
+
+	for _, test := range []struct {
+		filter   string
+		scimType errors.ScimType
+		detail   string
+		// root indicates whether the filter is also checked in queries across all resource types.
+		root bool
+	}{
+		{filter: `userName eq "test01"`, root: true},
+		{filter: `userName eq "test01" or userName eq "test02" or emails[type eq "work"]`, root: true},
+		{
+			filter:   `userName eq "` + strings.Repeat("a", 200) + `"`,
+			scimType: errors.ScimTypeTooMany, detail: "214 bytes long, which exceeds the limit MaxLength of 200", root: true,
+		},
+		{
+			filter:   `userName pr and title pr and nickName pr and displayName pr`,
+			scimType: errors.ScimTypeTooMany, detail: "4 clauses, which exceeds the limit MaxClauses of 3", root: true,
+		},
+		{
+			filter:   `not (not (not (not (title pr))))`,
+			scimType: errors.ScimTypeTooMany, detail: "5 levels deep, which exceeds the limit MaxDepth of 4", root: true,
+		},
+		// The nesting is checked before the filter is parsed.
+		{
+			filter:   `(((((title pr`,
+			scimType: errors.ScimTypeTooMany, detail: "5 levels deep, which exceeds the limit MaxDepth of 4", root: true,
+		},
+		{filter: `userName xx "test01"`, scimType: errors.ScimTypeInvalidFilter},
+		{filter: `unknown pr`, scimType: errors.ScimTypeInvalidFilter},
+	} {
+		t.Run(test.filter, func(t *testing.T) {
+			endpoints := []string{"/Users"}
+			if test.root {
+				endpoints = append(endpoints, "/")
+			}
+			var reqs []*http.Request
+			for _, endpoint := range endpoints {
+				reqs = append(reqs,
+					httptest.NewRequest(http.MethodGet, endpoint+"?filter="+url.QueryEscape(test.filter), nil),
+					httptest.NewRequest(http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/.search", marshalSearchRequest(t, searchRequest{
+						Schemas: []string{"urn:ietf:params:scim:api:messages:2.0:SearchRequest"},
+						Filter:  test.filter,
+					})),
+				)
+			}
+			for _, req := range reqs {
+				rr := httptest.NewRecorder()
+				s.ServeHTTP(rr, req)
+
+				if test.scimType == "" {
+					assertEqualStatusCode(t, http.StatusOK, rr.Code)
+					continue
+				}
+				assertEqualStatusCode(t, http.StatusBadRequest, rr.Code)
+				var scimErr errors.ScimError
+				assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &scimErr))
+				assertEqual(t, test.scimType, scimErr.ScimType)
+				if !strings.Contains(scimErr.Detail, test.detail) {
+					t.Errorf("unexpected detail: %s", scimErr.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestServerFilterLimitsDefault(t *testing.T) {
+	chain := func(clauses int) string {
+		return strings.Repeat(`userName eq "test01" or `, clauses-1) + `userName eq "test02"`
+	}
+	for _, test := range []struct {
+		filter   string
+		limits   FilterLimits
+		expected int
+	}{
+		// Chains of the same logical operator are flattened, so long chains do not exceed the default MaxDepth.
+		{filter: chain(40), expected: http.StatusOK},
+		{filter: chain(101), expected: http.StatusBadRequest},
+		{
+			filter:   chain(101),
+			limits:   FilterLimits{MaxLength: -1, MaxDepth: -1, MaxClauses: -1, MaxValuePathDepth: -1},
+			expected: http.StatusOK,
+		},
+	} {
+		s := newTestServerWithSearchHandler(t)
+		WithFilterLimits(test.limits)(&s)
+
+		req := httptest.NewRequest(http.MethodGet, "/Users?filter="+url.QueryEscape(test.filter), nil)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		assertEqualStatusCode(t, test.expected, rr.Code)
+	}
+}
@@ -5,4 +5,5 @@ go 1.16
 require (
 	github.com/di-wu/xsd-datetime v1.0.0
 	github.com/scim2/filter-parser/v2 v2.2.0
+	modernc.org/sqlite v1.23.1
 )
@@ -3,9 +3,9 @@ package scim
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/elimity-com/scim/errors"
-	"github.com/elimity-com/scim/filter"
 	"github.com/elimity-com/scim/schema"
 )
 
@@ -30,6 +30,60 @@ func (s Server) errorHandler(w http.ResponseWriter, scimErr *errors.ScimError) {
 	}
 }
 
+// meHandler receives an HTTP request to the "/Me" alias and delegates it to the resource handler of the resource type
+// that the authenticated subject resolves to. Per RFC 7644 Section 3.11, a POST creates a new resource of that type.
+func (s Server) meHandler(w http.ResponseWriter, r *http.Request) {
+	name, id, err := s.meResolver.ResolveMe(r)
+	if err != nil {
+		scimErr := errors.CheckScimError(err, r.Method)
+		s.errorHandler(w, &scimErr)
+		return
+	}
+
+	var (
+		resourceType ResourceType
+		found        bool
+	)
+	for _, t := range s.resourceTypes {
+		if t.Name == name {
+			resourceType, found = t, true
+			break
+		}
+	}
+	if !found {
+		s.errorHandler(w, &errors.ScimErrorInternal)
+		s.log.Error(
+			"me resolver returned an unknown resource type",
+			"resourceType", name,
+		)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		s.resourcePostHandler(w, r, resourceType)
+		return
+	}
+
+	if id == "" {
+		scimErr := errors.ScimErrorResourceNotFound("Me")
+		s.errorHandler(w, &scimErr)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.resourceGetHandler(w, r, id, resourceType)
+	case http.MethodPut:
+		s.resourcePutHandler(w, r, id, resourceType)
+	case http.MethodPatch:
+		s.resourcePatchHandler(w, r, id, resourceType)
+	case http.MethodDelete:
+		s.resourceDeleteHandler(w, r, id, resourceType)
+	default:
+		s.errorHandler(w, &errors.ScimError{Status: http.StatusMethodNotAllowed})
+	}
+}
+
 // parseSearchRequest reads and parses a search request body, returning a SearchParams.
 func (s Server) parseSearchRequest(r *http.Request) (searchRequest, SearchParams, *errors.ScimError) {
 	data, err := readBody(r)
@@ -50,7 +104,7 @@ func (s Server) parseSearchRequest(r *http.Request) (searchRequest, SearchParams
 		return searchRequest{}, SearchParams{}, &errors.ScimErrorInvalidValue
 	}
 
-	if sr.SortOrder != "" && sr.SortOrder != "ascending" && sr.SortOrder != "descending" {
+	if sr.Cursor != nil && sr.StartIndex != nil {
 		return searchRequest{}, SearchParams{}, &errors.ScimErrorInvalidValue
 	}
 
@@ -89,6 +143,11 @@ func (s Server) parseSearchRequest(r *http.Request) (searchRequest, SearchParams
 // resourceDeleteHandler receives an HTTP DELETE request to the resource endpoint, e.g., "/Users/{id}" or "/Groups/{id}",
 // where "{id}" is a resource identifier to delete a known resource.
 func (s Server) resourceDeleteHandler(w http.ResponseWriter, r *http.Request, id string, resourceType ResourceType) {
+	r, ok := s.checkPreconditions(w, r, id, resourceType)
+	if !ok {
+		return
+	}
+
 	deleteErr := resourceType.Handler.Delete(r, id)
 	if deleteErr != nil {
 		scimErr := errors.CheckScimError(deleteErr, http.MethodDelete)
@@ -109,8 +168,20 @@ func (s Server) resourceGetHandler(w http.ResponseWriter, r *http.Request, id st
 		return
 	}
 
+	if s.config.SupportETag {
+		switch preconditionStatus(r, resource.Meta.Version) {
+		case http.StatusNotModified:
+			w.Header().Set("Etag", resource.Meta.Version)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case http.StatusPreconditionFailed:
+			s.errorHandler(w, preconditionFailed())
+			return
+		}
+	}
+
 	location := resourceLocation(resourceType, id, s.baseURL)
-	raw, err := json.Marshal(resource.response(resourceType, location))
+	raw, err := json.Marshal(projectionFromQuery(r).apply(resourceType, resource.response(resourceType, location)))
 	if err != nil {
 		s.errorHandler(w, &errors.ScimErrorInternal)
 		s.log.Error(
@@ -143,7 +214,20 @@ func (s Server) resourcePatchHandler(w http.ResponseWriter, r *http.Request, id
 		return
 	}
 
-	resource, patchErr := resourceType.Handler.Patch(r, id, patch)
+	r, ok := s.checkPreconditions(w, r, id, resourceType)
+	if !ok {
+		return
+	}
+
+	var (
+		resource Resource
+		patchErr error
+	)
+	if patcher, ok := resourceType.Handler.(ResourcePatcher); ok {
+		resource, patchErr = patcher.Patch(r, id, patch)
+	} else {
+		resource, patchErr = resourceType.patchWithReplace(r, id, patch)
+	}
 	if patchErr != nil {
 		scimErr := errors.CheckScimError(patchErr, http.MethodPatch)
 		s.errorHandler(w, &scimErr)
@@ -156,7 +240,7 @@ func (s Server) resourcePatchHandler(w http.ResponseWriter, r *http.Request, id
 	}
 
 	location := resourceLocation(resourceType, id, s.baseURL)
-	raw, err := json.Marshal(resource.response(resourceType, location))
+	raw, err := json.Marshal(projectionFromQuery(r).apply(resourceType, resource.response(resourceType, location)))
 	if err != nil {
 		s.errorHandler(w, &errors.ScimErrorInternal)
 		s.log.Error(
@@ -201,7 +285,7 @@ func (s Server) resourcePostHandler(w http.ResponseWriter, r *http.Request, reso
 	}
 
 	location := resourceLocation(resourceType, resource.ID, s.baseURL)
-	raw, err := json.Marshal(resource.response(resourceType, location))
+	raw, err := json.Marshal(projectionFromQuery(r).apply(resourceType, resource.response(resourceType, location)))
 	if err != nil {
 		s.errorHandler(w, &errors.ScimErrorInternal)
 		s.log.Error(
@@ -239,6 +323,11 @@ func (s Server) resourcePutHandler(w http.ResponseWriter, r *http.Request, id st
 		return
 	}
 
+	r, ok := s.checkPreconditions(w, r, id, resourceType)
+	if !ok {
+		return
+	}
+
 	resource, putError := resourceType.Handler.Replace(r, id, attributes)
 	if putError != nil {
 		scimErr := errors.CheckScimError(putError, http.MethodPut)
@@ -247,7 +336,7 @@ func (s Server) resourcePutHandler(w http.ResponseWriter, r *http.Request, id st
 	}
 
 	location := resourceLocation(resourceType, id, s.baseURL)
-	raw, err := json.Marshal(resource.response(resourceType, location))
+	raw, err := json.Marshal(projectionFromQuery(r).apply(resourceType, resource.response(resourceType, location)))
 	if err != nil {
 		s.errorHandler(w, &errors.ScimErrorInternal)
 		s.log.Error(
@@ -283,26 +372,50 @@ func (s Server) resourceSearchHandler(w http.ResponseWriter, r *http.Request, re
 		return
 	}
 
-	_, params, scimErr := s.parseSearchRequest(r)
+	sr, params, scimErr := s.parseSearchRequest(r)
 	if scimErr != nil {
 		s.errorHandler(w, scimErr)
 		return
 	}
 
 	if params.Filter != "" {
-		validator, err := filter.NewValidator(params.Filter, resourceType.Schema, resourceType.getSchemaExtensions()...)
-		if err != nil {
-			s.errorHandler(w, &errors.ScimErrorInvalidFilter)
+		validator, scimErr := newFilterValidator(params.Filter, s.filterLimits, resourceType.Schema, resourceType.getSchemaExtensions()...)
+		if scimErr != nil {
+			s.errorHandler(w, scimErr)
 			return
 		}
-		if err := validator.Validate(); err != nil {
-			s.errorHandler(w, &errors.ScimErrorInvalidFilter)
+		params.FilterValidator = validator
+	}
+
+	params.SortBy, params.SortOrder, scimErr = parseSortParams(&resourceType, params.SortBy, params.SortOrder)
+	if scimErr != nil {
+		s.errorHandler(w, scimErr)
+		return
+	}
+
+	var (
+		page      Page
+		searchErr error
+	)
+	if sr.Cursor != nil {
+		paginator, scimErr := s.cursorPaginator(resourceType.Handler)
+		if scimErr != nil {
+			s.errorHandler(w, scimErr)
 			return
 		}
-		params.FilterValidator = &validator
+		params.StartIndex = 0
+		page, searchErr = paginator.GetAllWithCursor(r, ListRequestParams{
+			Attributes:         params.Attributes,
+			Count:              params.Count,
+			Cursor:             *sr.Cursor,
+			ExcludedAttributes: params.ExcludedAttributes,
+			FilterValidator:    params.FilterValidator,
+			SortBy:             params.SortBy,
+			SortOrder:          params.SortOrder,
+		})
+	} else {
+		page, searchErr = searcher.Search(r, params)
 	}
-
-	page, searchErr := searcher.Search(r, params)
 	if searchErr != nil {
 		scimErr := errors.CheckScimError(searchErr, http.MethodPost)
 		s.errorHandler(w, &scimErr)
@@ -310,10 +423,12 @@ func (s Server) resourceSearchHandler(w http.ResponseWriter, r *http.Request, re
 	}
 
 	lr := listResponse{
-		TotalResults: page.TotalResults,
-		Resources:    page.resources(resourceType, s.baseURL),
-		StartIndex:   params.StartIndex,
-		ItemsPerPage: params.Count,
+		TotalResults:   page.TotalResults,
+		Resources:      page.resources(resourceType, s.baseURL, newProjection(params.Attributes, params.ExcludedAttributes)),
+		StartIndex:     params.StartIndex,
+		ItemsPerPage:   params.Count,
+		NextCursor:     page.NextCursor,
+		PreviousCursor: page.PreviousCursor,
 	}
 	raw, err := json.Marshal(lr)
 	if err != nil {
@@ -422,7 +537,27 @@ func (s Server) resourcesGetHandler(w http.ResponseWriter, r *http.Request, reso
 		return
 	}
 
-	page, getError := resourceType.Handler.GetAll(r, params)
+	cursor, useCursor, scimErr := parseCursorParam(r)
+	if scimErr != nil {
+		s.errorHandler(w, scimErr)
+		return
+	}
+
+	var (
+		page     Page
+		getError error
+	)
+	if useCursor {
+		paginator, scimErr := s.cursorPaginator(resourceType.Handler)
+		if scimErr != nil {
+			s.errorHandler(w, scimErr)
+			return
+		}
+		params.Cursor, params.StartIndex = cursor, 0
+		page, getError = paginator.GetAllWithCursor(r, params)
+	} else {
+		page, getError = resourceType.Handler.GetAll(r, params)
+	}
 	if getError != nil {
 		scimErr := errors.CheckScimError(getError, http.MethodGet)
 		s.errorHandler(w, &scimErr)
@@ -430,10 +565,12 @@ func (s Server) resourcesGetHandler(w http.ResponseWriter, r *http.Request, reso
 	}
 
 	lr := listResponse{
-		TotalResults: page.TotalResults,
-		Resources:    page.resources(resourceType, s.baseURL),
-		StartIndex:   params.StartIndex,
-		ItemsPerPage: params.Count,
+		TotalResults:   page.TotalResults,
+		Resources:      page.resources(resourceType, s.baseURL, newProjection(params.Attributes, params.ExcludedAttributes)),
+		StartIndex:     params.StartIndex,
+		ItemsPerPage:   params.Count,
+		NextCursor:     page.NextCursor,
+		PreviousCursor: page.PreviousCursor,
 	}
 	raw, err := json.Marshal(lr)
 	if err != nil {
@@ -463,9 +600,16 @@ func (s Server) rootResourcesGetHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	query := r.URL.Query()
+	if scimErr := s.filterLimits.checkRootFilter(strings.TrimSpace(query.Get("filter"))); scimErr != nil {
+		s.errorHandler(w, scimErr)
+		return
+	}
 	params := ListRequestParams{
-		Count:      count,
-		StartIndex: startIndex,
+		Attributes:         splitAttributeList(query.Get("attributes")),
+		Count:              count,
+		ExcludedAttributes: splitAttributeList(query.Get("excludedAttributes")),
+		StartIndex:         startIndex,
 	}
 
 	page, getError := s.rootQueryHandler.GetAll(r, params)
@@ -477,7 +621,7 @@ func (s Server) rootResourcesGetHandler(w http.ResponseWriter, r *http.Request)
 
 	lr := listResponse{
 		TotalResults: page.TotalResults,
-		Resources:    page.rawResources(),
+		Resources:    page.rawResources(s.resourceTypes, newProjection(params.Attributes, params.ExcludedAttributes)),
 		StartIndex:   params.StartIndex,
 		ItemsPerPage: params.Count,
 	}
@@ -512,6 +656,16 @@ func (s Server) rootSearchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	params.SortBy, params.SortOrder, scimErr = parseSortParams(nil, params.SortBy, params.SortOrder)
+	if scimErr != nil {
+		s.errorHandler(w, scimErr)
+		return
+	}
+	if scimErr := s.filterLimits.checkRootFilter(params.Filter); scimErr != nil {
+		s.errorHandler(w, scimErr)
+		return
+	}
+
 	var (
 		page     Page
 		getError error
@@ -520,8 +674,10 @@ func (s Server) rootSearchHandler(w http.ResponseWriter, r *http.Request) {
 		page, getError = searcher.Search(r, params)
 	} else {
 		page, getError = s.rootQueryHandler.GetAll(r, ListRequestParams{
-			Count:      params.Count,
-			StartIndex: params.StartIndex,
+			Attributes:         params.Attributes,
+			Count:              params.Count,
+			ExcludedAttributes: params.ExcludedAttributes,
+			StartIndex:         params.StartIndex,
 		})
 	}
 	if getError != nil {
@@ -532,7 +688,7 @@ func (s Server) rootSearchHandler(w http.ResponseWriter, r *http.Request) {
 
 	lr := listResponse{
 		TotalResults: page.TotalResults,
-		Resources:    page.rawResources(),
+		Resources:    page.rawResources(s.resourceTypes, newProjection(params.Attributes, params.ExcludedAttributes)),
 		StartIndex:   params.StartIndex,
 		ItemsPerPage: params.Count,
 	}
@@ -673,4 +829,5 @@ type searchRequest struct {
 	SortOrder          string   `json:"sortOrder"`
 	StartIndex         *int     `json:"startIndex"`
 	Count              *int     `json:"count"`
+	Cursor             *string  `json:"cursor"`
 }
@@ -182,6 +182,88 @@ func TestServerMeEndpoint(t *testing.T) {
 	assertEqualStatusCode(t, http.StatusNotImplemented, rr.Code)
 }
 
+func TestServerMeEndpointWithResolver(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		body           io.Reader
+		resolver       testMeResolver
+		expectedStatus int
+	}{
+		{
+			name:           "GET",
+			method:         http.MethodGet,
+			resolver:       testMeResolver{resourceType: "User", id: "0001"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "PUT",
+			method:         http.MethodPut,
+			body:           strings.NewReader(`{"userName": "me", "schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"]}`),
+			resolver:       testMeResolver{resourceType: "User", id: "0001"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "DELETE",
+			method:         http.MethodDelete,
+			resolver:       testMeResolver{resourceType: "User", id: "0001"},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "POST without resource",
+			method:         http.MethodPost,
+			body:           strings.NewReader(`{"userName": "me", "schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"]}`),
+			resolver:       testMeResolver{resourceType: "User"},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "GET without resource",
+			method:         http.MethodGet,
+			resolver:       testMeResolver{resourceType: "User"},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "unauthenticated",
+			method:         http.MethodGet,
+			resolver:       testMeResolver{err: errors.ScimError{Status: http.StatusUnauthorized}},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "unknown resource type",
+			method:         http.MethodGet,
+			resolver:       testMeResolver{resourceType: "Unknown", id: "0001"},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t)
+			WithMeResolver(test.resolver)(&s)
+
+			req := httptest.NewRequest(test.method, "/Me", test.body)
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+
+			assertEqualStatusCode(t, test.expectedStatus, rr.Code)
+		})
+	}
+
+	t.Run("GET returns the resource location", func(t *testing.T) {
+		s := newTestServer(t)
+		WithMeResolver(testMeResolver{resourceType: "User", id: "0001"})(&s)
+
+		req := httptest.NewRequest(http.MethodGet, "/v2/Me", nil)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+
+		var resource map[string]interface{}
+		assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+		assertEqual(t, "0001", resource["id"])
+		assertEqual(t, "Users/0001", resource["meta"].(map[string]interface{})["location"])
+	})
+}
+
 func TestServerResourceDeleteHandler(t *testing.T) {
 	req := httptest.NewRequest(http.MethodDelete, "/Users/0001", nil)
 	rr := httptest.NewRecorder()
@@ -786,6 +868,11 @@ func TestServerResourcePutHandlerWithBaseURL(t *testing.T) {
 
 func TestServerResourceSearch(t *testing.T) {
 	s := newTestServerWithSearchHandler(t)
+	captured := &SearchParams{}
+	s.resourceTypes[0].Handler = testSearchHandler{
+		testResourceHandler: newTestResourceHandler().(testResourceHandler),
+		captured:            captured,
+	}
 
 	count := 10
 	startIndex := 1
@@ -809,22 +896,19 @@ func TestServerResourceSearch(t *testing.T) {
 	assertEqual(t, 1, response.TotalResults)
 	assertEqual(t, 1, len(response.Resources))
 
-	resource := response.Resources[0].(map[string]interface{})
-	assertEqual(t, `userName eq "test01"`, resource["capturedFilter"])
-	assertTrue(t, resource["capturedFilterValid"].(bool))
-	assertEqual(t, float64(10), resource["capturedCount"])
-	assertEqual(t, float64(1), resource["capturedStartIndex"])
-	assertEqual(t, "userName", resource["capturedSortBy"])
-	assertEqual(t, "ascending", resource["capturedSortOrder"])
-
-	attrs := resource["capturedAttributes"].([]interface{})
-	assertLen(t, attrs, 2)
-	assertEqual(t, "userName", attrs[0])
-	assertEqual(t, "emails", attrs[1])
+	assertEqual(t, `userName eq "test01"`, captured.Filter)
+	assertNotNil(t, captured.FilterValidator, "filterValidator")
+	assertEqual(t, 10, captured.Count)
+	assertEqual(t, 1, captured.StartIndex)
+	assertEqual(t, "userName", captured.SortBy)
+	assertEqual(t, "ascending", captured.SortOrder)
+	assertEqualStrings(t, []string{"userName", "emails"}, captured.Attributes)
+	assertEqualStrings(t, []string{"displayName"}, captured.ExcludedAttributes)
 
-	excluded := resource["capturedExcludedAttributes"].([]interface{})
-	assertLen(t, excluded, 1)
-	assertEqual(t, "displayName", excluded[0])
+	// The requested attributes are applied to the returned resources.
+	resource := response.Resources[0].(map[string]interface{})
+	assertEqual(t, "s1", resource["id"])
+	assertNil(t, resource["capturedFilter"], "capturedFilter")
 }
 
 func TestServerResourceSearchInvalidBody(t *testing.T) {
@@ -1978,6 +2062,16 @@ func (w *statusRecordingResponseWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 }
 
+type testMeResolver struct {
+	resourceType string
+	id           string
+	err          error
+}
+
+func (r testMeResolver) ResolveMe(*http.Request) (string, string, error) {
+	return r.resourceType, r.id, r.err
+}
+
 type testRootQueryHandler struct{}
 
 func (h testRootQueryHandler) GetAll(r *http.Request, params ListRequestParams) (Page, error) {
@@ -2053,9 +2147,14 @@ func (h testRootQueryHandlerWithMeta) GetAll(r *http.Request, params ListRequest
 
 type testSearchHandler struct {
 	testResourceHandler
+	// captured records the parameters of the last search, if not nil.
+	captured *SearchParams
 }
 
 func (h testSearchHandler) Search(r *http.Request, params SearchParams) (Page, error) {
+	if h.captured != nil {
+		*h.captured = params
+	}
 	attrs := make([]interface{}, len(params.Attributes))
 	for i, a := range params.Attributes {
 		attrs[i] = a
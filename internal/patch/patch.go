@@ -184,7 +184,7 @@ func (v OperationValidator) validateEmptyPath() (interface{}, error) {
 
 	rootValue := map[string]interface{}{}
 	for p, value := range attributes {
-		path, err := filter.ParsePath([]byte(p))
+		path, err := f.ParsePath(p)
 		if err != nil {
 			return nil, fmt.Errorf("invalid attribute path: %s", p)
 		}
@@ -0,0 +1,55 @@
+package translate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+// LogicalOperands returns the operands of the given chain of logical expressions with the given operator, nested
+// logical expressions with the same operator are flattened, e.g., the three operands of `a pr or b pr or c pr`.
+func LogicalOperands(op filter.LogicalOperator, e filter.Expression) []filter.Expression {
+	l, ok := e.(*filter.LogicalExpression)
+	if !ok || l.Operator != op {
+		return []filter.Expression{e}
+	}
+	return append(LogicalOperands(op, l.Left), LogicalOperands(op, l.Right)...)
+}
+
+// Lookup returns the value of the given key, compared case-insensitively, within the given map.
+func Lookup(m map[string]string, key string) (string, bool) {
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Schemas are the schema of the resources of a translator, including the common attributes "id", "externalId" and
+// "meta", followed by its extensions.
+type Schemas []schema.Schema
+
+// NewSchemas returns the schemas of resources with the given schema and extensions.
+func NewSchemas(s schema.Schema, exts ...schema.Schema) Schemas {
+	return append(Schemas{schema.WithCommonAttributes(s)}, exts...)
+}
+
+// Resolve returns the schema and attribute to which the given attribute path applies, and whether the schema is an
+// extension.
+func (s Schemas) Resolve(attrPath filter.AttributePath) (schema.Schema, bool, schema.CoreAttribute, error) {
+	for i, ref := range s {
+		if uri := attrPath.URI(); uri != "" && ref.ID != uri {
+			continue
+		}
+		if attr, ok := ref.Attributes.ContainsAttribute(attrPath.AttributeName); ok {
+			return ref, i != 0, attr, nil
+		}
+	}
+	return schema.Schema{}, false, schema.CoreAttribute{}, fmt.Errorf("unknown attribute: %s", attrPath)
+}
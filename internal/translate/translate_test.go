@@ -0,0 +1,51 @@
+package translate
+
+import (
+	"testing"
+
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+func TestLookup(t *testing.T) {
+	m := map[string]string{"userName": "user_name", "name.familyName": "family_name"}
+	if v, ok := Lookup(m, "NAME.familyname"); !ok || v != "family_name" {
+		t.Errorf("unexpected value: %s", v)
+	}
+	if _, ok := Lookup(m, "title"); ok {
+		t.Error("expected no value")
+	}
+}
+
+func TestSchemas_Resolve(t *testing.T) {
+	schemas := NewSchemas(schema.CoreUserSchema(), schema.ExtensionEnterpriseUser())
+	for _, test := range []struct {
+		path string
+		id   string
+		ext  bool
+	}{
+		{path: "userName", id: schema.CoreUserSchema().ID},
+		{path: "externalId", id: schema.CoreUserSchema().ID},
+		{path: "employeeNumber", id: schema.ExtensionEnterpriseUser().ID, ext: true},
+		{path: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value", id: schema.ExtensionEnterpriseUser().ID, ext: true},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			attrPath, err := filter.ParseAttrPath([]byte(test.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, ext, _, err := schemas.Resolve(attrPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.ID != test.id || ext != test.ext {
+				t.Errorf("unexpected schema: %s, %t", s.ID, ext)
+			}
+		})
+	}
+
+	attrPath, _ := filter.ParseAttrPath([]byte("unknown"))
+	if _, _, _, err := schemas.Resolve(attrPath); err == nil {
+		t.Error("expected an error")
+	}
+}
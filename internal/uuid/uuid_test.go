@@ -0,0 +1,13 @@
+package uuid
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if a, b := New(), New(); !pattern.MatchString(a) || a == b {
+		t.Errorf("unexpected UUIDs: %s, %s", a, b)
+	}
+}
@@ -13,6 +13,10 @@ type Page struct {
 	TotalResults int
 	// Resources is a multi-valued list of complex objects containing the requested resources.
 	Resources []Resource
+	// NextCursor is the cursor of the next page when using cursor-based pagination. It is empty on the last page.
+	NextCursor string
+	// PreviousCursor is the cursor of the previous page when using cursor-based pagination, if supported.
+	PreviousCursor string
 }
 
 // rawResources returns resources as raw interface values for root queries (GET /).
@@ -34,7 +38,9 @@ type Page struct {
 //
 // These meta fields are merged into any existing "meta" map in Attributes. If the caller already provides a "meta"
 // map (e.g. with "resourceType"), the injected fields are added alongside it without overwriting existing keys.
-func (p Page) rawResources() []interface{} {
+//
+// The given projection is applied to the resources of which "meta.resourceType" names one of the given resource types.
+func (p Page) rawResources(resourceTypes []ResourceType, proj projection) []interface{} {
 	if len(p.Resources) == 0 {
 		if p.Resources != nil {
 			return []interface{}{}
@@ -87,12 +93,21 @@ func (p Page) rawResources() []interface{} {
 			attrs[schema.CommonAttributeMeta] = metaMap
 		}
 
+		if name, ok := metaMap["resourceType"].(string); ok {
+			for _, resourceType := range resourceTypes {
+				if resourceType.Name == name {
+					attrs = proj.apply(resourceType, attrs)
+					break
+				}
+			}
+		}
+
 		resources = append(resources, attrs)
 	}
 	return resources
 }
 
-func (p Page) resources(resourceType ResourceType, baseURL string) []interface{} {
+func (p Page) resources(resourceType ResourceType, baseURL string, proj projection) []interface{} {
 	// If the page.Resources is nil, then it will also be represented as a `null` in the response.
 	// Otherwise is it is an empty slice then it will result in an empty array `[]`.
 	if len(p.Resources) == 0 {
@@ -107,7 +122,7 @@ func (p Page) resources(resourceType ResourceType, baseURL string) []interface{}
 		location := resourceLocation(resourceType, v.ID, baseURL)
 		resources = append(
 			resources,
-			v.response(resourceType, location),
+			proj.apply(resourceType, v.response(resourceType, location)),
 		)
 	}
 	return resources
@@ -133,14 +148,30 @@ type listResponse struct {
 	// This may be a subset of the full set of resources if pagination is requested.
 	// REQUIRED if TotalResults is non-zero.
 	Resources []interface{}
+
+	// NextCursor is the cursor of the next page when using cursor-based pagination (RFC 9865).
+	NextCursor string
+
+	// PreviousCursor is the cursor of the previous page when using cursor-based pagination (RFC 9865).
+	PreviousCursor string
 }
 
 func (l listResponse) MarshalJSON() ([]byte, error) {
-	return json.Marshal(map[string]interface{}{
+	raw := map[string]interface{}{
 		"schemas":      []string{"urn:ietf:params:scim:api:messages:2.0:ListResponse"},
 		"totalResults": l.TotalResults,
 		"itemsPerPage": l.ItemsPerPage,
-		"startIndex":   l.StartIndex,
 		"Resources":    l.Resources,
-	})
+	}
+	// The start index is not used with cursor-based pagination.
+	if l.StartIndex != 0 {
+		raw["startIndex"] = l.StartIndex
+	}
+	if l.NextCursor != "" {
+		raw["nextCursor"] = l.NextCursor
+	}
+	if l.PreviousCursor != "" {
+		raw["previousCursor"] = l.PreviousCursor
+	}
+	return json.Marshal(raw)
 }
@@ -0,0 +1,445 @@
+package memory
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/internal/uuid"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+)
+
+// maxIDAttempts is the number of identifiers that are generated for a new resource before giving up, if all of them are
+// already in use.
+const maxIDAttempts = 16
+
+// copyValue returns a deep copy of the given (complex or multi-valued) value.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return copyValues(v)
+	case scim.ResourceAttributes:
+		return copyValues(v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, value := range v {
+			values[i] = copyValue(value)
+		}
+		return values
+	case []map[string]interface{}:
+		values := make([]interface{}, len(v))
+		for i, value := range v {
+			values[i] = copyValues(value)
+		}
+		return values
+	default:
+		return value
+	}
+}
+
+// copyValues returns a deep copy of the given attributes.
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		c[k] = copyValue(v)
+	}
+	return c
+}
+
+// equalUnique checks whether the given values of an attribute with the given characteristics are equal.
+func equalUnique(attr schema.CoreAttribute, a, b interface{}) bool {
+	x, ok := a.(string)
+	if !ok {
+		return a == b
+	}
+	y, ok := b.(string)
+	if !ok {
+		return false
+	}
+	if attr.CaseExact() {
+		return x == y
+	}
+	return strings.EqualFold(x, y)
+}
+
+// lookup returns the value of the attribute with the given name, attribute names are case-insensitive.
+func lookup(values map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := values[name]; ok {
+		return v, true
+	}
+	for k, v := range values {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Handler is a concurrency-safe in-memory store of the resources of a single resource type. It implements
+// scim.ResourceHandler and scim.ResourceSearcher, PATCH requests are applied by the server through Get and Replace.
+//
+// Resources get a random UUID as identifier, their metadata is maintained by the handler and their version is
+// incremented on every modification. Filters, sorting and pagination of list and search requests are evaluated in
+// memory, uniqueness of attributes with a "server" or "global" uniqueness is enforced and modifications that carry an
+// expected version (see scim.ExpectedVersion) fail with 412 Precondition Failed if the resource was modified.
+type Handler struct {
+	resourceType scim.ResourceType
+	newID        func() string
+	now          func() time.Time
+
+	mu        sync.RWMutex
+	resources map[string]*resource
+	// ids contains the identifiers of the resources in the order in which they were created.
+	ids []string
+}
+
+// NewHandler returns an empty in-memory handler for resources of the given resource type.
+func NewHandler(resourceType scim.ResourceType, opts ...Option) *Handler {
+	h := &Handler{
+		resourceType: resourceType,
+		newID:        uuid.New,
+		now:          time.Now,
+		resources:    make(map[string]*resource),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Create stores the given attributes as a new resource.
+func (h *Handler) Create(r *http.Request, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stored := newResource(attributes, h.now())
+	if err := h.checkUniqueness("", stored.attributes); err != nil {
+		return scim.Resource{}, err
+	}
+
+	id, err := h.generateID()
+	if err != nil {
+		return scim.Resource{}, err
+	}
+	h.resources[id] = stored
+	h.ids = append(h.ids, id)
+	return stored.toResource(id), nil
+}
+
+// Delete removes the resource with the given identifier.
+func (h *Handler) Delete(r *http.Request, id string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stored, err := h.get(id)
+	if err != nil {
+		return err
+	}
+	if version, ok := scim.ExpectedVersion(r); ok && version != stored.version() {
+		return errors.ScimErrorPreconditionFailed
+	}
+
+	delete(h.resources, id)
+	for i, v := range h.ids {
+		if v == id {
+			h.ids = append(h.ids[:i], h.ids[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Get returns the resource with the given identifier.
+func (h *Handler) Get(r *http.Request, id string) (scim.Resource, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stored, err := h.get(id)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+	return stored.toResource(id), nil
+}
+
+// GetAll returns the page of resources that pass the filter of the given parameters, sorted and paginated accordingly.
+func (h *Handler) GetAll(r *http.Request, params scim.ListRequestParams) (scim.Page, error) {
+	return h.query(params.FilterValidator, params.SortBy, params.SortOrder, params.StartIndex, params.Count)
+}
+
+// Len returns the number of stored resources.
+func (h *Handler) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.ids)
+}
+
+// Replace replaces all attributes of the resource with the given identifier.
+func (h *Handler) Replace(r *http.Request, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stored, err := h.get(id)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+	if version, ok := scim.ExpectedVersion(r); ok && version != stored.version() {
+		return scim.Resource{}, errors.ScimErrorPreconditionFailed
+	}
+
+	replaced := newResource(attributes, h.now())
+	if err := h.checkUniqueness(id, replaced.attributes); err != nil {
+		return scim.Resource{}, err
+	}
+	replaced.created = stored.created
+	replaced.revision = stored.revision + 1
+	h.resources[id] = replaced
+	return replaced.toResource(id), nil
+}
+
+// Search returns the page of resources that match the given search request.
+func (h *Handler) Search(r *http.Request, params scim.SearchParams) (scim.Page, error) {
+	return h.query(params.FilterValidator, params.SortBy, params.SortOrder, params.StartIndex, params.Count)
+}
+
+// checkUniqueness returns a 409 Conflict SCIM error if one of the unique attributes of the given attributes has the same
+// value as another resource than the one with the given identifier.
+func (h *Handler) checkUniqueness(id string, attributes map[string]interface{}) error {
+	check := func(s schema.Schema, values map[string]interface{}, valuesOf func(*resource) map[string]interface{}) error {
+		for _, attr := range s.Attributes {
+			if attr.MultiValued() || attr.HasSubAttributes() {
+				continue
+			}
+			if u := attr.Uniqueness(); u != "server" && u != "global" {
+				continue
+			}
+			value, ok := lookup(values, attr.Name())
+			if !ok || value == nil {
+				continue
+			}
+			for otherID, other := range h.resources {
+				if otherID == id {
+					continue
+				}
+				if otherValue, ok := lookup(valuesOf(other), attr.Name()); ok && equalUnique(attr, value, otherValue) {
+					return errors.ScimErrorUniqueness
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := check(h.resourceType.Schema, attributes, func(r *resource) map[string]interface{} {
+		return r.attributes
+	}); err != nil {
+		return err
+	}
+	for _, extension := range h.resourceType.SchemaExtensions {
+		uri := extension.Schema.ID
+		values, _ := lookup(attributes, uri)
+		m, ok := values.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if err := check(extension.Schema, m, func(r *resource) map[string]interface{} {
+			values, _ := lookup(r.attributes, uri)
+			m, _ := values.(map[string]interface{})
+			return m
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterValues returns the values of the given resource as expected by filter.Predicate. Attributes of schema
+// extensions are prefixed by the identifier of their schema and the common attributes are included.
+func (h *Handler) filterValues(id string, stored *resource) map[string]interface{} {
+	values := make(map[string]interface{}, len(stored.attributes)+4)
+	schemas := []interface{}{h.resourceType.Schema.ID}
+	for k, v := range stored.attributes {
+		values[k] = v
+	}
+	for _, extension := range h.resourceType.SchemaExtensions {
+		uri := extension.Schema.ID
+		for k, v := range values {
+			if !strings.EqualFold(k, uri) {
+				continue
+			}
+			delete(values, k)
+			if m, ok := v.(map[string]interface{}); ok {
+				for name, value := range m {
+					values[uri+":"+name] = value
+				}
+				schemas = append(schemas, uri)
+			}
+		}
+	}
+
+	values[schema.CommonAttributeID] = id
+	if stored.externalID.Present() {
+		values[schema.CommonAttributeExternalID] = stored.externalID.Value()
+	}
+	values[schema.CommonAttributeMeta] = map[string]interface{}{
+		"resourceType": h.resourceType.Name,
+		"created":      stored.created.Format(time.RFC3339),
+		"lastModified": stored.lastModified.Format(time.RFC3339),
+		"version":      stored.version(),
+	}
+	values["schemas"] = schemas
+	return values
+}
+
+// generateID returns a new identifier that is not in use yet.
+func (h *Handler) generateID() (string, error) {
+	for i := 0; i < maxIDAttempts; i++ {
+		id := h.newID()
+		if _, ok := h.resources[id]; !ok {
+			return id, nil
+		}
+	}
+	return "", errors.ScimError{
+		Detail: "Could not generate a unique identifier for the resource.",
+		Status: http.StatusInternalServerError,
+	}
+}
+
+// get returns the stored resource with the given identifier. The caller must hold the lock.
+func (h *Handler) get(id string) (*resource, error) {
+	stored, ok := h.resources[id]
+	if !ok {
+		return nil, errors.ScimErrorResourceNotFound(id)
+	}
+	return stored, nil
+}
+
+// query returns the page of resources that pass the given filter, sorted and paginated by the given parameters.
+func (h *Handler) query(validator *filter.Validator, sortBy, sortOrder string, startIndex, count int) (scim.Page, error) {
+	var predicate filter.Predicate
+	if validator != nil {
+		p, err := validator.Compile()
+		if err != nil {
+			return scim.Page{}, errors.ScimErrorInvalidFilter
+		}
+		predicate = p
+	}
+
+	h.mu.RLock()
+	resources := make([]scim.Resource, 0, len(h.ids))
+	for _, id := range h.ids {
+		stored := h.resources[id]
+		if predicate != nil {
+			// Values that do not match the type of their attribute do not pass the filter.
+			if ok, err := predicate(h.filterValues(id, stored)); !ok || err != nil {
+				continue
+			}
+		}
+		resources = append(resources, stored.toResource(id))
+	}
+	h.mu.RUnlock()
+
+	if sortBy != "" {
+		if err := h.resourceType.SortResources(resources, sortBy, sortOrder); err != nil {
+			return scim.Page{}, errors.ScimErrorBadParams([]string{"sortBy"})
+		}
+	}
+
+	page := scim.Page{
+		TotalResults: len(resources),
+		Resources:    []scim.Resource{},
+	}
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if start := startIndex - 1; start < len(resources) && count > 0 {
+		end := start + count
+		if end > len(resources) {
+			end = len(resources)
+		}
+		page.Resources = resources[start:end]
+	}
+	return page, nil
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithClock sets the function that returns the current time, which is used for the metadata of the resources.
+func WithClock(now func() time.Time) Option {
+	return func(h *Handler) {
+		h.now = now
+	}
+}
+
+// WithIDGenerator sets the function that generates the identifiers of new resources. It defaults to random UUIDs.
+// Generated identifiers that are already in use are discarded, the creation of a resource fails if no unused identifier
+// is generated after a number of attempts.
+func WithIDGenerator(newID func() string) Option {
+	return func(h *Handler) {
+		h.newID = newID
+	}
+}
+
+// resource represents a stored resource.
+type resource struct {
+	attributes   map[string]interface{}
+	externalID   optional.String
+	created      time.Time
+	lastModified time.Time
+	revision     int
+}
+
+// newResource returns a new resource with a copy of the given attributes, the external identifier is stored
+// separately.
+func newResource(attributes scim.ResourceAttributes, now time.Time) *resource {
+	values := copyValues(attributes)
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+
+	var externalID optional.String
+	for k, v := range values {
+		if strings.EqualFold(k, schema.CommonAttributeExternalID) {
+			if s, ok := v.(string); ok {
+				externalID = optional.NewString(s)
+			}
+			delete(values, k)
+		}
+	}
+	return &resource{
+		attributes:   values,
+		externalID:   externalID,
+		created:      now,
+		lastModified: now,
+		revision:     1,
+	}
+}
+
+// toResource returns a copy of the stored resource with the given identifier.
+func (r *resource) toResource(id string) scim.Resource {
+	created, lastModified := r.created, r.lastModified
+	return scim.Resource{
+		ID:         id,
+		ExternalID: r.externalID,
+		Attributes: copyValues(r.attributes),
+		Meta: scim.Meta{
+			Created:      &created,
+			LastModified: &lastModified,
+			Version:      r.version(),
+		},
+	}
+}
+
+// version returns the version of the resource, which is a weak entity tag.
+func (r *resource) version() string {
+	return fmt.Sprintf(`W/"%d"`, r.revision)
+}
@@ -0,0 +1,265 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/schema"
+)
+
+func TestHandler(t *testing.T) {
+	s, h := newTestServer(t)
+
+	for i, userName := range []string{"charlie", "alice", "bob"} {
+		rr := serve(s, http.MethodPost, "/Users", fmt.Sprintf(`{
+			"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"],
+			"userName": %q,
+			"externalId": "ext-%d",
+			"emails": [{"value": "%s@example.com", "type": "work"}],
+			"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"employeeNumber": "%d"}
+		}`, userName, i, userName, i))
+		if rr.Code != http.StatusCreated {
+			t.Fatal(rr.Code, rr.Body.String())
+		}
+	}
+	if h.Len() != 3 {
+		t.Fatalf("expected 3 resources, got %d", h.Len())
+	}
+
+	t.Run("uniqueness", func(t *testing.T) {
+		rr := serve(s, http.MethodPost, "/Users", `{
+			"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+			"userName": "ALICE"
+		}`)
+		if rr.Code != http.StatusConflict {
+			t.Error(rr.Code, rr.Body.String())
+		}
+	})
+
+	for _, test := range []struct {
+		target   string
+		expected []string
+		total    int
+	}{
+		{target: "/Users", expected: []string{"charlie", "alice", "bob"}, total: 3},
+		{target: "/Users?sortBy=userName", expected: []string{"alice", "bob", "charlie"}, total: 3},
+		{target: "/Users?sortBy=userName&sortOrder=descending&startIndex=2&count=1", expected: []string{"bob"}, total: 3},
+		{target: "/Users?filter=" + url.QueryEscape(`userName sw "b" or userName eq "Alice"`), expected: []string{"alice", "bob"}, total: 2},
+		{target: "/Users?filter=" + url.QueryEscape(`emails.value eq "bob@example.com"`), expected: []string{"bob"}, total: 1},
+		{target: "/Users?filter=" + url.QueryEscape(`emails[type eq "work" and value co "char"]`), expected: []string{"charlie"}, total: 1},
+		{target: "/Users?filter=" + url.QueryEscape(`externalId eq "ext-1"`), expected: []string{"alice"}, total: 1},
+		{target: "/Users?filter=" + url.QueryEscape(`employeeNumber eq "2"`), expected: []string{"bob"}, total: 1},
+		{target: "/Users?filter=" + url.QueryEscape(`meta.created gt "2000-01-01T00:00:00Z"`), expected: []string{"charlie", "alice", "bob"}, total: 3},
+		{target: "/Users?count=0", total: 3},
+	} {
+		t.Run(test.target, func(t *testing.T) {
+			rr := serve(s, http.MethodGet, test.target, "")
+			if rr.Code != http.StatusOK {
+				t.Fatal(rr.Code, rr.Body.String())
+			}
+			total, userNames := listUserNames(t, rr)
+			if total != test.total {
+				t.Errorf("expected %d total results, got %d", test.total, total)
+			}
+			if strings.Join(userNames, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("expected %v, got %v", test.expected, userNames)
+			}
+		})
+	}
+
+	t.Run("search", func(t *testing.T) {
+		rr := serve(s, http.MethodPost, "/Users/.search", `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"],
+			"filter": "userName ne \"alice\"",
+			"sortBy": "userName",
+			"sortOrder": "descending"
+		}`)
+		if rr.Code != http.StatusOK {
+			t.Fatal(rr.Code, rr.Body.String())
+		}
+		if _, userNames := listUserNames(t, rr); strings.Join(userNames, ",") != "charlie,bob" {
+			t.Error(userNames)
+		}
+	})
+
+	t.Run("search common and multi-valued attributes", func(t *testing.T) {
+		rr := serve(s, http.MethodPost, "/Users/.search", `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"],
+			"filter": "meta.created pr and emails.value ew \"e@example.com\"",
+			"sortBy": "userName"
+		}`)
+		if rr.Code != http.StatusOK {
+			t.Fatal(rr.Code, rr.Body.String())
+		}
+		if _, userNames := listUserNames(t, rr); strings.Join(userNames, ",") != "alice,charlie" {
+			t.Error(userNames)
+		}
+	})
+}
+
+func TestHandlerModifications(t *testing.T) {
+	s, h := newTestServer(t, WithIDGenerator(func() string { return "0001" }))
+
+	rr := serve(s, http.MethodPost, "/Users", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "alice"
+	}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatal(rr.Code, rr.Body.String())
+	}
+	if etag := rr.Header().Get("Etag"); etag != `W/"1"` {
+		t.Errorf("unexpected version: %s", etag)
+	}
+
+	rr = serve(s, http.MethodPatch, "/Users/0001", `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "add", "path": "displayName", "value": "Alice"}]
+	}`)
+	if rr.Code != http.StatusOK {
+		t.Fatal(rr.Code, rr.Body.String())
+	}
+	if etag := rr.Header().Get("Etag"); etag != `W/"2"` {
+		t.Errorf("unexpected version: %s", etag)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/Users/0001", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "alice"
+	}`))
+	req.Header.Set("If-Match", `W/"1"`)
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Error(rr.Code, rr.Body.String())
+	}
+
+	resource, err := h.Get(nil, "0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.Attributes["displayName"] != "Alice" {
+		t.Error(resource.Attributes)
+	}
+	if resource.Meta.LastModified.Before(*resource.Meta.Created) {
+		t.Error(resource.Meta)
+	}
+
+	// Returned attributes are copies.
+	resource.Attributes["displayName"] = "Bob"
+	if resource, _ := h.Get(nil, "0001"); resource.Attributes["displayName"] != "Alice" {
+		t.Error(resource.Attributes)
+	}
+
+	if rr := serve(s, http.MethodDelete, "/Users/0001", ""); rr.Code != http.StatusNoContent {
+		t.Error(rr.Code, rr.Body.String())
+	}
+	if rr := serve(s, http.MethodGet, "/Users/0001", ""); rr.Code != http.StatusNotFound {
+		t.Error(rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandlerIDExhausted(t *testing.T) {
+	s, h := newTestServer(t, WithIDGenerator(func() string { return "0001" }))
+
+	for i, expected := range []int{http.StatusCreated, http.StatusInternalServerError} {
+		rr := serve(s, http.MethodPost, "/Users", fmt.Sprintf(`{
+			"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+			"userName": "user%d"
+		}`, i))
+		if rr.Code != expected {
+			t.Errorf("expected %d, got %d: %s", expected, rr.Code, rr.Body.String())
+		}
+	}
+	if h.Len() != 1 {
+		t.Errorf("expected 1 resource, got %d", h.Len())
+	}
+}
+
+func TestHandlerConcurrency(t *testing.T) {
+	h := NewHandler(testResourceType())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodPut, "/Users", nil)
+			resource, err := h.Create(r, scim.ResourceAttributes{"userName": fmt.Sprintf("user%d", i)})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if _, err := h.Replace(r, resource.ID, scim.ResourceAttributes{"userName": fmt.Sprintf("user%d", i)}); err != nil {
+				t.Error(err)
+			}
+			if _, err := h.GetAll(r, scim.ListRequestParams{Count: 10, StartIndex: 1}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if h.Len() != 50 {
+		t.Errorf("expected 50 resources, got %d", h.Len())
+	}
+}
+
+func listUserNames(t *testing.T, rr *httptest.ResponseRecorder) (int, []string) {
+	var response struct {
+		TotalResults int
+		Resources    []struct {
+			UserName string
+		}
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	var userNames []string
+	for _, resource := range response.Resources {
+		userNames = append(userNames, resource.UserName)
+	}
+	return response.TotalResults, userNames
+}
+
+func newTestServer(t *testing.T, opts ...Option) (scim.Server, *Handler) {
+	resourceType := testResourceType()
+	h := NewHandler(resourceType, append([]Option{WithClock(time.Now)}, opts...)...)
+	resourceType.Handler = h
+
+	s, err := scim.NewServer(
+		&scim.ServerArgs{
+			ServiceProviderConfig: &scim.ServiceProviderConfig{SupportETag: true},
+			ResourceTypes:         []scim.ResourceType{resourceType},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, h
+}
+
+func serve(s scim.Server, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	return rr
+}
+
+func testResourceType() scim.ResourceType {
+	return scim.ResourceType{
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema:   schema.CoreUserSchema(),
+		SchemaExtensions: []scim.SchemaExtension{
+			{Schema: schema.ExtensionEnterpriseUser()},
+		},
+	}
+}
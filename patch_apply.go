@@ -0,0 +1,586 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/elimity-com/scim/errors"
+	f "github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+	"github.com/scim2/filter-parser/v2"
+)
+
+// applyPatchAttribute applies the operation to the attribute with the given key, without a value filter or a
+// sub-attribute in the path.
+func applyPatchAttribute(container map[string]interface{}, key string, attr schema.CoreAttribute, op string, value interface{}) {
+	switch {
+	case op == PatchOperationRemove && (value == nil || !attr.MultiValued()):
+		delete(container, key)
+	case op == PatchOperationRemove:
+		// Remove the values that match any of the given values, e.g., the members of a group.
+		var values []interface{}
+		for _, element := range toSlice(container[key]) {
+			if !containsAnyValue(element, toSlice(value)) {
+				values = append(values, element)
+			}
+		}
+		setPatchValues(container, key, values)
+	case attr.MultiValued():
+		var (
+			values  []interface{}
+			changed []int
+		)
+		if op == PatchOperationAdd {
+			values = toSlice(container[key])
+		}
+		for _, v := range toSlice(value) {
+			if op == PatchOperationAdd && containsValue(values, v) {
+				continue
+			}
+			changed = append(changed, len(values))
+			values = append(values, copyValue(v))
+		}
+		resetPrimary(values, changed)
+		setPatchValues(container, key, values)
+	case attr.AttributeType() == "complex":
+		// Sub-attributes that are not specified in the value are left unchanged, for both add and replace operations.
+		complexValue, ok := asMap(container[key])
+		if !ok {
+			complexValue = map[string]interface{}{}
+		}
+		if values, ok := asMap(value); ok {
+			mergeValues(complexValue, values)
+		}
+		container[key] = complexValue
+	default:
+		container[key] = copyValue(value)
+	}
+}
+
+// applyPatchFilter applies the operation to the values of the multi-valued attribute with the given key that match the
+// given value filter, e.g., `emails[type eq "work"]`. If the sub-attribute name is not empty, the operation only
+// applies to that sub-attribute of the matching values.
+func applyPatchFilter(container map[string]interface{}, key string, attr schema.CoreAttribute, op string, expression filter.Expression, subAttrName string, value interface{}) error {
+	var (
+		values  = toSlice(container[key])
+		matched []int
+	)
+	// The value filter is compiled once for all values. A filter that can not be compiled matches no values.
+	validator := f.NewFilterValidator(expression, schema.Schema{
+		Attributes: f.MultiValuedFilterAttributes(attr),
+	})
+	if predicate, err := validator.Compile(); err == nil {
+		for i, element := range values {
+			if matchesValueFilter(predicate, element) {
+				matched = append(matched, i)
+			}
+		}
+	}
+
+	if op == PatchOperationRemove {
+		var remaining []interface{}
+		for i, element := range values {
+			if !containsIndex(matched, i) {
+				remaining = append(remaining, element)
+				continue
+			}
+			if subAttrName != "" {
+				if m, ok := asMap(element); ok {
+					if k, ok := findKey(m, subAttrName); ok {
+						delete(m, k)
+					}
+				}
+				remaining = append(remaining, element)
+			}
+		}
+		setPatchValues(container, key, remaining)
+		return nil
+	}
+
+	if len(matched) == 0 {
+		// An add operation with an equality filter creates the value it selects, e.g. `emails[type eq "work"].value`.
+		e, ok := expression.(*filter.AttributeExpression)
+		if op != PatchOperationAdd || !ok || e.Operator != filter.EQ || attr.AttributeType() != "complex" {
+			return errors.ScimErrorNoTarget
+		}
+		name := e.AttributePath.AttributeName
+		if subAttr, ok := attr.SubAttributes().ContainsAttribute(name); ok {
+			name = subAttr.Name()
+		}
+		matched = append(matched, len(values))
+		values = append(values, map[string]interface{}{name: e.CompareValue})
+	}
+
+	for _, i := range matched {
+		element, ok := asMap(values[i])
+		switch {
+		case subAttrName != "":
+			if !ok {
+				return errors.ScimErrorNoTarget
+			}
+			name := subAttrName
+			if subAttr, ok := attr.SubAttributes().ContainsAttribute(subAttrName); ok {
+				name = subAttr.Name()
+			}
+			if k, ok := findKey(element, name); ok {
+				name = k
+			}
+			element[name] = copyValue(value)
+			values[i] = element
+		case op == PatchOperationAdd && ok:
+			if m, ok := asMap(value); ok {
+				mergeValues(element, m)
+			}
+			values[i] = element
+		default:
+			values[i] = copyValue(value)
+		}
+	}
+	resetPrimary(values, matched)
+	setPatchValues(container, key, values)
+	return nil
+}
+
+// applyPatchSubAttribute applies the operation to the sub-attribute with the given name of the attribute with the given
+// key, e.g., "name.givenName". If the attribute is multi-valued, the operation applies to all its values.
+func applyPatchSubAttribute(container map[string]interface{}, key string, attr schema.CoreAttribute, op string, subAttrName string, value interface{}) error {
+	if subAttr, ok := attr.SubAttributes().ContainsAttribute(subAttrName); ok {
+		subAttrName = subAttr.Name()
+	}
+	update := func(element map[string]interface{}) {
+		k, ok := findKey(element, subAttrName)
+		if !ok {
+			k = subAttrName
+		}
+		if op == PatchOperationRemove {
+			delete(element, k)
+			return
+		}
+		element[k] = copyValue(value)
+	}
+
+	if !attr.MultiValued() {
+		element, ok := asMap(container[key])
+		if !ok {
+			if op == PatchOperationRemove {
+				return nil
+			}
+			element = map[string]interface{}{}
+		}
+		update(element)
+		if len(element) == 0 {
+			delete(container, key)
+			return nil
+		}
+		container[key] = element
+		return nil
+	}
+
+	values := toSlice(container[key])
+	if len(values) == 0 && op != PatchOperationRemove {
+		return errors.ScimErrorNoTarget
+	}
+	for i, v := range values {
+		if element, ok := asMap(v); ok {
+			update(element)
+			values[i] = element
+		}
+	}
+	setPatchValues(container, key, values)
+	return nil
+}
+
+// asMap returns the given value as a map, if it is a complex value.
+func asMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, v != nil
+	case ResourceAttributes:
+		return v, v != nil
+	default:
+		return nil, false
+	}
+}
+
+// containsAnyValue checks whether the given value matches one of the given values. A complex value matches if it
+// contains all the sub-attributes of the other value, e.g., `{"value": "0001"}` matches a member with that value.
+func containsAnyValue(value interface{}, values []interface{}) bool {
+	for _, v := range values {
+		m, ok := asMap(v)
+		if !ok {
+			if equalValues(value, v) {
+				return true
+			}
+			continue
+		}
+		element, ok := asMap(value)
+		if !ok {
+			continue
+		}
+		matches := true
+		for name, subValue := range m {
+			k, ok := findKey(element, name)
+			if !ok || !equalValues(element[k], subValue) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// containsIndex checks whether the given indices contain the given index.
+func containsIndex(indices []int, index int) bool {
+	for _, i := range indices {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// containsValue checks whether the given values contain a value that is equal to the given value.
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if equalValues(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyValue returns a deep copy of the given (complex or multi-valued) value. Resource attributes are copied to plain
+// maps.
+func copyValue(value interface{}) interface{} {
+	if m, ok := asMap(value); ok {
+		c := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			c[k] = copyValue(v)
+		}
+		return c
+	}
+	switch v := value.(type) {
+	case []interface{}, []map[string]interface{}:
+		values := toSlice(v)
+		c := make([]interface{}, len(values))
+		for i, v := range values {
+			c[i] = copyValue(v)
+		}
+		return c
+	default:
+		return value
+	}
+}
+
+// equalValues checks whether the given values are equal. Attribute names are compared case-insensitively and numbers
+// are compared regardless of their type.
+func equalValues(a, b interface{}) bool {
+	if x, ok := asMap(a); ok {
+		y, ok := asMap(b)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for name, v := range x {
+			k, ok := findKey(y, name)
+			if !ok || !equalValues(v, y[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	switch a.(type) {
+	case []interface{}, []map[string]interface{}:
+		switch b.(type) {
+		case []interface{}, []map[string]interface{}:
+		default:
+			return false
+		}
+		x, y := toSlice(a), toSlice(b)
+		if len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equalValues(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// findKey returns the key of the given values that matches the given attribute name case-insensitively.
+func findKey(values map[string]interface{}, name string) (string, bool) {
+	if _, ok := values[name]; ok {
+		return name, true
+	}
+	for k := range values {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
+	}
+	return name, false
+}
+
+// matchesValueFilter checks whether the given value of a multi-valued attribute matches the given compiled value filter.
+// Values of multi-valued attributes that are not complex are matched as their "value" sub-attribute. Values that do not
+// match the types of their attributes, e.g., invalid stored data, do not match the filter.
+func matchesValueFilter(predicate f.Predicate, value interface{}) bool {
+	element, ok := asMap(value)
+	if !ok {
+		element = map[string]interface{}{"value": value}
+	}
+	matches, err := predicate(element)
+	return err == nil && matches
+}
+
+// mergeValues sets the given sub-attribute values on the given complex value, keeping the existing keys.
+func mergeValues(target, values map[string]interface{}) {
+	for name, v := range values {
+		k, _ := findKey(target, name)
+		target[k] = copyValue(v)
+	}
+}
+
+// resetPrimary ensures that at most one of the given values is primary. If one of the values at the given indices is
+// primary, the "primary" sub-attribute of all other values is set to false.
+func resetPrimary(values []interface{}, indices []int) {
+	primary := -1
+	for _, i := range indices {
+		if p, ok := lookupAttribute(values[i], "primary").(bool); ok && p {
+			primary = i
+		}
+	}
+	if primary == -1 {
+		return
+	}
+	for i, v := range values {
+		if i == primary {
+			continue
+		}
+		if element, ok := asMap(v); ok {
+			if k, ok := findKey(element, "primary"); ok && element[k] == true {
+				element[k] = false
+			}
+		}
+	}
+}
+
+// setPatchValues sets the values of the multi-valued attribute with the given key. The attribute is removed if no
+// values remain.
+func setPatchValues(container map[string]interface{}, key string, values []interface{}) {
+	if len(values) == 0 {
+		delete(container, key)
+		return
+	}
+	container[key] = values
+}
+
+// toSlice returns the values of the given multi-valued attribute. A singular value is returned as a single value.
+func toSlice(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	case []map[string]interface{}:
+		values := make([]interface{}, len(v))
+		for i, m := range v {
+			values[i] = m
+		}
+		return values
+	default:
+		return []interface{}{value}
+	}
+}
+
+// ApplyPatch applies the given PATCH operations, as passed to ResourcePatcher.Patch, to a copy of the given attributes
+// as described in RFC 7644 Section 3.5.2. It supports attribute paths with sub-attributes, value filters, e.g.,
+// `emails[type eq "work"].value`, and schema extension URIs. Values added to multi-valued attributes are merged with
+// the existing values and if one of them is primary, the "primary" sub-attribute of the other values is reset.
+//
+// The patched attributes are returned together with whether they differ from the given attributes. If nothing changed,
+// a handler can return an empty resource to respond with 204 No Content.
+func (t ResourceType) ApplyPatch(attributes ResourceAttributes, operations []PatchOperation) (ResourceAttributes, bool, error) {
+	patched, _ := copyValue(map[string]interface{}(attributes)).(map[string]interface{})
+	if patched == nil {
+		patched = map[string]interface{}{}
+	}
+
+	for _, operation := range operations {
+		if err := t.applyPatchOperation(patched, operation); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Extensions without attributes are removed.
+	for _, extension := range t.SchemaExtensions {
+		if k, ok := findKey(patched, extension.Schema.ID); ok {
+			if m, ok := asMap(patched[k]); ok && len(m) == 0 {
+				delete(patched, k)
+			}
+		}
+	}
+	return patched, !equalValues(map[string]interface{}(attributes), patched), nil
+}
+
+// applyPatchOperation applies a single PATCH operation to the given attributes.
+func (t ResourceType) applyPatchOperation(attributes map[string]interface{}, operation PatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	switch op {
+	case PatchOperationAdd, PatchOperationReplace:
+	case PatchOperationRemove:
+		if operation.Path == nil {
+			return errors.ScimErrorNoTarget
+		}
+	default:
+		return errors.ScimErrorInvalidValue
+	}
+
+	if operation.Path != nil {
+		return t.applyPatchPath(attributes, op, *operation.Path, operation.Value)
+	}
+
+	// If the path is omitted, the value contains the attributes to add or replace, keyed by their paths.
+	values, ok := asMap(operation.Value)
+	if !ok {
+		return errors.ScimErrorInvalidValue
+	}
+	paths := make([]string, 0, len(values))
+	for p := range values {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		path, err := f.ParsePath(p)
+		if err != nil {
+			return errors.ScimErrorInvalidPath
+		}
+		if err := t.applyPatchPath(attributes, op, path, values[p]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyPatchPath applies the operation to the attribute at the given path.
+func (t ResourceType) applyPatchPath(attributes map[string]interface{}, op string, path filter.Path, value interface{}) error {
+	container, attr, err := t.patchContainer(attributes, path.AttributePath, op != PatchOperationRemove)
+	if err != nil {
+		return err
+	}
+	if container == nil {
+		// Nothing to remove from an absent extension.
+		return nil
+	}
+
+	key, _ := findKey(container, attr.Name())
+	if path.ValueExpression != nil {
+		if !attr.MultiValued() {
+			return errors.ScimErrorInvalidPath
+		}
+		return applyPatchFilter(container, key, attr, op, path.ValueExpression, path.SubAttributeName(), value)
+	}
+	if subAttrName := path.AttributePath.SubAttributeName(); subAttrName != "" {
+		return applyPatchSubAttribute(container, key, attr, op, subAttrName, value)
+	}
+	applyPatchAttribute(container, key, attr, op, value)
+	return nil
+}
+
+// patchContainer returns the attributes that contain the attribute with the given path, which are either the given
+// attributes or the attributes of a schema extension, together with the referenced attribute. If create is true, the
+// attributes of an absent schema extension are created.
+func (t ResourceType) patchContainer(attributes map[string]interface{}, attrPath filter.AttributePath, create bool) (map[string]interface{}, schema.CoreAttribute, error) {
+	uri := attrPath.URI()
+	if uri == "" || strings.EqualFold(uri, t.Schema.ID) {
+		attr, ok := t.schemaWithCommon().Attributes.ContainsAttribute(attrPath.AttributeName)
+		if !ok {
+			return nil, schema.CoreAttribute{}, errors.ScimErrorInvalidPath
+		}
+		return attributes, attr, nil
+	}
+
+	for _, extension := range t.SchemaExtensions {
+		if !strings.EqualFold(uri, extension.Schema.ID) {
+			continue
+		}
+		attr, ok := extension.Schema.Attributes.ContainsAttribute(attrPath.AttributeName)
+		if !ok {
+			return nil, schema.CoreAttribute{}, errors.ScimErrorInvalidPath
+		}
+		key, _ := findKey(attributes, extension.Schema.ID)
+		container, ok := asMap(attributes[key])
+		if !ok {
+			if !create {
+				return nil, attr, nil
+			}
+			container = map[string]interface{}{}
+			attributes[key] = container
+		}
+		return container, attr, nil
+	}
+	return nil, schema.CoreAttribute{}, errors.ScimErrorInvalidPath
+}
+
+// patchWithReplace applies the given operations to the resource with the given identifier for handlers that do not
+// implement ResourcePatcher. The patched attributes are validated and stored with Replace, which receives the version of
+// the fetched resource through ExpectedVersion. An empty resource is returned if nothing changed.
+func (t ResourceType) patchWithReplace(r *http.Request, id string, operations []PatchOperation) (Resource, error) {
+	resource, err := t.Handler.Get(r, id)
+	if err != nil {
+		return Resource{}, err
+	}
+
+	// The resource was modified after its preconditions were evaluated.
+	if version, ok := ExpectedVersion(r); ok && version != resource.Meta.Version {
+		return Resource{}, *preconditionFailed()
+	}
+
+	attributes := ResourceAttributes{}
+	for k, v := range resource.Attributes {
+		attributes[k] = v
+	}
+	if _, ok := findKey(attributes, schema.CommonAttributeExternalID); !ok && resource.ExternalID.Present() {
+		attributes[schema.CommonAttributeExternalID] = resource.ExternalID.Value()
+	}
+
+	patched, changed, err := t.ApplyPatch(attributes, operations)
+	if err != nil {
+		return Resource{}, err
+	}
+	if !changed {
+		return Resource{}, nil
+	}
+
+	schemas := []string{t.Schema.ID}
+	for _, extension := range t.SchemaExtensions {
+		if _, ok := findKey(patched, extension.Schema.ID); ok {
+			schemas = append(schemas, extension.Schema.ID)
+		}
+	}
+	patched["schemas"] = schemas
+
+	raw, err := json.Marshal(patched)
+	if err != nil {
+		return Resource{}, err
+	}
+	validated, scimErr := t.validate(raw)
+	if scimErr != nil {
+		return Resource{}, *scimErr
+	}
+
+	ctx := context.WithValue(r.Context(), expectedVersionKey{}, resource.Meta.Version)
+	return t.Handler.Replace(r.WithContext(ctx), id, validated)
+}
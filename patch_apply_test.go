@@ -0,0 +1,335 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/errors"
+)
+
+func TestResourceTypeApplyPatch(t *testing.T) {
+	const enterprise = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+	resourceType := ResourceType{
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema:   getUserSchema(),
+		SchemaExtensions: []SchemaExtension{
+			{Schema: getUserExtensionSchema()},
+		},
+	}
+
+	newAttributes := func() ResourceAttributes {
+		return ResourceAttributes{
+			"userName": "alice",
+			"Name": map[string]interface{}{
+				"familyName": "Smith",
+				"givenName":  "Alice",
+			},
+			"emails": []interface{}{
+				map[string]interface{}{"value": "alice@example.com", "type": "work", "primary": true},
+				map[string]interface{}{"value": "alice@example.org", "type": "home"},
+			},
+			enterprise: map[string]interface{}{
+				"employeeNumber": "42",
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		operations string
+		expected   string
+		unchanged  bool
+	}{
+		{
+			name:       "add without path",
+			operations: `{"op": "add", "value": {"displayName": "Alice Smith", "name.givenName": "Ally"}}`,
+			expected: `{
+				"userName": "alice",
+				"displayName": "Alice Smith",
+				"Name": {"familyName": "Smith", "givenName": "Ally"},
+				"emails": [
+					{"value": "alice@example.com", "type": "work", "primary": true},
+					{"value": "alice@example.org", "type": "home"}
+				],
+				"` + enterprise + `": {"employeeNumber": "42"}
+			}`,
+		},
+		{
+			name:       "add merges complex attribute",
+			operations: `{"op": "add", "path": "name", "value": {"givenName": "Ally"}}`,
+			expected: `{
+				"userName": "alice",
+				"Name": {"familyName": "Smith", "givenName": "Ally"},
+				"emails": [
+					{"value": "alice@example.com", "type": "work", "primary": true},
+					{"value": "alice@example.org", "type": "home"}
+				],
+				"` + enterprise + `": {"employeeNumber": "42"}
+			}`,
+		},
+		{
+			name:       "add primary value",
+			operations: `{"op": "add", "path": "emails", "value": [{"value": "alice@example.net", "primary": true}]}`,
+			expected: `{
+				"userName": "alice",
+				"Name": {"familyName": "Smith", "givenName": "Alice"},
+				"emails": [
+					{"value": "alice@example.com", "type": "work", "primary": false},
+					{"value": "alice@example.org", "type": "home"},
+					{"value": "alice@example.net", "primary": true}
+				],
+				"` + enterprise + `": {"employeeNumber": "42"}
+			}`,
+		},
+		{
+			name:       "add existing value",
+			operations: `{"op": "add", "path": "emails", "value": [{"value": "alice@example.org", "type": "home"}]}`,
+			unchanged:  true,
+		},
+		{
+			name:       "add with value filter",
+			operations: `{"op": "add", "path": "emails[type eq \"other\"].value", "value": "alice@example.net"}`,
+			expected: `{
+				"userName": "alice",
+				"Name": {"familyName": "Smith", "givenName": "Alice"},
+				"emails": [
+					{"value": "alice@example.com", "type": "work", "primary": true},
+					{"value": "alice@example.org", "type": "home"},
+					{"value": "alice@example.net", "type": "other"}
+				],
+				"` + enterprise + `": {"employeeNumber": "42"}
+			}`,
+		},
+		{
+			name:       "replace with value filter",
+			operations: `{"op": "replace", "path": "emails[type eq \"home\"].primary", "value": true}`,
+			expected: `{
+				"userName": "alice",
+				"Name": {"familyName": "Smith", "givenName": "Alice"},
+				"emails": [
+					{"value": "alice@example.com", "type": "work", "primary": false},
+					{"value": "alice@example.org", "type": "home", "primary": true}
+				],
+				"` + enterprise + `": {"employeeNumber": "42"}
+			}`,
+		},
+		{
+			name:       "replace multi-valued attribute",
+			operations: `{"op": "replace", "path": "emails", "value": [{"value": "alice@example.net"}]}`,
+			expected: `{
+				"userName": "alice",
+				"Name": {"familyName": "Smith", "givenName": "Alice"},
+				"emails": [{"value": "alice@example.net"}],
+				"` + enterprise + `": {"employeeNumber": "42"}
+			}`,
+		},
+		{
+			name:       "replace same value",
+			operations: `{"op": "replace", "path": "userName", "value": "alice"}`,
+			unchanged:  true,
+		},
+		{
+			name:       "replace extension attribute",
+			operations: `{"op": "replace", "path": "` + enterprise + `:organization", "value": "Elimity"}`,
+			expected: `{
+				"userName": "alice",
+				"Name": {"familyName": "Smith", "givenName": "Alice"},
+				"emails": [
+					{"value": "alice@example.com", "type": "work", "primary": true},
+					{"value": "alice@example.org", "type": "home"}
+				],
+				"` + enterprise + `": {"employeeNumber": "42", "organization": "Elimity"}
+			}`,
+		},
+		{
+			name:       "remove with value filter",
+			operations: `{"op": "remove", "path": "emails[type eq \"work\"]"}`,
+			expected: `{
+				"userName": "alice",
+				"Name": {"familyName": "Smith", "givenName": "Alice"},
+				"emails": [{"value": "alice@example.org", "type": "home"}],
+				"` + enterprise + `": {"employeeNumber": "42"}
+			}`,
+		},
+		{
+			name:       "remove values",
+			operations: `{"op": "remove", "path": "emails", "value": [{"value": "alice@example.com"}, {"value": "alice@example.org"}]}`,
+			expected: `{
+				"userName": "alice",
+				"Name": {"familyName": "Smith", "givenName": "Alice"},
+				"` + enterprise + `": {"employeeNumber": "42"}
+			}`,
+		},
+		{
+			name:       "remove sub-attribute",
+			operations: `{"op": "remove", "path": "name.givenName"}, {"op": "remove", "path": "name.familyName"}`,
+			expected: `{
+				"userName": "alice",
+				"emails": [
+					{"value": "alice@example.com", "type": "work", "primary": true},
+					{"value": "alice@example.org", "type": "home"}
+				],
+				"` + enterprise + `": {"employeeNumber": "42"}
+			}`,
+		},
+		{
+			name:       "remove extension attribute",
+			operations: `{"op": "remove", "path": "` + enterprise + `:employeeNumber"}`,
+			expected: `{
+				"userName": "alice",
+				"Name": {"familyName": "Smith", "givenName": "Alice"},
+				"emails": [
+					{"value": "alice@example.com", "type": "work", "primary": true},
+					{"value": "alice@example.org", "type": "home"}
+				]
+			}`,
+		},
+		{
+			name:       "remove absent attribute",
+			operations: `{"op": "remove", "path": "displayName"}`,
+			unchanged:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			operations := validTestPatchOperations(t, resourceType, test.operations)
+			attributes := newAttributes()
+			patched, changed, err := resourceType.ApplyPatch(attributes, operations)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqual(t, !test.unchanged, changed)
+
+			// The given attributes are not modified.
+			original, _ := json.Marshal(newAttributes())
+			actual, _ := json.Marshal(attributes)
+			assertEqualJSON(t, string(original), string(actual))
+
+			if test.unchanged {
+				return
+			}
+			actual, err = json.Marshal(patched)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqualJSON(t, test.expected, string(actual))
+		})
+	}
+
+	t.Run("no target", func(t *testing.T) {
+		operations := validTestPatchOperations(t, resourceType, `{"op": "replace", "path": "emails[type eq \"other\"].value", "value": "x"}`)
+		_, _, err := resourceType.ApplyPatch(newAttributes(), operations)
+		scimErr, ok := err.(errors.ScimError)
+		assertTrue(t, ok)
+		assertEqual(t, errors.ScimTypeNoTarget, scimErr.ScimType)
+	})
+
+	t.Run("invalid stored value", func(t *testing.T) {
+		attributes := newAttributes()
+		attributes["emails"] = []interface{}{
+			map[string]interface{}{"value": "alice@example.com", "type": 42},
+			map[string]interface{}{"value": "alice@example.org", "type": "home"},
+		}
+		operations := validTestPatchOperations(t, resourceType, `{"op": "remove", "path": "emails[type eq \"home\"]"}`)
+		patched, changed, err := resourceType.ApplyPatch(attributes, operations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertTrue(t, changed)
+		actual, _ := json.Marshal(patched["emails"])
+		assertEqualJSON(t, `[{"value": "alice@example.com", "type": 42}]`, string(actual))
+	})
+}
+
+func TestServerPatchWithReplace(t *testing.T) {
+	handler := &testReplaceOnlyHandler{ResourceHandler: newTestResourceHandler()}
+	s, err := NewServer(
+		&ServerArgs{
+			ServiceProviderConfig: &ServiceProviderConfig{},
+			ResourceTypes: []ResourceType{
+				{
+					Name:     "User",
+					Endpoint: "/Users",
+					Schema:   getUserSchema(),
+					Handler:  handler,
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok := handler.ResourceHandler.(ResourcePatcher)
+	assertTrue(t, ok)
+	_, ok = interface{}(handler).(ResourcePatcher)
+	assertFalse(t, ok)
+
+	patch := func(operations string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/Users/0001", strings.NewReader(`{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [`+operations+`]
+		}`))
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := patch(`{"op": "add", "path": "displayName", "value": "Test"}`)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+	assertEqual(t, "v1", handler.version)
+
+	var resource map[string]interface{}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+	assertEqual(t, "Test", resource["displayName"])
+	assertEqual(t, "test01", resource["userName"])
+
+	stored, err := handler.Get(nil, "0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "Test", stored.Attributes["displayName"])
+
+	rr = patch(`{"op": "replace", "path": "displayName", "value": "Test"}`)
+	assertEqualStatusCode(t, http.StatusNoContent, rr.Code)
+
+	rr = patch(`{"op": "remove", "path": "userName"}`)
+	assertEqualStatusCode(t, http.StatusBadRequest, rr.Code)
+
+	req := httptest.NewRequest(http.MethodPatch, "/Users/9999", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "add", "path": "displayName", "value": "Test"}]
+	}`))
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusNotFound, rr.Code)
+}
+
+func validTestPatchOperations(t *testing.T, resourceType ResourceType, operations string) []PatchOperation {
+	req := httptest.NewRequest(http.MethodPatch, "/Users/0001", strings.NewReader(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [`+operations+`]
+	}`))
+	patch, scimErr := resourceType.validatePatch(req)
+	if scimErr != nil {
+		t.Fatal(scimErr)
+	}
+	return patch
+}
+
+// testReplaceOnlyHandler hides the Patch method of the wrapped handler and records the expected version of the last
+// replace request.
+type testReplaceOnlyHandler struct {
+	ResourceHandler
+	version string
+}
+
+func (h *testReplaceOnlyHandler) Replace(r *http.Request, id string, attributes ResourceAttributes) (Resource, error) {
+	h.version, _ = ExpectedVersion(r)
+	return h.ResourceHandler.Replace(r, id, attributes)
+}
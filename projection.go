@@ -0,0 +1,252 @@
+package scim
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/elimity-com/scim/schema"
+)
+
+// attributeReturned returns the "returned" characteristic of the attribute with the given name. Attributes that are
+// not defined by the given attributes are returned by default.
+func attributeReturned(attributes schema.Attributes, name string) (schema.CoreAttribute, string) {
+	attribute, ok := attributes.ContainsAttribute(name)
+	if !ok {
+		return schema.CoreAttribute{}, "default"
+	}
+	return attribute, attribute.Returned()
+}
+
+// projectAttributes returns the given values that are to be returned. If include is true, the selection contains the
+// requested attributes, otherwise it contains the excluded attributes.
+func projectAttributes(attributes schema.Attributes, values map[string]interface{}, selection map[string]map[string]bool, include bool) map[string]interface{} {
+	projected := map[string]interface{}{}
+	for name, value := range values {
+		attribute, returned := attributeReturned(attributes, name)
+		selected, named := selection[strings.ToLower(name)]
+
+		switch returned {
+		case "never":
+			continue
+		case "always":
+			if !include {
+				selected = nil
+			} else if !named {
+				selected = map[string]bool{"": true}
+			}
+		case "request":
+			if !include || !named {
+				continue
+			}
+		default:
+			if include && !named {
+				continue
+			}
+			if !include && selected[""] {
+				continue
+			}
+		}
+
+		if m, ok := value.(meta); ok {
+			value = m.toMap()
+		}
+		projected[name] = projectSubAttributes(attribute.SubAttributes(), value, selected, include)
+	}
+	return projected
+}
+
+// projectSubAttributes returns the sub-attributes of the given (multi-valued) complex value that are to be returned.
+// A sub-attribute that is returned on request is only returned if either it or its parent attribute is requested.
+func projectSubAttributes(subAttributes schema.Attributes, value interface{}, selection map[string]bool, include bool) interface{} {
+	project := func(values map[string]interface{}) map[string]interface{} {
+		projected := map[string]interface{}{}
+		for name, value := range values {
+			_, returned := attributeReturned(subAttributes, name)
+			named := selection[strings.ToLower(name)]
+
+			switch returned {
+			case "never":
+				continue
+			case "always":
+			case "request":
+				if !include || !(named || selection[""]) {
+					continue
+				}
+			default:
+				if include && !(named || selection[""]) {
+					continue
+				}
+				if !include && named {
+					continue
+				}
+			}
+			projected[name] = value
+		}
+		return projected
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return project(v)
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		values := make([]interface{}, 0, len(v))
+		for _, value := range v {
+			if m, ok := value.(map[string]interface{}); ok {
+				values = append(values, project(m))
+				continue
+			}
+			values = append(values, value)
+		}
+		return values
+	case []map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		values := make([]interface{}, 0, len(v))
+		for _, m := range v {
+			values = append(values, project(m))
+		}
+		return values
+	default:
+		return value
+	}
+}
+
+// selectAll returns a selection of all the given values as a whole.
+func selectAll(values map[string]interface{}) map[string]map[string]bool {
+	selection := map[string]map[string]bool{}
+	for name := range values {
+		selection[strings.ToLower(name)] = map[string]bool{"": true}
+	}
+	return selection
+}
+
+// splitAttributeList splits the comma-separated attribute paths of an "attributes" or "excludedAttributes" query
+// parameter.
+func splitAttributeList(raw string) []string {
+	var paths []string
+	for _, path := range strings.Split(raw, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// attributeSelection maps the lower-cased identifiers of schemas to the lower-cased names of the selected attributes
+// of that schema, which in turn map to the selected sub-attributes. The empty name denotes the schema or attribute
+// as a whole.
+type attributeSelection map[string]map[string]map[string]bool
+
+// newAttributeSelection parses the given attribute paths, e.g., "userName", "name.givenName" or
+// "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber", against the schemas of the given
+// resource type.
+func newAttributeSelection(resourceType ResourceType, paths []string) attributeSelection {
+	coreID := strings.ToLower(resourceType.Schema.ID)
+
+	selection := attributeSelection{}
+	for _, path := range paths {
+		schemaID, rest := coreID, strings.ToLower(path)
+		for _, extension := range resourceType.SchemaExtensions {
+			id := strings.ToLower(extension.Schema.ID)
+			if rest == id || strings.HasPrefix(rest, id+":") {
+				schemaID, rest = id, strings.TrimPrefix(strings.TrimPrefix(rest, id), ":")
+				break
+			}
+		}
+		if schemaID == coreID {
+			rest = strings.TrimPrefix(rest, coreID+":")
+		}
+
+		name, subName := rest, ""
+		if i := strings.Index(rest, "."); i != -1 {
+			name, subName = rest[:i], rest[i+1:]
+		}
+
+		if selection[schemaID] == nil {
+			selection[schemaID] = map[string]map[string]bool{}
+		}
+		if selection[schemaID][name] == nil {
+			selection[schemaID][name] = map[string]bool{}
+		}
+		selection[schemaID][name][subName] = true
+	}
+	return selection
+}
+
+// projection represents the "attributes" and "excludedAttributes" parameters of a request, as described in RFC 7644
+// Section 3.4.2.5. If both are given, "excludedAttributes" is ignored.
+type projection struct {
+	attributes         []string
+	excludedAttributes []string
+}
+
+func newProjection(attributes, excludedAttributes []string) projection {
+	return projection{
+		attributes:         attributes,
+		excludedAttributes: excludedAttributes,
+	}
+}
+
+// projectionFromQuery returns the projection given by the query parameters of the given request.
+func projectionFromQuery(r *http.Request) projection {
+	query := r.URL.Query()
+	return newProjection(
+		splitAttributeList(query.Get("attributes")),
+		splitAttributeList(query.Get("excludedAttributes")),
+	)
+}
+
+// apply returns the attributes of the given resource response that are to be returned, based on the projection and
+// the "returned" characteristics of the attributes in the schemas of the given resource type. Attributes that are
+// never returned are always removed, attributes that are returned on request are only kept if they are requested.
+func (p projection) apply(resourceType ResourceType, response ResourceAttributes) ResourceAttributes {
+	include := len(p.attributes) != 0
+	selection := newAttributeSelection(resourceType, p.excludedAttributes)
+	if include {
+		selection = newAttributeSelection(resourceType, p.attributes)
+	}
+
+	core := make(map[string]interface{}, len(response))
+	for name, value := range response {
+		core[name] = value
+	}
+	for _, extension := range resourceType.SchemaExtensions {
+		delete(core, extension.Schema.ID)
+	}
+
+	attributes := schema.WithCommonAttributes(resourceType.Schema).Attributes
+	projected := ResourceAttributes(projectAttributes(attributes, core, selection[strings.ToLower(resourceType.Schema.ID)], include))
+
+	for _, extension := range resourceType.SchemaExtensions {
+		var value map[string]interface{}
+		switch v := response[extension.Schema.ID].(type) {
+		case map[string]interface{}:
+			value = v
+		case ResourceAttributes:
+			value = v
+		default:
+			continue
+		}
+
+		extensionSelection := selection[strings.ToLower(extension.Schema.ID)]
+		if extensionSelection[""][""] {
+			if !include {
+				continue
+			}
+			extensionSelection = selectAll(value)
+		}
+
+		if projectedExtension := projectAttributes(extension.Schema.Attributes, value, extensionSelection, include); len(projectedExtension) != 0 {
+			projected[extension.Schema.ID] = projectedExtension
+		}
+	}
+
+	if schemas, ok := response["schemas"]; ok {
+		projected["schemas"] = schemas
+	}
+	return projected
+}
@@ -0,0 +1,265 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elimity-com/scim/schema"
+)
+
+func TestProjectionApply(t *testing.T) {
+	const enterprise = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+	resourceType := ResourceType{
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema: schema.Schema{
+			ID: "urn:ietf:params:scim:schemas:core:2.0:User",
+			Attributes: []schema.CoreAttribute{
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					Name:     "userName",
+					Returned: schema.AttributeReturnedAlways(),
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					Name: "displayName",
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					Name:     "password",
+					Returned: schema.AttributeReturnedNever(),
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					Name:     "nickName",
+					Returned: schema.AttributeReturnedRequest(),
+				})),
+				schema.ComplexCoreAttribute(schema.ComplexParams{
+					Name:        "emails",
+					MultiValued: true,
+					SubAttributes: []schema.SimpleParams{
+						schema.SimpleStringParams(schema.StringParams{
+							Name: "value",
+						}),
+						schema.SimpleStringParams(schema.StringParams{
+							Name: "type",
+						}),
+						schema.SimpleStringParams(schema.StringParams{
+							Name:     "secret",
+							Returned: schema.AttributeReturnedNever(),
+						}),
+					},
+				}),
+			},
+		},
+		SchemaExtensions: []SchemaExtension{
+			{Schema: getUserExtensionSchema()},
+		},
+	}
+
+	newResponse := func() ResourceAttributes {
+		return ResourceAttributes{
+			"schemas":     []string{"urn:ietf:params:scim:schemas:core:2.0:User", enterprise},
+			"id":          "0001",
+			"userName":    "alice",
+			"displayName": "Alice",
+			"password":    "secret",
+			"nickName":    "Al",
+			"emails": []interface{}{
+				map[string]interface{}{"value": "alice@example.com", "type": "work", "secret": "x"},
+			},
+			"meta": meta{ResourceType: "User", Location: "Users/0001", Version: "v1"},
+			enterprise: map[string]interface{}{
+				"employeeNumber": "42",
+				"organization":   "Elimity",
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		projection projection
+		expected   string
+	}{
+		{
+			name:       "no projection",
+			projection: newProjection(nil, nil),
+			expected: `{
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "` + enterprise + `"],
+				"id": "0001",
+				"userName": "alice",
+				"displayName": "Alice",
+				"emails": [{"value": "alice@example.com", "type": "work"}],
+				"meta": {"resourceType": "User", "location": "Users/0001", "version": "v1"},
+				"` + enterprise + `": {"employeeNumber": "42", "organization": "Elimity"}
+			}`,
+		},
+		{
+			name:       "attributes",
+			projection: newProjection([]string{"displayName", "password"}, nil),
+			expected: `{
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "` + enterprise + `"],
+				"id": "0001",
+				"userName": "alice",
+				"displayName": "Alice"
+			}`,
+		},
+		{
+			name:       "attributes with request attribute",
+			projection: newProjection([]string{"NICKNAME"}, nil),
+			expected: `{
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "` + enterprise + `"],
+				"id": "0001",
+				"userName": "alice",
+				"nickName": "Al"
+			}`,
+		},
+		{
+			name:       "attributes with sub-attributes",
+			projection: newProjection([]string{"emails.value", "meta.version"}, nil),
+			expected: `{
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "` + enterprise + `"],
+				"id": "0001",
+				"userName": "alice",
+				"emails": [{"value": "alice@example.com"}],
+				"meta": {"version": "v1"}
+			}`,
+		},
+		{
+			name:       "attributes with extension",
+			projection: newProjection([]string{enterprise + ":employeeNumber", "urn:ietf:params:scim:schemas:core:2.0:User:displayName"}, nil),
+			expected: `{
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "` + enterprise + `"],
+				"id": "0001",
+				"userName": "alice",
+				"displayName": "Alice",
+				"` + enterprise + `": {"employeeNumber": "42"}
+			}`,
+		},
+		{
+			name:       "attributes with whole extension",
+			projection: newProjection([]string{enterprise}, nil),
+			expected: `{
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "` + enterprise + `"],
+				"id": "0001",
+				"userName": "alice",
+				"` + enterprise + `": {"employeeNumber": "42", "organization": "Elimity"}
+			}`,
+		},
+		{
+			name:       "excluded attributes",
+			projection: newProjection(nil, []string{"userName", "displayName", "emails.type", "meta", enterprise + ":organization"}),
+			expected: `{
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "` + enterprise + `"],
+				"id": "0001",
+				"userName": "alice",
+				"emails": [{"value": "alice@example.com"}],
+				"` + enterprise + `": {"employeeNumber": "42"}
+			}`,
+		},
+		{
+			name:       "excluded extension",
+			projection: newProjection(nil, []string{enterprise, "emails"}),
+			expected: `{
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "` + enterprise + `"],
+				"id": "0001",
+				"userName": "alice",
+				"displayName": "Alice",
+				"meta": {"resourceType": "User", "location": "Users/0001", "version": "v1"}
+			}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := json.Marshal(test.projection.apply(resourceType, newResponse()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqualJSON(t, test.expected, string(actual))
+		})
+	}
+}
+
+func TestServerProjection(t *testing.T) {
+	t.Run("GET", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/Users/0001?attributes=userName", nil)
+		rr := httptest.NewRecorder()
+		newTestServer(t).ServeHTTP(rr, req)
+		assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+		assertEqualJSON(t, `{
+			"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+			"id": "0001",
+			"userName": "test01"
+		}`, rr.Body.String())
+	})
+
+	t.Run("list", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/Users?count=1&excludedAttributes=meta,externalId", nil)
+		rr := httptest.NewRecorder()
+		newTestServer(t).ServeHTTP(rr, req)
+		assertEqualStatusCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Resources []map[string]interface{}
+		}
+		assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assertLen(t, response.Resources, 1)
+		assertNil(t, response.Resources[0]["meta"], "meta")
+		assertNil(t, response.Resources[0]["externalId"], "externalId")
+		assertNotNil(t, response.Resources[0]["userName"], "userName")
+	})
+
+	t.Run("POST", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/Users?attributes=displayName", strings.NewReader(`{
+			"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+			"userName": "alice",
+			"displayName": "Alice"
+		}`))
+		rr := httptest.NewRecorder()
+		newTestServer(t).ServeHTTP(rr, req)
+		assertEqualStatusCode(t, http.StatusCreated, rr.Code)
+
+		var resource map[string]interface{}
+		assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+		assertEqual(t, "Alice", resource["displayName"])
+		assertNil(t, resource["userName"], "userName")
+		assertNotNil(t, resource["id"], "id")
+	})
+}
+
+func TestServerReturnedNever(t *testing.T) {
+	s, err := NewServer(
+		&ServerArgs{
+			ServiceProviderConfig: &ServiceProviderConfig{},
+			ResourceTypes: []ResourceType{
+				{
+					Name:     "User",
+					Endpoint: "/Users",
+					Schema:   schema.CoreUserSchema(),
+					Handler:  newTestResourceHandler(),
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []string{"/Users", "/Users?attributes=password"} {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{
+			"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+			"userName": "alice",
+			"password": "secret"
+		}`))
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		assertEqualStatusCode(t, http.StatusCreated, rr.Code)
+
+		var resource map[string]interface{}
+		assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+		assertNil(t, resource["password"], "password")
+		assertNotNil(t, resource["id"], "id")
+	}
+}
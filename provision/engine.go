@@ -0,0 +1,490 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/client"
+	scimErrors "github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+)
+
+// retryable checks whether a request that failed with the given error can be retried: network errors (including
+// timeouts), "429 Too Many Requests" and server errors are retried. Other errors, e.g., invalid responses, are not.
+func retryable(err error) bool {
+	var scimErr scimErrors.ScimError
+	if errors.As(err, &scimErr) {
+		return scimErr.Status == http.StatusTooManyRequests || scimErr.Status >= http.StatusInternalServerError
+	}
+	// The errors of the context also implement net.Error, but are not caused by the network.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// sleep waits for the given duration or until the given context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// status returns the HTTP status of the given SCIM error, or zero.
+func status(err error) int {
+	var scimErr scimErrors.ScimError
+	if errors.As(err, &scimErr) {
+		return scimErr.Status
+	}
+	return 0
+}
+
+// withExternalID returns the attributes of the given resource, including its "externalId" if present.
+func withExternalID(r scim.Resource) scim.ResourceAttributes {
+	attributes := make(scim.ResourceAttributes, len(r.Attributes)+1)
+	for k, v := range r.Attributes {
+		attributes[k] = v
+	}
+	if r.ExternalID.Present() {
+		attributes[schema.CommonAttributeExternalID] = r.ExternalID.Value()
+	}
+	return attributes
+}
+
+// withoutUnreturned returns a copy of the given attributes without the attributes that are never returned by a service
+// provider, i.e., attributes that are returned "never" or are write-only.
+func withoutUnreturned(attrs schema.Attributes, attributes map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(attributes))
+	for k, v := range attributes {
+		c[k] = v
+	}
+	for _, attr := range attrs {
+		if attr.Returned() == "never" || attr.Mutability() == "writeOnly" {
+			for k := range c {
+				if strings.EqualFold(k, attr.Name()) {
+					delete(c, k)
+				}
+			}
+		}
+	}
+	return c
+}
+
+// Engine provisions resources of a single resource type to a SCIM service provider. It compares the resources of a
+// source of truth with the resources at the service provider, plans the creates, patches and deletes that reconcile
+// them and executes the plan.
+//
+// Source resources are matched with the resources at the service provider on their "externalId" and then on their
+// "userName", see WithMatchAttributes. Matched resources are compared with ResourceType.Diff, so the source resources
+// are authoritative: attributes that are absent in the source are removed at the service provider.
+type Engine struct {
+	client       *client.Client
+	resourceType scim.ResourceType
+
+	matchAttributes []string
+	deletes         bool
+	retries         int
+	backoff         time.Duration
+	interval        time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewEngine returns an engine that provisions resources of the given resource type with the given client. The schemas
+// of the resource type are used to validate and compare the resources, e.g., as returned by client.Client.Discover.
+func NewEngine(c *client.Client, resourceType scim.ResourceType, opts ...Option) *Engine {
+	e := &Engine{
+		client:          c,
+		resourceType:    resourceType,
+		matchAttributes: []string{schema.CommonAttributeExternalID, "userName"},
+		retries:         3,
+		backoff:         500 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Apply executes the actions of the given plan in order and returns a reconciliation report. Failed actions do not
+// stop the execution of the plan, their errors are part of the report.
+func (e *Engine) Apply(ctx context.Context, plan Plan) Report {
+	report := Report{
+		Invalid:   plan.Invalid,
+		Unchanged: plan.Unchanged,
+	}
+	for _, action := range plan.Actions {
+		if err := ctx.Err(); err != nil {
+			report.Results = append(report.Results, Result{Action: action, Err: err})
+			continue
+		}
+		report.Results = append(report.Results, e.apply(ctx, action))
+	}
+	return report
+}
+
+// Plan returns the actions that reconcile the resources at the service provider with the given source resources.
+// Source resources that do not conform to the schemas of the resource type are not planned, but the resources they
+// match are not deleted either.
+func (e *Engine) Plan(ctx context.Context, source []scim.Resource) (Plan, error) {
+	remote, err := e.list(ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	index := make(map[string][]int)
+	for i, r := range remote {
+		attributes := withExternalID(r)
+		for _, name := range e.matchAttributes {
+			if key, _, ok := e.key(name, attributes); ok {
+				index[key] = append(index[key], i)
+			}
+		}
+	}
+
+	var (
+		plan    Plan
+		matched = make(map[int]bool)
+	)
+	for _, r := range source {
+		// The source resource is matched before it is validated, so that the resource it matches is never deleted.
+		i, match, err := e.match(withExternalID(r), index)
+		if err != nil {
+			plan.Invalid = append(plan.Invalid, Invalid{Resource: r, Err: err})
+			continue
+		}
+		if i != -1 {
+			if matched[i] {
+				plan.Invalid = append(plan.Invalid, Invalid{
+					Resource: r,
+					Err:      fmt.Errorf("resource %s is matched by multiple source resources (%s)", remote[i].ID, match),
+				})
+				continue
+			}
+			matched[i] = true
+		}
+
+		attributes, err := e.resourceType.Validate(withExternalID(r))
+		if err != nil {
+			plan.Invalid = append(plan.Invalid, Invalid{Resource: r, Err: err})
+			continue
+		}
+		if i == -1 {
+			plan.Actions = append(plan.Actions, Action{
+				Type:       ActionCreate,
+				Match:      match,
+				Resource:   r,
+				Attributes: attributes,
+			})
+			continue
+		}
+
+		operations, err := e.diff(remote[i], attributes)
+		if err != nil {
+			plan.Invalid = append(plan.Invalid, Invalid{Resource: r, Err: err})
+			continue
+		}
+		if len(operations) == 0 {
+			plan.Unchanged++
+			continue
+		}
+		plan.Actions = append(plan.Actions, Action{
+			Type:       ActionPatch,
+			ID:         remote[i].ID,
+			Match:      match,
+			Resource:   r,
+			Attributes: attributes,
+			Operations: operations,
+		})
+	}
+
+	if e.deletes {
+		for i, r := range remote {
+			if !matched[i] {
+				plan.Actions = append(plan.Actions, Action{
+					Type:     ActionDelete,
+					ID:       r.ID,
+					Resource: r,
+				})
+			}
+		}
+	}
+	return plan, nil
+}
+
+// Sync plans and applies the actions that reconcile the resources at the service provider with the given source
+// resources.
+func (e *Engine) Sync(ctx context.Context, source []scim.Resource) (Report, error) {
+	plan, err := e.Plan(ctx, source)
+	if err != nil {
+		return Report{}, err
+	}
+	return e.Apply(ctx, plan), nil
+}
+
+// apply executes the given action.
+func (e *Engine) apply(ctx context.Context, action Action) Result {
+	result := Result{
+		Action: action,
+		ID:     action.ID,
+	}
+	switch action.Type {
+	case ActionCreate:
+		return e.create(ctx, action)
+	case ActionPatch:
+		result.Attempts, result.Err = e.do(ctx, func() error {
+			_, err := e.client.Patch(ctx, e.resourceType.Endpoint, action.ID, action.Operations)
+			return err
+		})
+	case ActionDelete:
+		result.Attempts, result.Err = e.do(ctx, func() error {
+			err := e.client.Delete(ctx, e.resourceType.Endpoint, action.ID)
+			// The resource might have been deleted by a previous attempt of which the response got lost.
+			if status(err) == http.StatusNotFound {
+				return nil
+			}
+			return err
+		})
+	default:
+		result.Err = fmt.Errorf("unknown action type %q", action.Type)
+	}
+	return result
+}
+
+// create executes the given ActionCreate. If the resource already exists, it is matched and patched instead.
+func (e *Engine) create(ctx context.Context, action Action) Result {
+	result := Result{Action: action}
+	attributes := e.withSchemas(action.Attributes)
+	var created scim.Resource
+	result.Attempts, result.Err = e.do(ctx, func() (err error) {
+		created, err = e.client.Create(ctx, e.resourceType.Endpoint, attributes)
+		return err
+	})
+	if result.Err == nil {
+		result.ID = created.ID
+		return result
+	}
+	// The resource might have been created by a previous attempt of which the response got lost.
+	if status(result.Err) != http.StatusConflict || action.Match == "" {
+		return result
+	}
+	var existing client.ListResponse
+	attempts, err := e.do(ctx, func() (err error) {
+		existing, err = e.client.List(ctx, e.resourceType.Endpoint, client.ListParams{Filter: action.Match})
+		return err
+	})
+	result.Attempts += attempts
+	if err != nil || len(existing.Resources) != 1 {
+		return result
+	}
+	result.ID, result.Matched, result.Err = existing.Resources[0].ID, true, nil
+
+	operations, err := e.diff(existing.Resources[0], action.Attributes)
+	if err != nil || len(operations) == 0 {
+		result.Err = err
+		return result
+	}
+	attempts, result.Err = e.do(ctx, func() error {
+		_, err := e.client.Patch(ctx, e.resourceType.Endpoint, result.ID, operations)
+		return err
+	})
+	result.Attempts += attempts
+	return result
+}
+
+// diff returns the operations that change the given resource at the service provider into the given validated
+// attributes. Attributes that are never returned by the service provider, e.g., passwords, are not compared, neither
+// in the core schema nor in the schema extensions.
+func (e *Engine) diff(r scim.Resource, attributes scim.ResourceAttributes) ([]scim.PatchOperation, error) {
+	from, err := e.resourceType.Validate(withExternalID(r))
+	if err != nil {
+		// Compare the attributes as returned by the service provider.
+		from = withExternalID(r)
+	}
+
+	to := scim.ResourceAttributes(withoutUnreturned(e.resourceType.Schema.Attributes, attributes))
+	for _, extension := range e.resourceType.SchemaExtensions {
+		for k, v := range to {
+			if m, ok := v.(map[string]interface{}); ok && strings.EqualFold(k, extension.Schema.ID) {
+				to[k] = withoutUnreturned(extension.Schema.Attributes, m)
+			}
+		}
+	}
+	return e.resourceType.Diff(from, to)
+}
+
+// do calls the given function, which sends a request, respecting the rate limit. Requests that fail with a retryable
+// error are retried with an exponential backoff. It returns the number of attempts.
+func (e *Engine) do(ctx context.Context, f func() error) (int, error) {
+	var (
+		attempts int
+		backoff  = e.backoff
+	)
+	for {
+		if err := e.wait(ctx); err != nil {
+			return attempts, err
+		}
+		attempts++
+		err := f()
+		if err == nil || attempts > e.retries || !retryable(err) {
+			return attempts, err
+		}
+		if err := sleep(ctx, backoff); err != nil {
+			return attempts, err
+		}
+		backoff *= 2
+	}
+}
+
+// key returns the key of the given match attribute of the given attributes, which is used to match resources, together
+// with the value of the attribute. Values of attributes that are not case-exact are compared case-insensitively.
+func (e *Engine) key(name string, attributes scim.ResourceAttributes) (string, string, bool) {
+	var value string
+	for k, v := range attributes {
+		if s, ok := v.(string); ok && s != "" && strings.EqualFold(k, name) {
+			value = s
+			break
+		}
+	}
+	if value == "" {
+		return "", "", false
+	}
+	key := value
+	if attr, ok := schema.WithCommonAttributes(e.resourceType.Schema).Attributes.ContainsAttribute(name); ok && !attr.CaseExact() {
+		key = strings.ToLower(value)
+	}
+	return strings.ToLower(name) + "\x00" + key, value, true
+}
+
+// list returns all resources at the service provider.
+func (e *Engine) list(ctx context.Context) ([]scim.Resource, error) {
+	var resources []scim.Resource
+	for {
+		var resp client.ListResponse
+		if _, err := e.do(ctx, func() (err error) {
+			resp, err = e.client.List(ctx, e.resourceType.Endpoint, client.ListParams{StartIndex: len(resources) + 1})
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		resources = append(resources, resp.Resources...)
+		if len(resp.Resources) == 0 || resp.TotalResults <= len(resources) {
+			return resources, nil
+		}
+	}
+}
+
+// match returns the index of the resource at the service provider that matches the given attributes, or -1, together
+// with the filter that identifies the resource. It is an error if a match attribute matches multiple resources.
+func (e *Engine) match(attributes scim.ResourceAttributes, index map[string][]int) (int, string, error) {
+	var match string
+	for _, name := range e.matchAttributes {
+		key, value, ok := e.key(name, attributes)
+		if !ok {
+			continue
+		}
+		f := filter.Attr(name).Eq(value).String()
+		if match == "" {
+			match = f
+		}
+		switch indices := index[key]; len(indices) {
+		case 0:
+			continue
+		case 1:
+			return indices[0], f, nil
+		default:
+			return -1, f, fmt.Errorf("%s matches %d resources", f, len(indices))
+		}
+	}
+	return -1, match, nil
+}
+
+// wait waits until the next request is allowed by the rate limit.
+func (e *Engine) wait(ctx context.Context) error {
+	if e.interval <= 0 {
+		return ctx.Err()
+	}
+	e.mu.Lock()
+	now := time.Now()
+	next := e.next
+	if next.Before(now) {
+		next = now
+	}
+	e.next = next.Add(e.interval)
+	e.mu.Unlock()
+	return sleep(ctx, next.Sub(now))
+}
+
+// withSchemas returns the given attributes with the "schemas" attribute of the resource type.
+func (e *Engine) withSchemas(attributes scim.ResourceAttributes) scim.ResourceAttributes {
+	c := make(scim.ResourceAttributes, len(attributes)+1)
+	for k, v := range attributes {
+		c[k] = v
+	}
+	schemas := []string{e.resourceType.Schema.ID}
+	for _, extension := range e.resourceType.SchemaExtensions {
+		if _, ok := attributes[extension.Schema.ID]; ok {
+			schemas = append(schemas, extension.Schema.ID)
+		}
+	}
+	c["schemas"] = schemas
+	return c
+}
+
+// Option configures an Engine.
+type Option func(*Engine)
+
+// WithDeletes configures whether resources at the service provider that do not match any source resource are
+// deleted. They are not deleted by default.
+func WithDeletes(deletes bool) Option {
+	return func(e *Engine) {
+		e.deletes = deletes
+	}
+}
+
+// WithMatchAttributes sets the attributes on which source resources are matched with the resources at the service
+// provider, in order of preference. The first attribute that matches a resource is used. It defaults to "externalId"
+// and "userName".
+func WithMatchAttributes(names ...string) Option {
+	return func(e *Engine) {
+		e.matchAttributes = names
+	}
+}
+
+// WithRateLimit limits the number of requests per second that are sent to the service provider. There is no limit by
+// default.
+func WithRateLimit(requestsPerSecond float64) Option {
+	return func(e *Engine) {
+		if requestsPerSecond > 0 {
+			e.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+		}
+	}
+}
+
+// WithRetries sets the number of times a request that failed with a network error, "429 Too Many Requests" or a
+// server error is retried, and the backoff before the first retry, which doubles with each retry. It defaults to 3
+// retries with a backoff of 500 milliseconds.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(e *Engine) {
+		e.retries = retries
+		e.backoff = backoff
+	}
+}
@@ -0,0 +1,341 @@
+package provision_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/client"
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/memory"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/provision"
+	"github.com/elimity-com/scim/schema"
+)
+
+const testExtension = "urn:example:params:scim:schemas:extension:test:2.0:User"
+
+func TestEngine(t *testing.T) {
+	ctx := context.Background()
+	remote := newTestRemote(t, nil)
+	for _, r := range []scim.ResourceAttributes{
+		{"userName": "alice", "externalId": "e1", "displayName": "Alice"},
+		{"userName": "bob", "displayName": "Bob"},
+		{"userName": "carol", "externalId": "e3"},
+		{"userName": "erin", "externalId": "e5", "emails": []interface{}{map[string]interface{}{"value": "erin@example.com", "type": "work"}}},
+	} {
+		r["schemas"] = []string{"urn:ietf:params:scim:schemas:core:2.0:User"}
+		if _, err := remote.client.Create(ctx, "/Users", r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := []scim.Resource{
+		newTestResource("e1", scim.ResourceAttributes{"userName": "alice", "displayName": "Alice Smith"}),
+		newTestResource("e2", scim.ResourceAttributes{"userName": "BOB", "displayName": "Bob"}),
+		newTestResource("e4", scim.ResourceAttributes{"userName": "dave"}),
+		newTestResource("e5", scim.ResourceAttributes{
+			"userName": "erin",
+			"emails":   []interface{}{map[string]interface{}{"value": "erin@example.com", "type": "work"}},
+		}),
+		newTestResource("e6", scim.ResourceAttributes{"displayName": "No user name"}),
+		newTestResource("e7", scim.ResourceAttributes{"userName": "alice"}),
+	}
+
+	e := provision.NewEngine(remote.client, remote.resourceType, provision.WithDeletes(true))
+	plan, err := e.Plan(ctx, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, action := range plan.Actions {
+		s := string(action.Type)
+		if action.Match != "" {
+			s += " " + action.Match
+		}
+		actions = append(actions, s)
+	}
+	expected := `patch externalId eq "e1"|patch userName eq "BOB"|create externalId eq "e4"|delete`
+	if strings.Join(actions, "|") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(actions, "|"))
+	}
+	if plan.Unchanged != 1 || len(plan.Invalid) != 2 {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+
+	report := e.Apply(ctx, plan)
+	if s := report.String(); s != "created 1, patched 2, deleted 1, unchanged 1, failed 0, invalid 2" {
+		t.Errorf("unexpected report: %s", s)
+	}
+	if remote.handler.Len() != 4 {
+		t.Errorf("expected 4 resources, got %d", remote.handler.Len())
+	}
+
+	// The engine converges: a second run plans no actions.
+	plan, err = e.Plan(ctx, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 0 || plan.Unchanged != 4 {
+		t.Errorf("unexpected plan: %+v", plan.Actions)
+	}
+}
+
+func TestEngine_retries(t *testing.T) {
+	ctx := context.Background()
+	source := []scim.Resource{newTestResource("e1", scim.ResourceAttributes{"userName": "alice"})}
+
+	t.Run("server errors", func(t *testing.T) {
+		var failures int
+		remote := newTestRemote(t, func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+			if r.Method == http.MethodPost && failures < 2 {
+				failures++
+				writeTestError(w, http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+
+		report, err := provision.NewEngine(remote.client, remote.resourceType, provision.WithRetries(3, time.Millisecond)).Sync(ctx, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Results) != 1 || report.Results[0].Err != nil || report.Results[0].Attempts != 3 {
+			t.Errorf("unexpected results: %+v", report.Results)
+		}
+
+		failures = 0
+		source := []scim.Resource{newTestResource("e2", scim.ResourceAttributes{"userName": "bob"})}
+		report, err = provision.NewEngine(remote.client, remote.resourceType, provision.WithRetries(1, time.Millisecond)).Sync(ctx, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Failed()) != 1 || report.Failed()[0].Attempts != 2 {
+			t.Errorf("unexpected results: %+v", report.Results)
+		}
+	})
+
+	t.Run("invalid response", func(t *testing.T) {
+		remote := newTestRemote(t, func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte("{"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+
+		report, err := provision.NewEngine(remote.client, remote.resourceType, provision.WithRetries(3, time.Millisecond)).Sync(ctx, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Failed()) != 1 || report.Failed()[0].Attempts != 1 {
+			t.Errorf("unexpected results: %+v", report.Results)
+		}
+	})
+
+	t.Run("lost response", func(t *testing.T) {
+		var lost bool
+		remote := newTestRemote(t, func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+			if r.Method == http.MethodPost && !lost {
+				// The resource is created, but the client receives an error.
+				lost = true
+				next.ServeHTTP(httptest.NewRecorder(), r)
+				writeTestError(w, http.StatusBadGateway)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+
+		report, err := provision.NewEngine(remote.client, remote.resourceType, provision.WithRetries(3, time.Millisecond)).Sync(ctx, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Results) != 1 || report.Results[0].Err != nil || !report.Results[0].Matched || report.Results[0].ID == "" {
+			t.Errorf("unexpected results: %+v", report.Results)
+		}
+		if remote.handler.Len() != 1 {
+			t.Errorf("expected 1 resource, got %d", remote.handler.Len())
+		}
+	})
+}
+
+func TestEngine_caseExactMatch(t *testing.T) {
+	ctx := context.Background()
+	remote := newTestRemote(t, nil)
+	if _, err := remote.client.Create(ctx, "/Users", scim.ResourceAttributes{
+		"schemas":    []string{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"userName":   "carol",
+		"externalId": "E3",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The "externalId" attribute is case-exact, so the resource is matched by its user name instead.
+	source := []scim.Resource{newTestResource("e3", scim.ResourceAttributes{"userName": "carol"})}
+	plan, err := provision.NewEngine(remote.client, remote.resourceType).Plan(ctx, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 1 || plan.Actions[0].Match != `userName eq "carol"` {
+		t.Errorf("unexpected plan: %+v", plan.Actions)
+	}
+}
+
+func TestEngine_invalidSource(t *testing.T) {
+	ctx := context.Background()
+	remote := newTestRemote(t, nil)
+	if _, err := remote.client.Create(ctx, "/Users", scim.ResourceAttributes{
+		"schemas":    []string{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"userName":   "alice",
+		"externalId": "e1",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The source resource is invalid, but still matches alice, who is therefore not deleted.
+	source := []scim.Resource{newTestResource("e1", scim.ResourceAttributes{"userName": "alice", "active": "yes"})}
+	e := provision.NewEngine(remote.client, remote.resourceType, provision.WithDeletes(true))
+	plan, err := e.Plan(ctx, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 0 || len(plan.Invalid) != 1 {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+}
+
+func TestEngine_rateLimit(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []time.Time
+	)
+	remote := newTestRemote(t, func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		mu.Lock()
+		requests = append(requests, time.Now())
+		mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+	// Only the requests of the engine are rate limited.
+	mu.Lock()
+	requests = nil
+	mu.Unlock()
+
+	var source []scim.Resource
+	for _, userName := range []string{"a", "b", "c", "d"} {
+		source = append(source, newTestResource("", scim.ResourceAttributes{"userName": userName}))
+	}
+	report, err := provision.NewEngine(remote.client, remote.resourceType, provision.WithRateLimit(50)).Sync(context.Background(), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(provision.ActionCreate) != 4 {
+		t.Errorf("unexpected report: %s", report)
+	}
+	for i := 1; i < len(requests); i++ {
+		if d := requests[i].Sub(requests[i-1]); d < 15*time.Millisecond {
+			t.Errorf("requests %d and %d are only %s apart", i-1, i, d)
+		}
+	}
+}
+
+func TestEngine_unreturnedAttributes(t *testing.T) {
+	ctx := context.Background()
+	remote := newTestRemote(t, nil)
+	source := []scim.Resource{newTestResource("e1", scim.ResourceAttributes{
+		"userName":    "alice",
+		"password":    "secret",
+		testExtension: map[string]interface{}{"badge": "b1", "pin": "1234"},
+	})}
+
+	e := provision.NewEngine(remote.client, remote.resourceType)
+	report, err := e.Sync(ctx, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := report.String(); s != "created 1, patched 0, deleted 0, unchanged 0, failed 0, invalid 0" {
+		t.Errorf("unexpected report: %s", s)
+	}
+
+	// The password and the pin are never returned, so they can not be compared.
+	plan, err := e.Plan(ctx, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 0 || plan.Unchanged != 1 {
+		t.Errorf("unexpected plan: %+v", plan.Actions)
+	}
+}
+
+type testRemote struct {
+	client       *client.Client
+	handler      *memory.Handler
+	resourceType scim.ResourceType
+}
+
+func newTestRemote(t *testing.T, middleware func(w http.ResponseWriter, r *http.Request, next http.Handler)) testRemote {
+	resourceType := scim.ResourceType{
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema:   schema.CoreUserSchema(),
+		SchemaExtensions: []scim.SchemaExtension{
+			{Schema: schema.Schema{
+				ID:   testExtension,
+				Name: optional.NewString("Test"),
+				Attributes: []schema.CoreAttribute{
+					schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{Name: "badge"})),
+					schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+						Name:       "pin",
+						Mutability: schema.AttributeMutabilityWriteOnly(),
+						Returned:   schema.AttributeReturnedNever(),
+					})),
+				},
+			}},
+		},
+	}
+	h := memory.NewHandler(resourceType)
+	resourceType.Handler = h
+
+	s, err := scim.NewServer(&scim.ServerArgs{
+		ServiceProviderConfig: &scim.ServiceProviderConfig{MaxResults: 2, SupportFiltering: true, SupportPatch: true},
+		ResourceTypes:         []scim.ResourceType{resourceType},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var handler http.Handler = s
+	if middleware != nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			middleware(w, r, s)
+		})
+	}
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	c := client.NewClient(ts.URL, client.WithHTTPClient(ts.Client()))
+	d, err := c.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	discovered, _ := d.ResourceType("User")
+	return testRemote{client: c, handler: h, resourceType: discovered}
+}
+
+func newTestResource(externalID string, attributes scim.ResourceAttributes) scim.Resource {
+	r := scim.Resource{Attributes: attributes}
+	if externalID != "" {
+		r.ExternalID = optional.NewString(externalID)
+	}
+	return r
+}
+
+func writeTestError(w http.ResponseWriter, status int) {
+	raw, _ := errors.ScimError{Status: status}.MarshalJSON()
+	w.WriteHeader(status)
+	_, _ = w.Write(raw)
+}
@@ -0,0 +1,123 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/elimity-com/scim"
+)
+
+const (
+	// ActionCreate creates a resource at the service provider.
+	ActionCreate ActionType = "create"
+	// ActionPatch patches a resource at the service provider that was matched with a source resource.
+	ActionPatch ActionType = "patch"
+	// ActionDelete deletes a resource at the service provider that was not matched with any source resource.
+	ActionDelete ActionType = "delete"
+)
+
+// Action is a planned change to a resource at the service provider.
+type Action struct {
+	// Type is the type of the action.
+	Type ActionType
+	// ID is the identifier of the resource at the service provider. It is empty for ActionCreate.
+	ID string
+	// Match is the filter that identifies the source resource at the service provider, e.g., `externalId eq "e1"`.
+	// For ActionCreate, it is used to find the resource if it already exists. It is empty for ActionDelete.
+	Match string
+	// Resource is the source resource, or the resource at the service provider for ActionDelete.
+	Resource scim.Resource
+	// Attributes are the validated attributes of the source resource, including its "externalId".
+	Attributes scim.ResourceAttributes
+	// Operations are the operations of ActionPatch.
+	Operations []scim.PatchOperation
+}
+
+// String returns a short description of the action, e.g., `patch 2819c223 (userName eq "bjensen")`.
+func (a Action) String() string {
+	s := string(a.Type)
+	if a.ID != "" {
+		s += " " + a.ID
+	}
+	if a.Match != "" {
+		s += " (" + a.Match + ")"
+	}
+	return s
+}
+
+// ActionType is the type of an action.
+type ActionType string
+
+// Invalid is a source resource that was not planned, e.g., because it does not conform to the schemas of the resource
+// type or because it matches the same resource as another source resource.
+type Invalid struct {
+	// Resource is the source resource.
+	Resource scim.Resource
+	// Err is the reason why the resource was not planned.
+	Err error
+}
+
+// Plan is the set of actions that reconciles the resources at the service provider with the source resources.
+type Plan struct {
+	// Actions are the actions to execute, in order.
+	Actions []Action
+	// Invalid are the source resources that were not planned.
+	Invalid []Invalid
+	// Unchanged is the number of source resources that match a resource at the service provider without changes.
+	Unchanged int
+}
+
+// Report is the reconciliation report of a plan that was applied.
+type Report struct {
+	// Results are the results of the actions of the plan, in order.
+	Results []Result
+	// Invalid are the source resources that were not planned.
+	Invalid []Invalid
+	// Unchanged is the number of source resources that were already up to date.
+	Unchanged int
+}
+
+// Count returns the number of actions of the given type that succeeded.
+func (r Report) Count(t ActionType) int {
+	var n int
+	for _, result := range r.Results {
+		if result.Err == nil && result.Action.Type == t {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns the results of the actions that failed.
+func (r Report) Failed() []Result {
+	var failed []Result
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// String returns a summary of the report, e.g., "created 1, patched 2, deleted 0, unchanged 5, failed 0, invalid 0".
+func (r Report) String() string {
+	return fmt.Sprintf(
+		"created %d, patched %d, deleted %d, unchanged %d, failed %d, invalid %d",
+		r.Count(ActionCreate), r.Count(ActionPatch), r.Count(ActionDelete), r.Unchanged, len(r.Failed()), len(r.Invalid),
+	)
+}
+
+// Result is the result of an action.
+type Result struct {
+	// Action is the executed action.
+	Action Action
+	// ID is the identifier of the resource at the service provider, which is assigned by the service provider for
+	// ActionCreate.
+	ID string
+	// Attempts is the number of requests that were sent for the action, including retries.
+	Attempts int
+	// Matched reports whether the resource of ActionCreate already existed at the service provider, e.g., because a
+	// previous attempt succeeded without a response, and was matched and patched instead.
+	Matched bool
+	// Err is the error of the action, if it failed.
+	Err error
+}
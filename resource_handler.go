@@ -9,20 +9,63 @@ import (
 	"github.com/elimity-com/scim/schema"
 )
 
+// CursorPaginator is an optional interface that a ResourceHandler can implement to support cursor-based pagination as
+// described in RFC 9865. It is used for list and search requests that contain a "cursor" parameter, if the service
+// provider config enables cursor pagination.
+type CursorPaginator interface {
+	// GetAllWithCursor returns the page of resources that starts at the opaque ListRequestParams.Cursor, which is
+	// empty for the first page. The returned page contains the cursors of the next and previous pages, if any. An
+	// invalid or expired cursor should result in an errors.ScimErrorInvalidCursor or errors.ScimErrorExpiredCursor.
+	GetAllWithCursor(r *http.Request, params ListRequestParams) (Page, error)
+}
+
 // ListRequestParams request parameters sent to the API via a "GetAll" route.
 type ListRequestParams struct {
+	// Attributes is a list of attribute names to return in the response, given by the "attributes" query parameter.
+	// The server applies the projection to the returned resources, it is provided so that handlers can avoid fetching
+	// unneeded attributes.
+	Attributes []string
+
 	// Count specifies the desired maximum number of query results per page. A negative value SHALL be interpreted as "0".
 	// A value of "0" indicates that no resource results are to be returned except for "totalResults".
 	Count int
 
+	// Cursor is the opaque cursor of the requested page when using cursor-based pagination. It is only used by
+	// CursorPaginator and is empty for the first page.
+	Cursor string
+
+	// ExcludedAttributes is a list of attribute names to exclude from the response, given by the "excludedAttributes"
+	// query parameter.
+	ExcludedAttributes []string
+
 	// FilterValidator represents the parsed and tokenized filter query parameter.
 	// It is an optional parameter and thus will be nil when the parameter is not present.
 	FilterValidator *filter.Validator
 
+	// SortBy specifies the attribute whose value will be used to order the returned responses, given by the "sortBy"
+	// query parameter. It is validated against the schemas of the resource type. ResourceType.SortResources can be used
+	// to sort resources accordingly.
+	SortBy string
+
+	// SortOrder specifies the order in which the SortBy parameter is applied, either "ascending" or "descending". It
+	// defaults to "ascending" if SortBy is present.
+	SortOrder string
+
 	// StartIndex The 1-based index of the first query result. A value less than 1 SHALL be interpreted as 1.
 	StartIndex int
 }
 
+// MeResolver represents an optional callback that resolves the "/Me" alias to the resource of the authenticated subject
+// of a request. Per RFC 7644 Section 3.11, the "/Me" alias can be used with GET, PUT, PATCH and DELETE to manage the
+// resource of the subject and with POST to create it.
+type MeResolver interface {
+	// ResolveMe returns the name of the resource type and the identifier of the resource that corresponds with the
+	// authenticated subject of the given request. The identifier is ignored for POST requests and may be empty if the
+	// subject has no resource yet. An error is returned if the subject could not be resolved, e.g., a 401 SCIM error
+	// if the request is not authenticated.
+	ResolveMe(r *http.Request) (resourceType string, id string, err error)
+}
+
 // Meta represents the metadata of a resource.
 type Meta struct {
 	// Created is the time that the resource was added to the service provider.
@@ -103,6 +146,13 @@ type ResourceHandler interface {
 	Replace(r *http.Request, id string, attributes ResourceAttributes) (Resource, error)
 	// Delete removes the resource with corresponding ID.
 	Delete(r *http.Request, id string) error
+}
+
+// ResourcePatcher is an optional interface that a ResourceHandler can implement to handle PATCH requests itself. If a
+// handler does not implement it, the server applies the operations to the resource returned by Get, validates the
+// result against the schemas of the resource type and stores it with Replace. The version of the fetched resource is
+// available to Replace through ExpectedVersion, so that conflicting modifications can be detected.
+type ResourcePatcher interface {
 	// Patch update one or more attributes of a SCIM resource using a sequence of
 	// operations to "add", "remove", or "replace" values.
 	// If you return no Resource.Attributes, a 204 No Content status code will be returned.
@@ -136,11 +186,7 @@ type ResourceTypeFilter struct {
 func ValidateFilterForResourceTypes(rawFilter string, resourceTypes []ResourceType) []ResourceTypeFilter {
 	var results []ResourceTypeFilter
 	for _, rt := range resourceTypes {
-		s := rt.Schema
-		attrs := make([]schema.CoreAttribute, len(s.Attributes), len(s.Attributes)+len(schema.CommonAttributes()))
-		copy(attrs, s.Attributes)
-		s.Attributes = append(attrs, schema.CommonAttributes()...)
-		v, err := filter.NewValidator(rawFilter, s, rt.getSchemaExtensions()...)
+		v, err := filter.NewValidator(rawFilter, schema.WithCommonAttributes(rt.Schema), rt.getSchemaExtensions()...)
 		if err != nil {
 			return nil
 		}
@@ -159,8 +205,8 @@ func ValidateFilterForResourceTypes(rawFilter string, resourceTypes []ResourceTy
 // Per RFC 7644 Section 3.4.2.1, a query against the server root indicates that all resources within the server
 // shall be included, subject to filtering.
 //
-// The server does not validate or parse the filter for root queries because there is no single target schema.
-// ListRequestParams.FilterValidator will always be nil for root queries. The raw filter string can be
+// The server does not validate the filter for root queries because there is no single target schema, it only rejects
+// filters that exceed the FilterLimits. ListRequestParams.FilterValidator will always be nil for root queries. The raw filter string can be
 // obtained from the request via r.URL.Query().Get("filter"). The handler is responsible for interpreting
 // the filter (e.g. meta.resourceType eq "User") as appropriate for its backing store.
 type RootQueryHandler interface {
@@ -39,6 +39,33 @@ type ResourceType struct {
 	Handler ResourceHandler
 }
 
+// Validate validates the given attributes against the schemas of the resource type, in the same way as the attributes
+// of a POST or PUT request. The "schemas" attribute is derived from the schema extensions that are present. It returns
+// the validated attributes, of which the values are converted to the types of their attributes, or a SCIM error.
+func (t ResourceType) Validate(attributes ResourceAttributes) (ResourceAttributes, error) {
+	m := make(map[string]interface{}, len(attributes)+1)
+	for k, v := range attributes {
+		m[k] = v
+	}
+	schemas := []interface{}{t.Schema.ID}
+	for _, extension := range t.SchemaExtensions {
+		if _, ok := findKey(m, extension.Schema.ID); ok {
+			schemas = append(schemas, extension.Schema.ID)
+		}
+	}
+	m["schemas"] = schemas
+
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	validated, scimErr := t.validate(raw)
+	if scimErr != nil {
+		return nil, *scimErr
+	}
+	return validated, nil
+}
+
 func (t ResourceType) getRaw() map[string]interface{} {
 	return map[string]interface{}{
 		"schemas":          []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
@@ -67,6 +67,16 @@ func SchemasAttributes() CoreAttribute {
 	}))
 }
 
+// WithCommonAttributes returns a copy of the given schema that also contains the common attributes, e.g., to validate
+// filters that refer to "id" or "meta". The attributes of the given schema are not modified.
+func WithCommonAttributes(s Schema) Schema {
+	common := CommonAttributes()
+	attrs := make([]CoreAttribute, len(s.Attributes), len(s.Attributes)+len(common))
+	copy(attrs, s.Attributes)
+	s.Attributes = append(attrs, common...)
+	return s
+}
+
 // Unlike other core resources, the Schema" resource MAY contain a complex object within a sub-attribute.
 func schemaAttributes(subAttrs bool) []CoreAttribute {
 	attributes := []CoreAttribute{
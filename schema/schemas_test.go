@@ -48,3 +48,20 @@ func TestDefaultSchemas(t *testing.T) {
 		}
 	}
 }
+
+func TestWithCommonAttributes(t *testing.T) {
+	s := CoreGroupSchema()
+	s.Attributes = s.Attributes[:1:len(s.Attributes)]
+
+	common := WithCommonAttributes(s)
+	if len(common.Attributes) != 1+len(CommonAttributes()) {
+		t.Fatalf("unexpected number of attributes: %d", len(common.Attributes))
+	}
+	if _, ok := common.Attributes.ContainsAttribute("meta"); !ok {
+		t.Error("expected the common attribute meta")
+	}
+	// The spare capacity of the given attributes is not written to.
+	if name := CoreGroupSchema().Attributes[1].Name(); s.Attributes[:2][1].Name() != name {
+		t.Errorf("the given attributes were modified: %s", s.Attributes[:2][1].Name())
+	}
+}
@@ -13,29 +13,19 @@ import (
 )
 
 const (
-	defaultStartIndex = 1
-	fallbackCount     = 100
+	defaultStartIndex          = 1
+	fallbackBulkMaxOperations  = 1000
+	fallbackBulkMaxPayloadSize = 1048576
+	fallbackCount              = 100
 )
 
 // getFilter returns a validated filter if present in the url query, nil otherwise.
-func getFilterValidator(r *http.Request, s schema.Schema, extensions ...schema.Schema) (*filter.Validator, error) {
+func getFilterValidator(r *http.Request, limits FilterLimits, s schema.Schema, extensions ...schema.Schema) (*filter.Validator, *errors.ScimError) {
 	f := strings.TrimSpace(r.URL.Query().Get("filter"))
 	if f == "" {
 		return nil, nil // No filter present.
 	}
-
-	withCommon := s
-	attrs := make([]schema.CoreAttribute, len(s.Attributes), len(s.Attributes)+len(schema.CommonAttributes()))
-	copy(attrs, s.Attributes)
-	withCommon.Attributes = append(attrs, schema.CommonAttributes()...)
-	validator, err := filter.NewValidator(f, withCommon, extensions...)
-	if err != nil {
-		return nil, err
-	}
-	if err := validator.Validate(); err != nil {
-		return nil, err
-	}
-	return &validator, nil
+	return newFilterValidator(f, limits, s, extensions...)
 }
 
 func getIntQueryParam(r *http.Request, key string, def int) (int, error) {
@@ -52,6 +42,25 @@ func getIntQueryParam(r *http.Request, key string, def int) (int, error) {
 	return 0, fmt.Errorf("invalid query parameter, \"%s\" must be an integer", key)
 }
 
+// newFilterValidator returns a validator of the given filter, that is validated against the given schema (extended with
+// the common attributes) and checked against the given limits.
+func newFilterValidator(f string, limits FilterLimits, s schema.Schema, extensions ...schema.Schema) (*filter.Validator, *errors.ScimError) {
+	if scimErr := limits.checkText(f); scimErr != nil {
+		return nil, scimErr
+	}
+	validator, err := filter.NewValidator(f, schema.WithCommonAttributes(s), extensions...)
+	if err != nil {
+		return nil, &errors.ScimErrorInvalidFilter
+	}
+	if scimErr := limits.checkComplexity(validator.GetFilter()); scimErr != nil {
+		return nil, scimErr
+	}
+	if err := validator.Validate(); err != nil {
+		return nil, &errors.ScimErrorInvalidFilter
+	}
+	return &validator, nil
+}
+
 func parseIdentifier(path, endpoint string) (string, error) {
 	return url.PathUnescape(strings.TrimPrefix(path, endpoint+"/"))
 }
@@ -75,8 +84,10 @@ type Server struct {
 	config           ServiceProviderConfig
 	resourceTypes    []ResourceType
 	rootQueryHandler RootQueryHandler
+	meResolver       MeResolver
 	log              Logger
 	baseURL          string
+	filterLimits     FilterLimits
 }
 
 func NewServer(args *ServerArgs, opts ...ServerOption) (Server, error) {
@@ -110,7 +121,7 @@ func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/scim+json")
 	w = &statusResponseWriter{ResponseWriter: w}
 
-	path := strings.TrimPrefix(r.URL.Path, "/v2")
+	path := s.routePath(r.URL.Path)
 
 	switch {
 	case (path == "/" || path == "") && r.Method == http.MethodGet:
@@ -131,10 +142,28 @@ func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		s.rootSearchHandler(w, r)
 		return
+	case path == "/Bulk":
+		if r.Method != http.MethodPost {
+			s.errorHandler(w, &errors.ScimError{Status: http.StatusMethodNotAllowed})
+			return
+		}
+		if !s.config.SupportBulk {
+			s.errorHandler(w, &errors.ScimError{
+				Detail: "Bulk operations are not supported.",
+				Status: http.StatusNotImplemented,
+			})
+			return
+		}
+		s.bulkHandler(w, r)
+		return
 	case path == "/Me":
-		s.errorHandler(w, &errors.ScimError{
-			Status: http.StatusNotImplemented,
-		})
+		if s.meResolver == nil {
+			s.errorHandler(w, &errors.ScimError{
+				Status: http.StatusNotImplemented,
+			})
+			return
+		}
+		s.meHandler(w, r)
 		return
 	case path == "/Schemas" && r.Method == http.MethodGet:
 		s.schemasHandler(w, r)
@@ -284,18 +313,47 @@ func (s Server) parseRequestParams(r *http.Request, refSchema schema.Schema, ref
 		return ListRequestParams{}, scimErr
 	}
 
-	validator, err := getFilterValidator(r, refSchema, refExtensions...)
-	if err != nil {
-		return ListRequestParams{}, &errors.ScimErrorInvalidFilter
+	validator, scimErr := getFilterValidator(r, s.filterLimits, refSchema, refExtensions...)
+	if scimErr != nil {
+		return ListRequestParams{}, scimErr
+	}
+
+	query := r.URL.Query()
+	resourceType := ResourceType{Schema: refSchema}
+	for _, extension := range refExtensions {
+		resourceType.SchemaExtensions = append(resourceType.SchemaExtensions, SchemaExtension{Schema: extension})
+	}
+	sortBy, sortOrder, scimErr := parseSortParams(&resourceType, query.Get("sortBy"), query.Get("sortOrder"))
+	if scimErr != nil {
+		return ListRequestParams{}, scimErr
 	}
 
 	return ListRequestParams{
-		Count:           count,
-		FilterValidator: validator,
-		StartIndex:      startIndex,
+		Attributes:         splitAttributeList(query.Get("attributes")),
+		Count:              count,
+		ExcludedAttributes: splitAttributeList(query.Get("excludedAttributes")),
+		FilterValidator:    validator,
+		SortBy:             sortBy,
+		SortOrder:          sortOrder,
+		StartIndex:         startIndex,
 	}, nil
 }
 
+// routePath returns the given request path relative to the root of the service provider, i.e., without the path of
+// the base URL or the version prefix.
+func (s Server) routePath(path string) string {
+	roots := []string{"/v2"}
+	if u, err := url.Parse(s.baseURL); err == nil && u.Path != "" {
+		roots = append([]string{u.Path}, roots...)
+	}
+	for _, root := range roots {
+		if path == root || strings.HasPrefix(path, root+"/") {
+			return strings.TrimPrefix(path, root)
+		}
+	}
+	return path
+}
+
 type ServerArgs struct {
 	ServiceProviderConfig *ServiceProviderConfig
 	ResourceTypes         []ResourceType
@@ -312,6 +370,15 @@ func WithBaseURL(baseURL string) ServerOption {
 	}
 }
 
+// WithFilterLimits limits the complexity of the filters that the server accepts in list and search requests, including
+// queries across all resource types. Without this option, the default limits of FilterLimits apply. Per RFC 7644
+// Section 3.4.2.2, filters that exceed the limits are rejected with a "tooMany" error.
+func WithFilterLimits(limits FilterLimits) ServerOption {
+	return func(s *Server) {
+		s.filterLimits = limits
+	}
+}
+
 // WithLogger sets the logger for the server.
 func WithLogger(logger Logger) ServerOption {
 	return func(s *Server) {
@@ -321,6 +388,16 @@ func WithLogger(logger Logger) ServerOption {
 	}
 }
 
+// WithMeResolver sets the resolver that maps the authenticated subject of a request to its resource, which enables the
+// "/Me" alias. Per RFC 7644 Section 3.11, requests to "/Me" are handled as if they were made to the resource itself.
+func WithMeResolver(r MeResolver) ServerOption {
+	return func(s *Server) {
+		if r != nil {
+			s.meResolver = r
+		}
+	}
+}
+
 // WithRootQueryHandler sets a handler for queries against the server root endpoint (GET /).
 // Per RFC 7644 Section 3.4.2.1, a query against the server root indicates that all resources
 // within the server shall be included, subject to filtering.
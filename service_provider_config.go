@@ -45,12 +45,48 @@ type ServiceProviderConfig struct {
 	DocumentationURI optional.String
 	// AuthenticationSchemes is a multi-valued complex type that specifies supported authentication scheme properties.
 	AuthenticationSchemes []AuthenticationScheme
+	// CursorTimeout is the number of seconds during which a cursor is guaranteed to remain valid. It is only advertised
+	// if cursor pagination is supported and the value is positive.
+	CursorTimeout int
+	// MaxBulkOperations is the maximum number of operations in a bulk request. It defaults to 1000.
+	MaxBulkOperations int
+	// MaxBulkPayloadSize is the maximum payload size of a bulk request in bytes. It defaults to 1048576.
+	MaxBulkPayloadSize int
 	// MaxResults denotes the the integer value specifying the maximum number of resources returned in a response. It defaults to 100.
 	MaxResults int
+	// SupportBulk whether your SCIM implementation will support bulk operations on the "/Bulk" endpoint.
+	SupportBulk bool
+	// SupportCursorPagination whether your SCIM implementation will support cursor-based pagination (RFC 9865). The
+	// resource handlers need to implement CursorPaginator.
+	SupportCursorPagination bool
+	// SupportETag whether your SCIM implementation will support entity tags. If enabled, the "If-Match" and
+	// "If-None-Match" preconditions of requests are evaluated against the version of the targeted resource.
+	SupportETag bool
 	// SupportFiltering whether you SCIM implementation will support filtering.
 	SupportFiltering bool
 	// SupportPatch whether your SCIM implementation will support patch requests.
 	SupportPatch bool
+	// SupportSorting whether your SCIM implementation will support sorting with the "sortBy" and "sortOrder"
+	// parameters.
+	SupportSorting bool
+}
+
+// getBulkMaxOperations retrieves the configured maximum number of bulk operations. It falls back to 1000 when not
+// configured.
+func (config ServiceProviderConfig) getBulkMaxOperations() int {
+	if config.MaxBulkOperations < 1 {
+		return fallbackBulkMaxOperations
+	}
+	return config.MaxBulkOperations
+}
+
+// getBulkMaxPayloadSize retrieves the configured maximum payload size of a bulk request. It falls back to 1048576 bytes
+// when not configured.
+func (config ServiceProviderConfig) getBulkMaxPayloadSize() int {
+	if config.MaxBulkPayloadSize < 1 {
+		return fallbackBulkMaxPayloadSize
+	}
+	return config.MaxBulkPayloadSize
 }
 
 // getItemsPerPage retrieves the configured default count. It falls back to 100 when not configured.
@@ -69,9 +105,9 @@ func (config ServiceProviderConfig) getRaw() map[string]interface{} {
 			"supported": config.SupportPatch,
 		},
 		"bulk": map[string]interface{}{
-			"supported":      false,
-			"maxOperations":  1000,
-			"maxPayloadSize": 1048576,
+			"supported":      config.SupportBulk,
+			"maxOperations":  config.getBulkMaxOperations(),
+			"maxPayloadSize": config.getBulkMaxPayloadSize(),
 		},
 		"filter": map[string]interface{}{
 			"supported":  config.SupportFiltering,
@@ -81,15 +117,30 @@ func (config ServiceProviderConfig) getRaw() map[string]interface{} {
 			"supported": false,
 		},
 		"sort": map[string]bool{
-			"supported": false,
+			"supported": config.SupportSorting,
 		},
 		"etag": map[string]bool{
-			"supported": false,
+			"supported": config.SupportETag,
 		},
+		"pagination":            config.getRawPagination(),
 		"authenticationSchemes": config.getRawAuthenticationSchemes(),
 	}
 }
 
+func (config ServiceProviderConfig) getRawPagination() map[string]interface{} {
+	pagination := map[string]interface{}{
+		"cursor":                  config.SupportCursorPagination,
+		"index":                   true,
+		"defaultPaginationMethod": "index",
+		"defaultPageSize":         config.getItemsPerPage(),
+		"maxPageSize":             config.getItemsPerPage(),
+	}
+	if config.SupportCursorPagination && config.CursorTimeout > 0 {
+		pagination["cursorTimeout"] = config.CursorTimeout
+	}
+	return pagination
+}
+
 func (config ServiceProviderConfig) getRawAuthenticationSchemes() []map[string]interface{} {
 	rawAuthScheme := make([]map[string]interface{}, 0)
 	for _, auth := range config.AuthenticationSchemes {
@@ -0,0 +1,292 @@
+package scim
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/schema"
+)
+
+const (
+	// SortOrderAscending sorts the resources in ascending order, which is the default sort order.
+	SortOrderAscending = "ascending"
+	// SortOrderDescending sorts the resources in descending order.
+	SortOrderDescending = "descending"
+)
+
+// compareValues compares two values of the given attribute. Values are ordered according to the type of the
+// attribute, strings are compared case-insensitively unless the attribute is case exact. Missing values are ordered
+// after all other values.
+func compareValues(attr schema.CoreAttribute, a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	switch attr.AttributeType() {
+	case "boolean":
+		if x, ok := a.(bool); ok {
+			if y, ok := b.(bool); ok {
+				switch {
+				case x == y:
+					return 0
+				case !x:
+					return -1
+				default:
+					return 1
+				}
+			}
+		}
+	case "dateTime":
+		if x, ok := toTime(a); ok {
+			if y, ok := toTime(b); ok {
+				switch {
+				case x.Before(y):
+					return -1
+				case x.After(y):
+					return 1
+				default:
+					return 0
+				}
+			}
+		}
+	case "decimal", "integer":
+		if x, ok := toFloat(a); ok {
+			if y, ok := toFloat(b); ok {
+				switch {
+				case x < y:
+					return -1
+				case x > y:
+					return 1
+				default:
+					return 0
+				}
+			}
+		}
+	}
+
+	x, y := fmt.Sprint(a), fmt.Sprint(b)
+	if !attr.CaseExact() {
+		x, y = strings.ToLower(x), strings.ToLower(y)
+	}
+	return strings.Compare(x, y)
+}
+
+// lookupAttribute returns the value of the attribute with the given name, attribute names are case-insensitive.
+func lookupAttribute(value interface{}, name string) interface{} {
+	var values map[string]interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		values = v
+	case ResourceAttributes:
+		values = v
+	default:
+		return nil
+	}
+
+	if v, ok := values[name]; ok {
+		return v
+	}
+	for k, v := range values {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// parseSortParams validates the given sortBy and sortOrder parameters of a query and returns them, the sort order
+// defaults to "ascending" if an attribute to sort by is given. The attribute is validated against the schemas of the
+// given resource type with ResourceComparator, it is not validated if no resource type is given, e.g., for queries
+// across all resource types.
+func parseSortParams(resourceType *ResourceType, sortBy, sortOrder string) (string, string, *errors.ScimError) {
+	if sortOrder != "" && sortOrder != SortOrderAscending && sortOrder != SortOrderDescending {
+		scimErr := errors.ScimErrorBadParams([]string{"sortOrder"})
+		return "", "", &scimErr
+	}
+	sortBy = strings.TrimSpace(sortBy)
+	if sortBy == "" {
+		return "", sortOrder, nil
+	}
+	if resourceType != nil {
+		if _, err := resourceType.ResourceComparator(sortBy, sortOrder); err != nil {
+			scimErr := errors.ScimErrorBadParams([]string{"sortBy"})
+			return "", "", &scimErr
+		}
+	}
+	if sortOrder == "" {
+		sortOrder = SortOrderAscending
+	}
+	return sortBy, sortOrder, nil
+}
+
+// primaryValue returns the value of a multi-valued attribute that is marked as primary. If there is no primary value,
+// the first value is returned.
+func primaryValue(value interface{}) interface{} {
+	var values []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		values = v
+	case []map[string]interface{}:
+		for _, m := range v {
+			values = append(values, m)
+		}
+	default:
+		return value
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	for _, v := range values {
+		if primary, ok := lookupAttribute(v, "primary").(bool); ok && primary {
+			return v
+		}
+	}
+	return values[0]
+}
+
+// toFloat converts the given numeric value to a float.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// toTime converts the given time or RFC 3339 formatted string to a time.
+func toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, false
+		}
+		return *v, true
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+// ResourceComparator returns a function that compares two resources by the attribute with the given path, e.g.,
+// "userName", "name.familyName" or "meta.lastModified", as described in RFC 7644 Section 3.4.2.3. The function
+// returns a negative number if the first resource is ordered before the second one, a positive number if it is ordered
+// after the second one and zero otherwise. Multi-valued attributes are compared by their primary value, or their
+// first value if none is primary. Resources without a value are ordered last in ascending order and first in
+// descending order. An error is returned if the path is not valid within the schemas of the resource type or if the
+// sort order is neither "ascending" nor "descending". An empty sort order defaults to "ascending".
+func (t ResourceType) ResourceComparator(sortBy, sortOrder string) (func(a, b Resource) int, error) {
+	if sortOrder != "" && sortOrder != SortOrderAscending && sortOrder != SortOrderDescending {
+		return nil, fmt.Errorf("invalid sort order: %s", sortOrder)
+	}
+
+	path, attr, err := filter.ValidateAttributePath(sortBy, schema.WithCommonAttributes(t.Schema), t.getSchemaExtensions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	valueAttr := attr
+	if subAttrName := path.SubAttributeName(); subAttrName != "" {
+		valueAttr, _ = attr.SubAttributes().ContainsAttribute(subAttrName)
+	} else if attr.MultiValued() && attr.HasSubAttributes() {
+		if value, ok := attr.SubAttributes().ContainsAttribute("value"); ok {
+			valueAttr = value
+		}
+	}
+
+	uri, subAttrName := path.URI(), path.SubAttributeName()
+	compare := func(a, b Resource) int {
+		return compareValues(valueAttr, t.sortValue(a, attr, uri, subAttrName), t.sortValue(b, attr, uri, subAttrName))
+	}
+	if sortOrder == SortOrderDescending {
+		return func(a, b Resource) int {
+			return -compare(a, b)
+		}, nil
+	}
+	return compare, nil
+}
+
+// SortResources sorts the given resources by the attribute with the given path and in the given order. The sort is
+// stable. See ResourceComparator for more information on the ordering of the resources.
+func (t ResourceType) SortResources(resources []Resource, sortBy, sortOrder string) error {
+	compare, err := t.ResourceComparator(sortBy, sortOrder)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		return compare(resources[i], resources[j]) < 0
+	})
+	return nil
+}
+
+// sortValue returns the value of the given resource to sort on, i.e., the value of the given attribute of the schema
+// with the given uri and, if not empty, its sub-attribute with the given name.
+func (t ResourceType) sortValue(r Resource, attr schema.CoreAttribute, uri, subAttrName string) interface{} {
+	var value interface{}
+	if uri != "" && uri != t.Schema.ID {
+		value = lookupAttribute(lookupAttribute(r.Attributes, uri), attr.Name())
+	} else {
+		switch strings.ToLower(attr.Name()) {
+		case schema.CommonAttributeID:
+			return r.ID
+		case strings.ToLower(schema.CommonAttributeExternalID):
+			if r.ExternalID.Present() {
+				return r.ExternalID.Value()
+			}
+		case schema.CommonAttributeMeta:
+			switch strings.ToLower(subAttrName) {
+			case "created":
+				if r.Meta.Created != nil {
+					return *r.Meta.Created
+				}
+				return nil
+			case "lastmodified":
+				if r.Meta.LastModified != nil {
+					return *r.Meta.LastModified
+				}
+				return nil
+			case "version":
+				if r.Meta.Version != "" {
+					return r.Meta.Version
+				}
+				return nil
+			case "resourcetype":
+				return t.Name
+			}
+		}
+		value = lookupAttribute(r.Attributes, attr.Name())
+	}
+
+	if attr.MultiValued() {
+		value = primaryValue(value)
+	}
+	if subAttrName != "" {
+		return lookupAttribute(value, subAttrName)
+	}
+	if attr.MultiValued() && attr.HasSubAttributes() {
+		return lookupAttribute(value, "value")
+	}
+	return value
+}
@@ -0,0 +1,275 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+)
+
+func TestResourceTypeSortResources(t *testing.T) {
+	resourceType := ResourceType{
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema: schema.Schema{
+			ID: "urn:ietf:params:scim:schemas:core:2.0:User",
+			Attributes: []schema.CoreAttribute{
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					Name: "userName",
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					CaseExact: true,
+					Name:      "code",
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleNumberParams(schema.NumberParams{
+					Name: "age",
+					Type: schema.AttributeTypeInteger(),
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleNumberParams(schema.NumberParams{
+					Name: "score",
+					Type: schema.AttributeTypeDecimal(),
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleBooleanParams(schema.BooleanParams{
+					Name: "active",
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleDateTimeParams(schema.DateTimeParams{
+					Name: "hired",
+				})),
+				schema.ComplexCoreAttribute(schema.ComplexParams{
+					Name:        "emails",
+					MultiValued: true,
+					SubAttributes: []schema.SimpleParams{
+						schema.SimpleStringParams(schema.StringParams{Name: "value"}),
+						schema.SimpleBooleanParams(schema.BooleanParams{Name: "primary"}),
+					},
+				}),
+			},
+		},
+		SchemaExtensions: []SchemaExtension{
+			{Schema: schema.ExtensionEnterpriseUser()},
+		},
+	}
+
+	var (
+		early = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		late  = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+	newResources := func() []Resource {
+		return []Resource{
+			{
+				ID: "a",
+				Attributes: ResourceAttributes{
+					"userName": "bob",
+					"code":     "b",
+					"age":      int64(9),
+					"score":    10.5,
+					"active":   true,
+					"hired":    "2021-01-01T00:00:00Z",
+					"emails": []interface{}{
+						map[string]interface{}{"value": "a@example.com"},
+						map[string]interface{}{"value": "z@example.com", "primary": true},
+					},
+					"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": map[string]interface{}{
+						"employeeNumber": "2",
+					},
+				},
+				Meta: Meta{Created: &late},
+			},
+			{
+				ID: "b",
+				Attributes: ResourceAttributes{
+					"userName": "Alice",
+					"code":     "B",
+					"age":      10,
+					"score":    2.25,
+					"active":   false,
+					"hired":    "2020-06-01T00:00:00+02:00",
+					"emails": []interface{}{
+						map[string]interface{}{"value": "m@example.com"},
+					},
+					"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": map[string]interface{}{
+						"employeeNumber": "1",
+					},
+				},
+				Meta: Meta{Created: &early},
+			},
+			{
+				ID:         "c",
+				Attributes: ResourceAttributes{},
+			},
+		}
+	}
+
+	tests := []struct {
+		sortBy    string
+		sortOrder string
+		expected  []string
+	}{
+		{sortBy: "userName", expected: []string{"b", "a", "c"}},
+		{sortBy: "UserName", sortOrder: SortOrderDescending, expected: []string{"c", "a", "b"}},
+		{sortBy: "code", expected: []string{"b", "a", "c"}},
+		{sortBy: "age", expected: []string{"a", "b", "c"}},
+		{sortBy: "score", expected: []string{"b", "a", "c"}},
+		{sortBy: "active", expected: []string{"b", "a", "c"}},
+		{sortBy: "hired", expected: []string{"b", "a", "c"}},
+		{sortBy: "emails", expected: []string{"b", "a", "c"}},
+		{sortBy: "emails.value", sortOrder: SortOrderDescending, expected: []string{"c", "a", "b"}},
+		{sortBy: "meta.created", expected: []string{"b", "a", "c"}},
+		{sortBy: "id", sortOrder: SortOrderDescending, expected: []string{"c", "b", "a"}},
+		{sortBy: "employeeNumber", expected: []string{"b", "a", "c"}},
+		{sortBy: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber", expected: []string{"b", "a", "c"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.sortBy+" "+test.sortOrder, func(t *testing.T) {
+			resources := newResources()
+			if err := resourceType.SortResources(resources, test.sortBy, test.sortOrder); err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, r := range resources {
+				ids = append(ids, r.ID)
+			}
+			assertEqualStrings(t, test.expected, ids)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, params := range [][2]string{
+			{"unknown", ""},
+			{"userName.value", ""},
+			{"userName", "up"},
+		} {
+			if err := resourceType.SortResources(newResources(), params[0], params[1]); err == nil {
+				t.Errorf("(%s %s) should not be valid", params[0], params[1])
+			}
+		}
+	})
+}
+
+func TestServerSortParams(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "valid",
+			method:         http.MethodGet,
+			target:         "/Users?sortBy=name.familyName&sortOrder=descending",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid sortBy",
+			method:         http.MethodGet,
+			target:         "/Users?sortBy=unknown",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid sortOrder",
+			method:         http.MethodGet,
+			target:         "/Users?sortBy=userName&sortOrder=up",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid search sortBy",
+			method:         http.MethodPost,
+			target:         "/Users/.search",
+			body:           `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"], "sortBy": "unknown"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid search sortOrder",
+			method:         http.MethodPost,
+			target:         "/Users/.search",
+			body:           `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"], "sortBy": "userName", "sortOrder": "up"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServerWithSearchHandler(t)
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			rr := httptest.NewRecorder()
+			s.ServeHTTP(rr, req)
+			assertEqualStatusCode(t, test.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestServerSortParamsPassed(t *testing.T) {
+	var captured ListRequestParams
+	s, err := NewServer(
+		&ServerArgs{
+			ServiceProviderConfig: &ServiceProviderConfig{SupportSorting: true},
+			ResourceTypes: []ResourceType{
+				{
+					ID:       optional.NewString("User"),
+					Name:     "User",
+					Endpoint: "/Users",
+					Schema:   getUserSchema(),
+					Handler:  testListParamsHandler{testResourceHandler: newTestResourceHandler().(testResourceHandler), captured: &captured},
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/Users?sortBy=userName", nil)
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+	assertEqual(t, "userName", captured.SortBy)
+	assertEqual(t, SortOrderAscending, captured.SortOrder)
+
+	req = httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil)
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+
+	var config struct {
+		Sort struct {
+			Supported bool
+		}
+	}
+	assertUnmarshalNoError(t, json.Unmarshal(rr.Body.Bytes(), &config))
+	assertTrue(t, config.Sort.Supported)
+}
+
+func TestServerSortParamsSearchDefault(t *testing.T) {
+	s := newTestServerWithSearchHandler(t)
+	captured := &SearchParams{}
+	s.resourceTypes[0].Handler = testSearchHandler{
+		testResourceHandler: newTestResourceHandler().(testResourceHandler),
+		captured:            captured,
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/Users/.search", strings.NewReader(
+		`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"], "sortBy": " userName "}`,
+	))
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	assertEqualStatusCode(t, http.StatusOK, rr.Code)
+	assertEqual(t, "userName", captured.SortBy)
+	assertEqual(t, SortOrderAscending, captured.SortOrder)
+}
+
+// testListParamsHandler records the parameters of the last list request.
+type testListParamsHandler struct {
+	testResourceHandler
+	captured *ListRequestParams
+}
+
+func (h testListParamsHandler) GetAll(r *http.Request, params ListRequestParams) (Page, error) {
+	*h.captured = params
+	return h.testResourceHandler.GetAll(r, params)
+}
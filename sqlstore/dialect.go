@@ -0,0 +1,94 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/elimity-com/scim/filter/sqlfilter"
+)
+
+var (
+	// MySQL is the dialect of MySQL and MariaDB.
+	MySQL = Dialect{
+		Dialect:       sqlfilter.MySQL,
+		ReadIsolation: sql.LevelRepeatableRead,
+		Types: map[string]string{
+			"binary":    "TEXT",
+			"boolean":   "BOOLEAN",
+			"dateTime":  "DATETIME(6)",
+			"decimal":   "DOUBLE",
+			"document":  "LONGTEXT",
+			"integer":   "BIGINT",
+			"key":       "VARCHAR(255)",
+			"reference": "VARCHAR(255)",
+			"string":    "VARCHAR(255)",
+		},
+		UniqueViolation: func(err error) bool {
+			// The error code of a duplicate entry of a unique index, ER_DUP_ENTRY.
+			return strings.Contains(err.Error(), "Error 1062")
+		},
+	}
+	// PostgreSQL is the dialect of PostgreSQL.
+	PostgreSQL = Dialect{
+		Dialect:       sqlfilter.PostgreSQL,
+		ReadIsolation: sql.LevelRepeatableRead,
+		Types: map[string]string{
+			"binary":    "TEXT",
+			"boolean":   "BOOLEAN",
+			"dateTime":  "TIMESTAMP WITH TIME ZONE",
+			"decimal":   "DOUBLE PRECISION",
+			"document":  "TEXT",
+			"integer":   "BIGINT",
+			"key":       "TEXT",
+			"reference": "TEXT",
+			"string":    "TEXT",
+		},
+		UniqueViolation: func(err error) bool {
+			// The SQLSTATE of a unique violation, which is exposed by both lib/pq and pgx.
+			var state interface{ SQLState() string }
+			if errors.As(err, &state) {
+				return state.SQLState() == "23505"
+			}
+			return strings.Contains(err.Error(), "23505")
+		},
+	}
+	// SQLite is the dialect of SQLite.
+	SQLite = Dialect{
+		Dialect: sqlfilter.SQLite,
+		Types: map[string]string{
+			"binary":    "TEXT",
+			"boolean":   "BOOLEAN",
+			"dateTime":  "TIMESTAMP",
+			"decimal":   "REAL",
+			"document":  "TEXT",
+			"integer":   "INTEGER",
+			"key":       "TEXT",
+			"reference": "TEXT",
+			"string":    "TEXT",
+		},
+		UniqueViolation: func(err error) bool {
+			return strings.Contains(err.Error(), "UNIQUE constraint failed")
+		},
+	}
+)
+
+// Dialect is the SQL dialect of the database in which the resources are stored.
+type Dialect struct {
+	// Dialect contains the hooks that are used to translate filters.
+	sqlfilter.Dialect
+	// Types maps the SCIM attribute data types to the column types that are used when creating the tables. The "key"
+	// type is used for the identifiers of the resources and "document" for the JSON documents of the resources.
+	Types map[string]string
+	// ReadIsolation is the isolation level of the read-only transaction in which both the total number of results and
+	// the page of resources of a query are read, which must ensure that both are read from the same snapshot.
+	ReadIsolation sql.IsolationLevel
+	// UniqueViolation is an optional hook that reports whether the given error of an insert or update is caused by a
+	// unique constraint, which is reported as a 409 Conflict SCIM error.
+	UniqueViolation func(err error) bool
+}
+
+// uniqueViolation reports whether the given error is caused by a unique constraint.
+func (d Dialect) uniqueViolation(err error) bool {
+	return err != nil && d.UniqueViolation != nil && d.UniqueViolation(err)
+}
@@ -0,0 +1,629 @@
+package sqlstore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/filter/sqlfilter"
+	"github.com/elimity-com/scim/internal/uuid"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+)
+
+// recordColumns are the columns of a stored resource, as scanned by scanRecords.
+const recordColumns = "id, external_id, revision, created, last_modified, attributes"
+
+// checkModified returns a 412 Precondition Failed SCIM error if the given result did not affect any rows, i.e., if the
+// resource was modified by another transaction.
+func checkModified(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ScimErrorPreconditionFailed
+	}
+	return nil
+}
+
+// normalizeElement converts the numbers of a single decoded value of the given attribute to the types of validated
+// attribute values, i.e., int64 for integers and float64 for decimals.
+func normalizeElement(attr schema.CoreAttribute, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		normalizeValues(attr.SubAttributes(), v)
+	case json.Number:
+		if attr.AttributeType() == "integer" {
+			if i, err := v.Int64(); err == nil {
+				return i
+			}
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+// normalizeValue converts the numbers of the decoded value of the given attribute, see normalizeElement.
+func normalizeValue(attr schema.CoreAttribute, value interface{}) interface{} {
+	values, ok := value.([]interface{})
+	if !attr.MultiValued() || !ok {
+		return normalizeElement(attr, value)
+	}
+	for i, v := range values {
+		values[i] = normalizeElement(attr, v)
+	}
+	return values
+}
+
+// normalizeValues converts the numbers of the given decoded attributes, see normalizeElement.
+func normalizeValues(attrs schema.Attributes, values map[string]interface{}) {
+	for k, v := range values {
+		if attr, ok := attrs.ContainsAttribute(k); ok {
+			values[k] = normalizeValue(attr, v)
+		}
+	}
+}
+
+// requestContext returns the context of the given request.
+func requestContext(r *http.Request) context.Context {
+	if r == nil {
+		return context.Background()
+	}
+	return r.Context()
+}
+
+// scanRecords scans and closes the given rows of stored resources, which contain the recordColumns.
+func scanRecords(rows *sql.Rows) ([]record, error) {
+	defer rows.Close()
+
+	var records []record
+	for rows.Next() {
+		var (
+			stored     record
+			externalID sql.NullString
+		)
+		if err := rows.Scan(
+			&stored.id, &externalID, &stored.revision, &stored.created, &stored.lastModified, &stored.document,
+		); err != nil {
+			return nil, err
+		}
+		if externalID.Valid {
+			stored.externalID = optional.NewString(externalID.String)
+		}
+		records = append(records, stored)
+	}
+	return records, rows.Err()
+}
+
+// splitExternalID returns the given attributes without the external identifier and the external identifier itself, if
+// present.
+func splitExternalID(attributes scim.ResourceAttributes) (map[string]interface{}, optional.String) {
+	values := make(map[string]interface{}, len(attributes))
+	var externalID optional.String
+	for k, v := range attributes {
+		if strings.EqualFold(k, schema.CommonAttributeExternalID) {
+			if s, ok := v.(string); ok {
+				externalID = optional.NewString(s)
+			}
+			continue
+		}
+		values[k] = v
+	}
+	return values, externalID
+}
+
+// Handler stores the resources of a single resource type in a SQL database through database/sql. It implements
+// scim.ResourceHandler and scim.ResourceSearcher, PATCH requests are applied by the server through Get and Replace.
+//
+// Each resource is stored as a JSON document, together with its metadata and an indexed column for each simple
+// singular (sub-)attribute of the schemas of the resource type. The values of multi-valued attributes are stored in
+// child tables, one per attribute. The tables can be created with CreateTables.
+//
+// Every modification runs in its own transaction. Resources get a random UUID as identifier and their version is
+// incremented on every modification. Modifications that carry an expected version (see scim.ExpectedVersion), or that
+// race with another modification, fail with 412 Precondition Failed. Uniqueness of attributes with a "server" or
+// "global" uniqueness is enforced, also by unique constraints, and filters, sorting and pagination are pushed down to the
+// database. The total number of results and the page of a query are read within a single read-only transaction.
+type Handler struct {
+	db           *sql.DB
+	dialect      Dialect
+	resourceType scim.ResourceType
+	layout       layout
+	translator   sqlfilter.Translator
+	newID        func() string
+	now          func() time.Time
+}
+
+// NewHandler returns a handler that stores the resources of the given resource type in the table with the given name,
+// within the given database. The table name and the names of the child tables, which are prefixed by the table name,
+// are embedded in the queries as is. Time columns must be scanned as time.Time, e.g., with "parseTime=true" for MySQL.
+func NewHandler(db *sql.DB, dialect Dialect, table string, resourceType scim.ResourceType, opts ...Option) *Handler {
+	l := newLayout(table, resourceType)
+	var exts []schema.Schema
+	for _, extension := range resourceType.SchemaExtensions {
+		exts = append(exts, extension.Schema)
+	}
+	h := &Handler{
+		db:           db,
+		dialect:      dialect,
+		resourceType: resourceType,
+		layout:       l,
+		translator:   sqlfilter.NewTranslator(dialect.Dialect, l.mapping(), resourceType.Schema, exts...),
+		newID:        uuid.New,
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Create stores the given attributes as a new resource.
+func (h *Handler) Create(r *http.Request, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	values, externalID := splitExternalID(attributes)
+	document, err := json.Marshal(values)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+	now := h.now().UTC()
+	stored := record{
+		id:           h.newID(),
+		externalID:   externalID,
+		revision:     1,
+		created:      now,
+		lastModified: now,
+		document:     string(document),
+	}
+
+	if err := h.transaction(r, nil, func(ctx context.Context, tx *sql.Tx) error {
+		if err := h.checkUniqueness(ctx, tx, "", values); err != nil {
+			return err
+		}
+		columns := []string{"id", "external_id", "revision", "created", "last_modified", "attributes"}
+		args := []interface{}{stored.id, stored.externalIDValue(), stored.revision, now, now, stored.document}
+		for _, c := range h.layout.columns {
+			columns = append(columns, c.name)
+			args = append(args, c.value(values))
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s)",
+			h.layout.table, strings.Join(columns, ", "), h.placeholders(1, len(args)),
+		), args...); err != nil {
+			return h.execError(err)
+		}
+		return h.insertChildren(ctx, tx, stored.id, values)
+	}); err != nil {
+		return scim.Resource{}, err
+	}
+	return h.toResource(stored)
+}
+
+// CreateTables creates the table of the handler and its child tables, if they do not exist yet. Indexed columns of
+// attributes with a "server" or "global" uniqueness get a unique constraint.
+func (h *Handler) CreateTables(ctx context.Context) error {
+	for _, statement := range h.layout.createStatements(h.dialect) {
+		if _, err := h.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the resource with the given identifier.
+func (h *Handler) Delete(r *http.Request, id string) error {
+	return h.transaction(r, nil, func(ctx context.Context, tx *sql.Tx) error {
+		stored, err := h.get(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if version, ok := scim.ExpectedVersion(r); ok && version != stored.version() {
+			return errors.ScimErrorPreconditionFailed
+		}
+		if err := h.deleteChildren(ctx, tx, id); err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"DELETE FROM %s WHERE id = %s AND revision = %s",
+			h.layout.table, h.dialect.Placeholder(1), h.dialect.Placeholder(2),
+		), id, stored.revision)
+		if err != nil {
+			return err
+		}
+		return checkModified(result)
+	})
+}
+
+// Get returns the resource with the given identifier.
+func (h *Handler) Get(r *http.Request, id string) (scim.Resource, error) {
+	stored, err := h.get(requestContext(r), h.db, id)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+	return h.toResource(stored)
+}
+
+// GetAll returns the page of resources that pass the filter of the given parameters, sorted and paginated accordingly.
+func (h *Handler) GetAll(r *http.Request, params scim.ListRequestParams) (scim.Page, error) {
+	return h.query(r, params.FilterValidator, params.SortBy, params.SortOrder, params.StartIndex, params.Count)
+}
+
+// Replace replaces all attributes of the resource with the given identifier.
+func (h *Handler) Replace(r *http.Request, id string, attributes scim.ResourceAttributes) (scim.Resource, error) {
+	values, externalID := splitExternalID(attributes)
+	document, err := json.Marshal(values)
+	if err != nil {
+		return scim.Resource{}, err
+	}
+
+	var replaced record
+	if err := h.transaction(r, nil, func(ctx context.Context, tx *sql.Tx) error {
+		stored, err := h.get(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if version, ok := scim.ExpectedVersion(r); ok && version != stored.version() {
+			return errors.ScimErrorPreconditionFailed
+		}
+		if err := h.checkUniqueness(ctx, tx, id, values); err != nil {
+			return err
+		}
+
+		replaced = record{
+			id:           id,
+			externalID:   externalID,
+			revision:     stored.revision + 1,
+			created:      stored.created,
+			lastModified: h.now().UTC(),
+			document:     string(document),
+		}
+		assignments := []string{"external_id", "revision", "last_modified", "attributes"}
+		args := []interface{}{replaced.externalIDValue(), replaced.revision, replaced.lastModified, replaced.document}
+		for _, c := range h.layout.columns {
+			assignments = append(assignments, c.name)
+			args = append(args, c.value(values))
+		}
+		for i, name := range assignments {
+			assignments[i] = fmt.Sprintf("%s = %s", name, h.dialect.Placeholder(i+1))
+		}
+		result, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"UPDATE %s SET %s WHERE id = %s AND revision = %s",
+			h.layout.table, strings.Join(assignments, ", "),
+			h.dialect.Placeholder(len(args)+1), h.dialect.Placeholder(len(args)+2),
+		), append(args, id, stored.revision)...)
+		if err != nil {
+			return h.execError(err)
+		}
+		if err := checkModified(result); err != nil {
+			return err
+		}
+		if err := h.deleteChildren(ctx, tx, id); err != nil {
+			return err
+		}
+		return h.insertChildren(ctx, tx, id, values)
+	}); err != nil {
+		return scim.Resource{}, err
+	}
+	return h.toResource(replaced)
+}
+
+// Search returns the page of resources that match the given search request.
+func (h *Handler) Search(r *http.Request, params scim.SearchParams) (scim.Page, error) {
+	return h.query(r, params.FilterValidator, params.SortBy, params.SortOrder, params.StartIndex, params.Count)
+}
+
+// checkUniqueness returns a 409 Conflict SCIM error if one of the unique attributes of the given attributes has the same
+// value as another resource than the one with the given identifier.
+func (h *Handler) checkUniqueness(ctx context.Context, tx *sql.Tx, id string, values map[string]interface{}) error {
+	for _, c := range h.layout.columns {
+		if !c.unique {
+			continue
+		}
+		value := c.value(values)
+		if value == nil {
+			continue
+		}
+
+		caseInsensitive := c.attr.AttributeType() == "string" && !c.attr.CaseExact()
+		condition := h.dialect.Compare(c.name, "=", h.dialect.Placeholder(1), caseInsensitive)
+		if _, ok := value.(string); ok && !caseInsensitive && h.dialect.CompareExact != nil {
+			condition = h.dialect.CompareExact(c.name, "=", h.dialect.Placeholder(1))
+		}
+		var n int
+		if err := tx.QueryRowContext(ctx, fmt.Sprintf(
+			"SELECT COUNT(*) FROM %s WHERE %s AND id <> %s",
+			h.layout.table, condition, h.dialect.Placeholder(2),
+		), value, id).Scan(&n); err != nil {
+			return err
+		}
+		if n != 0 {
+			return errors.ScimErrorUniqueness
+		}
+	}
+	return nil
+}
+
+// deleteChildren deletes the rows of the child tables of the resource with the given identifier.
+func (h *Handler) deleteChildren(ctx context.Context, tx *sql.Tx, id string) error {
+	for _, child := range h.layout.children {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"DELETE FROM %s WHERE resource_id = %s",
+			child.name, h.dialect.Placeholder(1),
+		), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execError returns the error of the given failed insert or update, in which a violation of a unique constraint is
+// replaced by a 409 Conflict SCIM error. The constraint is violated if a conflicting resource was stored concurrently,
+// after the uniqueness was checked.
+func (h *Handler) execError(err error) error {
+	if h.dialect.uniqueViolation(err) {
+		return errors.ScimErrorUniqueness
+	}
+	return err
+}
+
+// get returns the stored resource with the given identifier.
+func (h *Handler) get(ctx context.Context, q queryer, id string) (record, error) {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(
+		"SELECT %s FROM %s WHERE id = %s",
+		recordColumns, h.layout.table, h.dialect.Placeholder(1),
+	), id)
+	if err != nil {
+		return record{}, err
+	}
+	records, err := scanRecords(rows)
+	if err != nil {
+		return record{}, err
+	}
+	if len(records) == 0 {
+		return record{}, errors.ScimErrorResourceNotFound(id)
+	}
+	return records[0], nil
+}
+
+// insertChildren inserts the values of the multi-valued attributes of the given attributes into the child tables.
+func (h *Handler) insertChildren(ctx context.Context, tx *sql.Tx, id string, values map[string]interface{}) error {
+	for _, child := range h.layout.children {
+		columns := []string{"resource_id"}
+		for _, c := range child.columns {
+			columns = append(columns, c.name)
+		}
+		statement := fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s)",
+			child.name, strings.Join(columns, ", "), h.placeholders(1, len(columns)),
+		)
+		for _, row := range child.rows(values) {
+			if _, err := tx.ExecContext(ctx, statement, append([]interface{}{id}, row...)...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// orderBy returns the ORDER BY expression for the given sort parameters. It returns false if the attribute can not be
+// sorted by the database, i.e., if it is multi-valued. Resources without a value are ordered last in ascending order and
+// first in descending order, just like by ResourceType.ResourceComparator.
+func (h *Handler) orderBy(sortBy, sortOrder string) (string, bool, error) {
+	direction := ""
+	if sortOrder == "descending" {
+		direction = " DESC"
+	}
+	table := h.layout.table
+	if sortBy == "" {
+		return fmt.Sprintf("%s.created, %s.id", table, table), true, nil
+	}
+
+	s := schema.WithCommonAttributes(h.resourceType.Schema)
+	var exts []schema.Schema
+	for _, extension := range h.resourceType.SchemaExtensions {
+		exts = append(exts, extension.Schema)
+	}
+	attrPath, _, err := filter.ValidateAttributePath(sortBy, s, exts...)
+	if err != nil {
+		return "", false, errors.ScimErrorBadParams([]string{"sortBy"})
+	}
+	key := attrPath.AttributeName
+	if sub := attrPath.SubAttributeName(); sub != "" {
+		key += "." + sub
+	}
+	if uri := attrPath.URI(); uri != "" && uri != s.ID {
+		key = uri + ":" + key
+	}
+
+	expr, ok := h.layout.sortExpression(key)
+	if !ok {
+		return "", false, nil
+	}
+	return fmt.Sprintf("%s IS NULL%s, %s%s, %s.id", expr, direction, expr, direction, table), true, nil
+}
+
+// placeholders returns a comma-separated list of n placeholders, starting at the given position.
+func (h *Handler) placeholders(position, n int) string {
+	placeholders := make([]string, n)
+	for i := range placeholders {
+		placeholders[i] = h.dialect.Placeholder(position + i)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// query returns the page of resources that pass the given filter, sorted and paginated by the given parameters.
+func (h *Handler) query(r *http.Request, validator *filter.Validator, sortBy, sortOrder string, startIndex, count int) (scim.Page, error) {
+	var (
+		where string
+		args  []interface{}
+	)
+	if validator != nil {
+		condition, filterArgs, err := h.translator.Translate(validator.GetFilter())
+		if err != nil {
+			scimErr := errors.ScimErrorInvalidFilter
+			scimErr.Detail = err.Error()
+			return scim.Page{}, scimErr
+		}
+		where, args = " WHERE "+condition, filterArgs
+	}
+	orderBy, pushdown, err := h.orderBy(sortBy, sortOrder)
+	if err != nil {
+		return scim.Page{}, err
+	}
+
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	page := scim.Page{Resources: []scim.Resource{}}
+	// The total number of results and the page are read from the same snapshot, so that they are consistent.
+	if err := h.transaction(r, &sql.TxOptions{Isolation: h.dialect.ReadIsolation, ReadOnly: true}, func(ctx context.Context, tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, fmt.Sprintf(
+			"SELECT COUNT(*) FROM %s%s", h.layout.table, where,
+		), args...).Scan(&page.TotalResults); err != nil {
+			return err
+		}
+		if count <= 0 || page.TotalResults < startIndex {
+			return nil
+		}
+
+		query := fmt.Sprintf("SELECT %s FROM %s%s", recordColumns, h.layout.table, where)
+		if pushdown {
+			query += fmt.Sprintf(
+				" ORDER BY %s LIMIT %s OFFSET %s",
+				orderBy, h.dialect.Placeholder(len(args)+1), h.dialect.Placeholder(len(args)+2),
+			)
+			args = append(args, count, startIndex-1)
+		}
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		records, err := scanRecords(rows)
+		if err != nil {
+			return err
+		}
+		for _, stored := range records {
+			resource, err := h.toResource(stored)
+			if err != nil {
+				return err
+			}
+			page.Resources = append(page.Resources, resource)
+		}
+		return nil
+	}); err != nil {
+		return scim.Page{}, err
+	}
+	if pushdown || len(page.Resources) == 0 {
+		return page, nil
+	}
+
+	// Attributes that can not be sorted by the database, are sorted in memory.
+	if err := h.resourceType.SortResources(page.Resources, sortBy, sortOrder); err != nil {
+		return scim.Page{}, errors.ScimErrorBadParams([]string{"sortBy"})
+	}
+	start, end := startIndex-1, startIndex-1+count
+	if end > len(page.Resources) {
+		end = len(page.Resources)
+	}
+	page.Resources = page.Resources[start:end]
+	return page, nil
+}
+
+// toResource decodes the given stored resource.
+func (h *Handler) toResource(stored record) (scim.Resource, error) {
+	d := json.NewDecoder(bytes.NewReader([]byte(stored.document)))
+	d.UseNumber()
+	var values map[string]interface{}
+	if err := d.Decode(&values); err != nil {
+		return scim.Resource{}, err
+	}
+	normalizeValues(h.resourceType.Schema.Attributes, values)
+	for _, extension := range h.resourceType.SchemaExtensions {
+		if m, ok := lookupMap(values, extension.Schema.ID); ok {
+			normalizeValues(extension.Schema.Attributes, m)
+		}
+	}
+
+	created, lastModified := stored.created, stored.lastModified
+	return scim.Resource{
+		ID:         stored.id,
+		ExternalID: stored.externalID,
+		Attributes: values,
+		Meta: scim.Meta{
+			Created:      &created,
+			LastModified: &lastModified,
+			Version:      stored.version(),
+		},
+	}, nil
+}
+
+// transaction runs the given function within a transaction with the given options, which is committed if the function
+// succeeds.
+func (h *Handler) transaction(r *http.Request, opts *sql.TxOptions, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx := requestContext(r)
+	tx, err := h.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err := fn(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithClock sets the function that returns the current time, which is used for the metadata of the resources.
+func WithClock(now func() time.Time) Option {
+	return func(h *Handler) {
+		h.now = now
+	}
+}
+
+// WithIDGenerator sets the function that generates the identifiers of new resources. It defaults to random UUIDs.
+func WithIDGenerator(newID func() string) Option {
+	return func(h *Handler) {
+		h.newID = newID
+	}
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// record represents a stored resource.
+type record struct {
+	id           string
+	externalID   optional.String
+	revision     int64
+	created      time.Time
+	lastModified time.Time
+	// document is the JSON document of the attributes of the resource, without the common attributes.
+	document string
+}
+
+// externalIDValue returns the value of the external identifier column.
+func (r record) externalIDValue() interface{} {
+	if !r.externalID.Present() {
+		return nil
+	}
+	return r.externalID.Value()
+}
+
+// version returns the version of the resource, which is a weak entity tag.
+func (r record) version() string {
+	return fmt.Sprintf(`W/"%d"`, r.revision)
+}
@@ -0,0 +1,441 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/errors"
+	"github.com/elimity-com/scim/filter"
+	"github.com/elimity-com/scim/optional"
+	"github.com/elimity-com/scim/schema"
+	_ "modernc.org/sqlite"
+)
+
+const testExtensionID = "urn:ietf:params:scim:schemas:extension:test:2.0:User"
+
+var testTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestHandlerCreate(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	resource, err := h.Create(httptest.NewRequest(http.MethodPost, "/Users", nil), scim.ResourceAttributes{
+		"userName":   "alice",
+		"externalId": "a",
+		"age":        int64(42),
+		"name":       map[string]interface{}{"givenName": "Alice"},
+		"emails": []interface{}{
+			map[string]interface{}{"value": "alice@example.com", "type": "work"},
+		},
+		testExtensionID: map[string]interface{}{"employeeNumber": "7"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.ID != "0001" || resource.ExternalID != optional.NewString("a") || resource.Meta.Version != `W/"1"` {
+		t.Error(resource)
+	}
+	if _, ok := resource.Attributes["externalId"]; ok {
+		t.Error(resource.Attributes)
+	}
+	if !resource.Meta.Created.Equal(testTime) {
+		t.Error(resource.Meta.Created)
+	}
+
+	var userName, employeeNumber string
+	var age int64
+	if err := db.QueryRow(
+		"SELECT attr_username, attr_age, ext1_employeenumber FROM users WHERE id = ?", "0001",
+	).Scan(&userName, &age, &employeeNumber); err != nil {
+		t.Fatal(err)
+	}
+	if userName != "alice" || age != 42 || employeeNumber != "7" {
+		t.Error(userName, age, employeeNumber)
+	}
+	var email, emailType string
+	if err := db.QueryRow(
+		"SELECT attr_value, attr_type FROM users_attr_emails WHERE resource_id = ?", "0001",
+	).Scan(&email, &emailType); err != nil {
+		t.Fatal(err)
+	}
+	if email != "alice@example.com" || emailType != "work" {
+		t.Error(email, emailType)
+	}
+}
+
+func TestHandlerCreateUniqueness(t *testing.T) {
+	h, db := newTestHandler(t, WithIDGenerator(newTestIDs()))
+	create := func(userName string) error {
+		_, err := h.Create(httptest.NewRequest(http.MethodPost, "/Users", nil), scim.ResourceAttributes{"userName": userName})
+		return err
+	}
+
+	if err := create("alice"); err != nil {
+		t.Fatal(err)
+	}
+	// The user name is not case-exact, so it is unique regardless of its case.
+	if err := create("ALICE"); !isScimError(err, http.StatusConflict) {
+		t.Errorf("expected a uniqueness error, got %v", err)
+	}
+	if err := create("bob"); err != nil {
+		t.Fatal(err)
+	}
+	// The failed creation used the second identifier.
+	if _, err := h.Replace(httptest.NewRequest(http.MethodPut, "/Users/0003", nil), "0003", scim.ResourceAttributes{
+		"userName": "alice",
+	}); !isScimError(err, http.StatusConflict) {
+		t.Errorf("expected a uniqueness error, got %v", err)
+	}
+
+	// A resource that is stored concurrently, after the uniqueness was checked, violates the unique constraint.
+	_, err := db.Exec(
+		"INSERT INTO users (id, revision, created, last_modified, attributes, attr_username) VALUES (?, 1, ?, ?, '{}', ?)",
+		"0004", testTime, testTime, "bob",
+	)
+	if err == nil {
+		t.Fatal("expected a violation of the unique constraint")
+	}
+	if h.execError(err) != errors.ScimErrorUniqueness {
+		t.Errorf("expected a uniqueness error, got %v", h.execError(err))
+	}
+}
+
+func TestHandlerDelete(t *testing.T) {
+	h, db := newTestHandler(t)
+	if _, err := h.Create(httptest.NewRequest(http.MethodPost, "/Users", nil), scim.ResourceAttributes{
+		"userName": "alice",
+		"tags":     []interface{}{"a"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Delete(httptest.NewRequest(http.MethodDelete, "/Users/0001", nil), "0001"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Get(nil, "0001"); !isScimError(err, http.StatusNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users_attr_tags").Scan(&n); err != nil || n != 0 {
+		t.Errorf("expected the tags to be deleted: %d, %v", n, err)
+	}
+	if err := h.Delete(httptest.NewRequest(http.MethodDelete, "/Users/0001", nil), "0001"); !isScimError(err, http.StatusNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestHandlerGet(t *testing.T) {
+	h, _ := newTestHandler(t)
+	if _, err := h.Create(httptest.NewRequest(http.MethodPost, "/Users", nil), scim.ResourceAttributes{
+		"userName":      "alice",
+		"age":           int64(42),
+		testExtensionID: map[string]interface{}{"employeeNumber": "7"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	resource, err := h.Get(nil, "0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.Meta.Version != `W/"1"` || !resource.Meta.LastModified.Equal(testTime) {
+		t.Error(resource.Meta)
+	}
+	// Numbers are decoded as the types of validated attribute values.
+	if age, ok := resource.Attributes["age"].(int64); !ok || age != 42 {
+		t.Errorf("%T: %v", resource.Attributes["age"], resource.Attributes["age"])
+	}
+
+	if _, err := h.Get(nil, "0002"); !isScimError(err, http.StatusNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestHandlerPreconditions(t *testing.T) {
+	h, _ := newTestHandler(t)
+	resourceType := testResourceType()
+	resourceType.Handler = h
+	s, err := scim.NewServer(&scim.ServerArgs{
+		ServiceProviderConfig: &scim.ServiceProviderConfig{SupportETag: true},
+		ResourceTypes:         []scim.ResourceType{resourceType},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(version, body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/Users/0001", strings.NewReader(body))
+		req.Header.Set("If-Match", version)
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if _, err := h.Create(httptest.NewRequest(http.MethodPost, "/Users", nil), scim.ResourceAttributes{"userName": "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	body := `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "bob"}`
+	if code := serve(`W/"1"`, body); code != http.StatusOK {
+		t.Errorf("expected 200, got %d", code)
+	}
+	if code := serve(`W/"1"`, body); code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412, got %d", code)
+	}
+}
+
+func TestHandlerReplace(t *testing.T) {
+	h, db := newTestHandler(t, WithClock(newTestClock()))
+	if _, err := h.Create(httptest.NewRequest(http.MethodPost, "/Users", nil), scim.ResourceAttributes{
+		"userName":   "alice",
+		"externalId": "a",
+		"tags":       []interface{}{"x"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	resource, err := h.Replace(httptest.NewRequest(http.MethodPut, "/Users/0001", nil), "0001", scim.ResourceAttributes{
+		"userName": "bob",
+		"active":   true,
+		"tags":     []interface{}{"a", "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.Meta.Version != `W/"2"` || resource.Attributes["userName"] != "bob" || resource.ExternalID.Present() {
+		t.Error(resource)
+	}
+	if !resource.Meta.Created.Equal(testTime) || !resource.Meta.LastModified.After(testTime) {
+		t.Error(resource.Meta)
+	}
+
+	rows, err := db.Query("SELECT attr_value FROM users_attr_tags WHERE resource_id = ? ORDER BY attr_value", "0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			t.Fatal(err)
+		}
+		tags = append(tags, tag)
+	}
+	if strings.Join(tags, ",") != "a,b" {
+		t.Error(tags)
+	}
+
+	if _, err := h.Replace(httptest.NewRequest(http.MethodPut, "/Users/0002", nil), "0002", scim.ResourceAttributes{
+		"userName": "charlie",
+	}); !isScimError(err, http.StatusNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestHandlerSearch(t *testing.T) {
+	h, _ := newTestHandler(t, WithIDGenerator(newTestIDs()), WithClock(newTestClock()))
+	for _, attributes := range []scim.ResourceAttributes{
+		{
+			"userName": "anne",
+			"name":     map[string]interface{}{"familyName": "Smith"},
+			"emails":   []interface{}{map[string]interface{}{"value": "a@example.com", "type": "work"}},
+		},
+		{
+			"userName": "alice",
+			"emails":   []interface{}{map[string]interface{}{"value": "b@example.com", "type": "work"}},
+		},
+		{
+			"userName": "adam",
+			"emails":   []interface{}{map[string]interface{}{"value": "c@example.com", "type": "home"}},
+		},
+		{
+			"userName": "bob",
+			"name":     map[string]interface{}{"familyName": "Jones"},
+			"emails":   []interface{}{map[string]interface{}{"value": "d@example.com", "type": "work"}},
+		},
+	} {
+		if _, err := h.Create(httptest.NewRequest(http.MethodPost, "/Users", nil), attributes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		name      string
+		filter    string
+		sortBy    string
+		sortOrder string
+		start     int
+		count     int
+		total     int
+		expected  []string
+	}{
+		{name: "all", count: 10, total: 4, expected: []string{"anne", "alice", "adam", "bob"}},
+		{
+			name:      "pushdown",
+			filter:    `userName sw "A" and emails[type eq "work"]`,
+			sortBy:    "userName",
+			sortOrder: "descending",
+			start:     2,
+			count:     1,
+			total:     2,
+			expected:  []string{"alice"},
+		},
+		{
+			name:     "sorted in memory",
+			filter:   `emails[type eq "work"]`,
+			sortBy:   "emails",
+			start:    2,
+			count:    2,
+			total:    3,
+			expected: []string{"alice", "bob"},
+		},
+		{name: "missing attributes", filter: `not (name.familyName eq "smith")`, count: 10, total: 3, expected: []string{"alice", "adam", "bob"}},
+		{name: "missing values ascending", sortBy: "name.familyName", count: 10, total: 4, expected: []string{"bob", "anne", "alice", "adam"}},
+		{name: "missing values descending", sortBy: "name.familyName", sortOrder: "descending", count: 10, total: 4, expected: []string{"alice", "adam", "anne", "bob"}},
+		{name: "present", filter: `name.familyName pr`, sortBy: "name.familyName", count: 10, total: 2, expected: []string{"bob", "anne"}},
+		{name: "common attributes", filter: `meta.created gt "2020-01-02T03:04:06Z"`, sortBy: "meta.created", sortOrder: "descending", count: 10, total: 2, expected: []string{"bob", "adam"}},
+		{name: "count only", filter: `userName sw "a"`, total: 3},
+		{name: "out of range", start: 5, count: 10, total: 4},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			params := scim.SearchParams{
+				SortBy:     test.sortBy,
+				SortOrder:  test.sortOrder,
+				StartIndex: test.start,
+				Count:      test.count,
+			}
+			if test.filter != "" {
+				validator, err := filter.NewValidator(test.filter, schema.WithCommonAttributes(testResourceType().Schema), testExtension())
+				if err != nil {
+					t.Fatal(err)
+				}
+				params.FilterValidator = &validator
+			}
+
+			page, err := h.Search(nil, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.TotalResults != test.total {
+				t.Errorf("expected %d results, got %d", test.total, page.TotalResults)
+			}
+			if page.Resources == nil {
+				t.Error("expected an empty page")
+			}
+			var userNames []string
+			for _, resource := range page.Resources {
+				userNames = append(userNames, resource.Attributes["userName"].(string))
+			}
+			if strings.Join(userNames, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("expected %v, got %v", test.expected, userNames)
+			}
+		})
+	}
+}
+
+func isScimError(err error, status int) bool {
+	scimErr, ok := err.(errors.ScimError)
+	return ok && scimErr.Status == status
+}
+
+// newTestClock returns a clock that advances a second on every call, starting at the test time.
+func newTestClock() func() time.Time {
+	now := testTime.Add(-time.Second)
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+// newTestHandler returns a handler of the users of a new SQLite database, with the clock stopped at the test time and
+// "0001" as identifier of all resources, unless overridden by the given options.
+func newTestHandler(t *testing.T, opts ...Option) (*Handler, *sql.DB) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "scim.db")+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	opts = append([]Option{
+		WithClock(func() time.Time { return testTime }),
+		WithIDGenerator(func() string { return "0001" }),
+	}, opts...)
+	h := NewHandler(db, SQLite, "users", testResourceType(), opts...)
+	if err := h.CreateTables(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return h, db
+}
+
+// newTestIDs returns an identifier generator that returns "0001", "0002", etc.
+func newTestIDs() func() string {
+	var n int
+	return func() string {
+		n++
+		return fmt.Sprintf("%04d", n)
+	}
+}
+
+func testExtension() schema.Schema {
+	return schema.Schema{
+		ID:   testExtensionID,
+		Name: optional.NewString("Test"),
+		Attributes: []schema.CoreAttribute{
+			schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{Name: "employeeNumber"})),
+		},
+	}
+}
+
+func testResourceType() scim.ResourceType {
+	return scim.ResourceType{
+		Name:     "User",
+		Endpoint: "/Users",
+		Schema: schema.Schema{
+			ID:   "urn:ietf:params:scim:schemas:core:2.0:User",
+			Name: optional.NewString("User"),
+			Attributes: []schema.CoreAttribute{
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					Name:       "userName",
+					Required:   true,
+					Uniqueness: schema.AttributeUniquenessServer(),
+				})),
+				schema.SimpleCoreAttribute(schema.SimpleBooleanParams(schema.BooleanParams{Name: "active"})),
+				schema.SimpleCoreAttribute(schema.SimpleNumberParams(schema.NumberParams{
+					Name: "age",
+					Type: schema.AttributeTypeInteger(),
+				})),
+				schema.ComplexCoreAttribute(schema.ComplexParams{
+					Name: "name",
+					SubAttributes: []schema.SimpleParams{
+						schema.SimpleStringParams(schema.StringParams{Name: "givenName"}),
+						schema.SimpleStringParams(schema.StringParams{Name: "familyName"}),
+					},
+				}),
+				schema.ComplexCoreAttribute(schema.ComplexParams{
+					MultiValued: true,
+					Name:        "emails",
+					SubAttributes: []schema.SimpleParams{
+						schema.SimpleStringParams(schema.StringParams{Name: "value"}),
+						schema.SimpleStringParams(schema.StringParams{Name: "type"}),
+						schema.SimpleBooleanParams(schema.BooleanParams{Name: "primary"}),
+					},
+				}),
+				schema.SimpleCoreAttribute(schema.SimpleStringParams(schema.StringParams{
+					MultiValued: true,
+					Name:        "tags",
+				})),
+			},
+		},
+		SchemaExtensions: []scim.SchemaExtension{
+			{Schema: testExtension()},
+		},
+	}
+}
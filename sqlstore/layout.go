@@ -0,0 +1,348 @@
+package sqlstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	datetime "github.com/di-wu/xsd-datetime"
+	"github.com/elimity-com/scim"
+	"github.com/elimity-com/scim/filter/sqlfilter"
+	"github.com/elimity-com/scim/schema"
+)
+
+// columnName returns a column name, consisting of lower case letters, digits and underscores, for the given parts.
+func columnName(parts ...string) string {
+	name := strings.ToLower(strings.Join(parts, "_"))
+	return strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('0' <= r && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// columnValue returns the value of the given attribute as stored in an indexed column. Values of dateTime attributes
+// are parsed, numbers are converted to the type of the attribute and complex values are not indexed.
+func columnValue(attr schema.CoreAttribute, value interface{}) interface{} {
+	switch attr.AttributeType() {
+	case "dateTime":
+		if s, ok := value.(string); ok {
+			if t, err := datetime.Parse(s); err == nil {
+				return t.UTC()
+			}
+		}
+		return nil
+	case "integer":
+		if i, ok := toInt(value); ok {
+			return i
+		}
+		return nil
+	case "decimal":
+		if f, ok := toFloat(value); ok {
+			return f
+		}
+		return nil
+	}
+	switch value.(type) {
+	case string, bool:
+		return value
+	default:
+		return nil
+	}
+}
+
+// lookup returns the value of the attribute with the given name, attribute names are case-insensitive.
+func lookup(values map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := values[name]; ok {
+		return v, true
+	}
+	for k, v := range values {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// lookupMap returns the complex value of the attribute with the given name.
+func lookupMap(values map[string]interface{}, name string) (map[string]interface{}, bool) {
+	value, _ := lookup(values, name)
+	m, ok := value.(map[string]interface{})
+	return m, ok
+}
+
+// toFloat converts the given number to a float.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	if i, ok := toInt(value); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+// toInt converts the given number to an integer.
+func toInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, false
+		}
+		return int64(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// column is an indexed column of the resource table, derived from a simple singular (sub-)attribute.
+type column struct {
+	name string
+	// uri is the identifier of the schema extension of the attribute, empty for attributes of the core schema.
+	uri string
+	// path is the name of the attribute followed by the name of the sub-attribute, if any.
+	path []string
+	attr schema.CoreAttribute
+	// unique indicates whether the values of the column must be unique.
+	unique bool
+}
+
+// key returns the attribute path of the column, as used within a sqlfilter.Mapping.
+func (c column) key() string {
+	key := strings.Join(c.path, ".")
+	if c.uri != "" {
+		key = c.uri + ":" + key
+	}
+	return key
+}
+
+// value returns the value of the column within the given attributes.
+func (c column) value(attributes map[string]interface{}) interface{} {
+	values := attributes
+	if c.uri != "" {
+		values, _ = lookupMap(attributes, c.uri)
+	}
+	for _, name := range c.path[:len(c.path)-1] {
+		values, _ = lookupMap(values, name)
+	}
+	value, _ := lookup(values, c.path[len(c.path)-1])
+	return columnValue(c.attr, value)
+}
+
+// childColumn is a column of a child table, derived from a sub-attribute of a multi-valued attribute.
+type childColumn struct {
+	name string
+	attr schema.CoreAttribute
+}
+
+// childTable is the table that contains the values of a multi-valued attribute, one row per value.
+type childTable struct {
+	name string
+	// uri is the identifier of the schema extension of the attribute, empty for attributes of the core schema.
+	uri     string
+	attr    schema.CoreAttribute
+	columns []childColumn
+}
+
+// key returns the name of the attribute, as used within a sqlfilter.Mapping.
+func (c childTable) key() string {
+	if c.uri != "" {
+		return c.uri + ":" + c.attr.Name()
+	}
+	return c.attr.Name()
+}
+
+// rows returns the values of the columns of each row of the table for the given attributes.
+func (c childTable) rows(attributes map[string]interface{}) [][]interface{} {
+	values := attributes
+	if c.uri != "" {
+		values, _ = lookupMap(attributes, c.uri)
+	}
+	value, _ := lookup(values, c.attr.Name())
+	elements, _ := value.([]interface{})
+
+	var rows [][]interface{}
+	for _, element := range elements {
+		row := make([]interface{}, len(c.columns))
+		if m, ok := element.(map[string]interface{}); ok {
+			for i, col := range c.columns {
+				v, _ := lookup(m, col.attr.Name())
+				row[i] = columnValue(col.attr, v)
+			}
+		} else if len(c.columns) != 0 {
+			row[0] = columnValue(c.columns[0].attr, element)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// layout describes the tables in which the resources of a resource type are stored. Each resource is a row in the
+// main table, that contains its JSON document, metadata and an indexed column for each simple singular attribute.
+// The values of multi-valued attributes are also stored in child tables, so that filters can be pushed down.
+type layout struct {
+	table    string
+	columns  []column
+	children []childTable
+}
+
+// newLayout returns the layout of the resources of the given resource type, stored in the table with the given name.
+func newLayout(table string, resourceType scim.ResourceType) layout {
+	l := layout{table: table}
+	add := func(prefix, uri string, s schema.Schema) {
+		for _, attr := range s.Attributes {
+			switch {
+			case attr.MultiValued():
+				child := childTable{
+					name: columnName(table, prefix, attr.Name()),
+					uri:  uri,
+					attr: attr,
+				}
+				if attr.HasSubAttributes() {
+					for _, sub := range attr.SubAttributes() {
+						child.columns = append(child.columns, childColumn{name: columnName("attr", sub.Name()), attr: sub})
+					}
+				} else {
+					child.columns = []childColumn{{name: "attr_value", attr: attr}}
+				}
+				l.children = append(l.children, child)
+			case attr.HasSubAttributes():
+				for _, sub := range attr.SubAttributes() {
+					if sub.MultiValued() {
+						continue
+					}
+					l.columns = append(l.columns, column{
+						name: columnName(prefix, attr.Name(), sub.Name()),
+						uri:  uri,
+						path: []string{attr.Name(), sub.Name()},
+						attr: sub,
+					})
+				}
+			default:
+				u := attr.Uniqueness()
+				l.columns = append(l.columns, column{
+					name:   columnName(prefix, attr.Name()),
+					uri:    uri,
+					path:   []string{attr.Name()},
+					attr:   attr,
+					unique: u == "server" || u == "global",
+				})
+			}
+		}
+	}
+	add("attr", "", resourceType.Schema)
+	for i, extension := range resourceType.SchemaExtensions {
+		add(fmt.Sprintf("ext%d", i+1), extension.Schema.ID, extension.Schema)
+	}
+	return l
+}
+
+// createStatements returns the statements that create the tables of the layout, if they do not exist yet.
+func (l layout) createStatements(d Dialect) []string {
+	definitions := []string{
+		fmt.Sprintf("id %s PRIMARY KEY", d.Types["key"]),
+		fmt.Sprintf("external_id %s", d.Types["string"]),
+		fmt.Sprintf("revision %s NOT NULL", d.Types["integer"]),
+		fmt.Sprintf("created %s NOT NULL", d.Types["dateTime"]),
+		fmt.Sprintf("last_modified %s NOT NULL", d.Types["dateTime"]),
+		fmt.Sprintf("attributes %s NOT NULL", d.Types["document"]),
+	}
+	for _, c := range l.columns {
+		definition := fmt.Sprintf("%s %s", c.name, d.Types[c.attr.AttributeType()])
+		if c.unique {
+			definition += " UNIQUE"
+		}
+		definitions = append(definitions, definition)
+	}
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", l.table, strings.Join(definitions, ", ")),
+	}
+
+	for _, child := range l.children {
+		definitions := []string{
+			fmt.Sprintf("resource_id %s NOT NULL REFERENCES %s (id) ON DELETE CASCADE", d.Types["key"], l.table),
+		}
+		for _, c := range child.columns {
+			definitions = append(definitions, fmt.Sprintf("%s %s", c.name, d.Types[c.attr.AttributeType()]))
+		}
+		statements = append(statements,
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", child.name, strings.Join(definitions, ", ")),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_resource_id ON %s (resource_id)", child.name, child.name),
+		)
+	}
+	return statements
+}
+
+// mapping returns the mapping of the attributes to the columns and child tables of the layout.
+func (l layout) mapping() sqlfilter.Mapping {
+	m := sqlfilter.Mapping{
+		Columns: map[string]string{
+			"id":                l.table + ".id",
+			"externalId":        l.table + ".external_id",
+			"meta.created":      l.table + ".created",
+			"meta.lastModified": l.table + ".last_modified",
+		},
+		Tables: make(map[string]sqlfilter.Table),
+	}
+	for _, c := range l.columns {
+		m.Columns[c.key()] = l.table + "." + c.name
+	}
+	for _, child := range l.children {
+		columns := make(map[string]string)
+		for _, c := range child.columns {
+			key := c.attr.Name()
+			if !child.attr.HasSubAttributes() {
+				key = "value"
+			}
+			columns[key] = child.name + "." + c.name
+		}
+		m.Tables[child.key()] = sqlfilter.Table{
+			Name:    child.name,
+			Join:    fmt.Sprintf("%s.resource_id = %s.id", child.name, l.table),
+			Columns: columns,
+		}
+	}
+	return m
+}
+
+// sortExpression returns the expression to sort the resources by the attribute with the given path. Strings that are
+// not case-exact are sorted case-insensitively.
+func (l layout) sortExpression(key string) (string, bool) {
+	switch strings.ToLower(key) {
+	case "id":
+		return l.table + ".id", true
+	case "externalid":
+		return l.table + ".external_id", true
+	case "meta.created":
+		return l.table + ".created", true
+	case "meta.lastmodified":
+		return l.table + ".last_modified", true
+	}
+	for _, c := range l.columns {
+		if !strings.EqualFold(c.key(), key) {
+			continue
+		}
+		if c.attr.AttributeType() == "string" && !c.attr.CaseExact() {
+			return fmt.Sprintf("LOWER(%s.%s)", l.table, c.name), true
+		}
+		return l.table + "." + c.name, true
+	}
+	return "", false
+}
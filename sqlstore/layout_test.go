@@ -0,0 +1,53 @@
+package sqlstore
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLayoutCreateStatements(t *testing.T) {
+	statements := newLayout("users", testResourceType()).createStatements(SQLite)
+	expected := []string{
+		"CREATE TABLE IF NOT EXISTS users (" +
+			"id TEXT PRIMARY KEY, external_id TEXT, revision INTEGER NOT NULL, created TIMESTAMP NOT NULL, " +
+			"last_modified TIMESTAMP NOT NULL, attributes TEXT NOT NULL, attr_username TEXT UNIQUE, " +
+			"attr_active BOOLEAN, attr_age INTEGER, attr_name_givenname TEXT, attr_name_familyname TEXT, " +
+			"ext1_employeenumber TEXT)",
+		"CREATE TABLE IF NOT EXISTS users_attr_emails (" +
+			"resource_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE, " +
+			"attr_value TEXT, attr_type TEXT, attr_primary BOOLEAN)",
+		"CREATE INDEX IF NOT EXISTS users_attr_emails_resource_id ON users_attr_emails (resource_id)",
+		"CREATE TABLE IF NOT EXISTS users_attr_tags (" +
+			"resource_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE, attr_value TEXT)",
+		"CREATE INDEX IF NOT EXISTS users_attr_tags_resource_id ON users_attr_tags (resource_id)",
+	}
+	if !reflect.DeepEqual(expected, statements) {
+		t.Errorf("expected %q, got %q", expected, statements)
+	}
+}
+
+func TestLayoutMapping(t *testing.T) {
+	m := newLayout("users", testResourceType()).mapping()
+	for key, expected := range map[string]string{
+		"id":                                "users.id",
+		"meta.created":                      "users.created",
+		"userName":                          "users.attr_username",
+		"name.familyName":                   "users.attr_name_familyname",
+		testExtensionID + ":employeeNumber": "users.ext1_employeenumber",
+	} {
+		if column := m.Columns[key]; column != expected {
+			t.Errorf("expected column %s for %s, got %s", expected, key, column)
+		}
+	}
+
+	emails := m.Tables["emails"]
+	if emails.Name != "users_attr_emails" || emails.Join != "users_attr_emails.resource_id = users.id" {
+		t.Error(emails)
+	}
+	if column := emails.Columns["primary"]; column != "users_attr_emails.attr_primary" {
+		t.Error(column)
+	}
+	if column := m.Tables["tags"].Columns["value"]; column != "users_attr_tags.attr_value" {
+		t.Error(column)
+	}
+}
@@ -1,6 +1,7 @@
 package scim
 
 import (
+	"encoding/json"
 	"github.com/elimity-com/scim/errors"
 	"reflect"
 	"testing"
@@ -12,6 +13,17 @@ func assertEqual(t *testing.T, expected, actual interface{}) {
 	}
 }
 
+func assertEqualJSON(t *testing.T, expected, actual string) {
+	t.Helper()
+
+	var e, a interface{}
+	assertUnmarshalNoError(t, json.Unmarshal([]byte(expected), &e))
+	assertUnmarshalNoError(t, json.Unmarshal([]byte(actual), &a))
+	if !reflect.DeepEqual(e, a) {
+		t.Errorf("not equal: expected %s, actual %s", expected, actual)
+	}
+}
+
 func assertEqualSCIMErrors(t *testing.T, expected, actual *errors.ScimError) {
 	if expected.ScimType != actual.ScimType ||
 		expected.Detail != actual.Detail ||